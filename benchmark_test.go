@@ -5,9 +5,12 @@ package gonja_test
 
 import (
 	"io/ioutil"
+	"strings"
 	"testing"
 
 	"github.com/paradime-io/gonja"
+	"github.com/paradime-io/gonja/exec"
+	"github.com/paradime-io/gonja/tokens"
 
 	tu "github.com/paradime-io/gonja/testutils"
 )
@@ -52,6 +55,108 @@ func BenchmarkExecute(b *testing.B) {
 	}
 }
 
+// BenchmarkExecuteInterpreted renders without compiling the expressions,
+// to be compared with BenchmarkExecute
+func BenchmarkExecuteInterpreted(b *testing.B) {
+	tpl, err := gonja.FromFile("testData/complex.tpl")
+	if err != nil {
+		b.Fatal(err)
+	}
+	tpl.Program = nil
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err = tpl.Execute(tu.Fixtures)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkExpressions compares the rendering of expressions
+// with and without compiling them
+func BenchmarkExpressions(b *testing.B) {
+	tpl, err := gonja.FromString(strings.Repeat(`{{ (x * 2 + 1 > 50 and x % 3 == 0) or not (x // 4 in [1, 2, 3]) }}{{ [x, x + 1]|join("-")|upper|replace("1", "one") }}{{ d["a"] if x is divisibleby 2 else -x }}`, 100))
+	if err != nil {
+		b.Fatal(err)
+	}
+	ctx := map[string]interface{}{"x": 42, "d": map[string]int{"a": 1}}
+	interpreted := *tpl
+	interpreted.Program = nil
+	for _, tc := range []struct {
+		name string
+		tpl  *exec.Template
+	}{{"compiled", tpl}, {"interpreted", &interpreted}} {
+		tpl := tc.tpl
+		b.Run(tc.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := tpl.Execute(ctx); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkAttributes compares the access to fields, methods and map keys
+// with and without compiling them
+func BenchmarkAttributes(b *testing.B) {
+	tpl, err := gonja.FromString(`{% for comment in complex.comments %}{{ comment.Author.Name }}{{ comment.Author.IsAdmin() }}{{ comment.Date.Year() }}{{ complex.post.Text|length }}{% endfor %}`)
+	if err != nil {
+		b.Fatal(err)
+	}
+	interpreted := *tpl
+	interpreted.Program = nil
+	for _, tc := range []struct {
+		name string
+		tpl  *exec.Template
+	}{{"compiled", tpl}, {"interpreted", &interpreted}} {
+		tpl := tc.tpl
+		b.Run(tc.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := tpl.Execute(tu.Fixtures); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkLex compares lexing on demand with lexing in a goroutine
+// sending the tokens over a channel
+func BenchmarkLex(b *testing.B) {
+	buf, err := ioutil.ReadFile("testData/complex.tpl")
+	if err != nil {
+		b.Fatal(err)
+	}
+	source := string(buf)
+	b.Run("sync", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			stream := tokens.Lex(source)
+			for !stream.End() {
+				stream.Next()
+			}
+		}
+	})
+	b.Run("slice", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			stream := tokens.NewStream(tokens.NewLexer(source).All())
+			for !stream.End() {
+				stream.Next()
+			}
+		}
+	})
+	b.Run("channel", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			lexer := tokens.NewLexer(source)
+			go lexer.Run()
+			stream := tokens.NewStream(lexer.Tokens)
+			for !stream.End() {
+				stream.Next()
+			}
+		}
+	})
+}
+
 func BenchmarkCompileAndExecute(b *testing.B) {
 	buf, err := ioutil.ReadFile("testData/complex.tpl")
 	if err != nil {
@@ -0,0 +1,80 @@
+package gonja_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/paradime-io/gonja"
+	"github.com/paradime-io/gonja/config"
+	"github.com/paradime-io/gonja/loaders"
+)
+
+func TestBlockModifiers(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"base.tpl":     "<{% block content required %}{# to override #} {% endblock %}>",
+		"child.tpl":    "{% extends 'base.tpl' %}{% block content %}child{% endblock %}",
+		"middle.tpl":   "{% extends 'base.tpl' %}{% block title %}{% endblock %}",
+		"leaf.tpl":     "{% extends 'middle.tpl' %}",
+		"override.tpl": "{% extends 'middle.tpl' %}{% block content %}leaf{% endblock %}",
+		"list.tpl":     "{% for i in items %}{% block item scoped %}{{ i }}{% endblock %}{% endfor %}",
+		"items.tpl":    "{% extends 'list.tpl' %}{% block item scoped %}[{{ i }}]{% endblock %}",
+		"both.tpl":     "{% for i in items %}{% block item scoped required %}{% endblock %}{% endfor %}",
+		"both2.tpl":    "{% extends 'both.tpl' %}{% block item %}{{ loop.index }}{% endblock %}",
+		"unscoped.tpl": "{% set x = 'top' %}{% for i in items %}{% set x = i %}{% block item %}[{{ i }}{{ x }}]{% endblock %}{% endfor %}",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	env := gonja.NewEnvironment(config.NewConfig(), loaders.MustNewFileSystemLoader(dir))
+	data := map[string]interface{}{"items": []int{1, 2}}
+
+	cases := []struct {
+		template string
+		expected string
+		err      string
+	}{
+		{"child.tpl", "<child>", ""},
+		{"override.tpl", "<leaf>", ""},
+		{"list.tpl", "12", ""},
+		{"items.tpl", "[1][2]", ""},
+		{"both2.tpl", "12", ""},
+		{"unscoped.tpl", "[top][top]", ""},
+		{"base.tpl", "", `Required block "content" not found in 'base.tpl'`},
+		{"middle.tpl", "", `Required block "content" not found in 'middle.tpl' > 'base.tpl'`},
+		{"leaf.tpl", "", `Required block "content" not found in 'leaf.tpl' > 'middle.tpl' > 'base.tpl'`},
+	}
+	for _, test := range cases {
+		tpl, err := env.FromFile(test.template)
+		if !assert.Nil(t, err, test.template) {
+			continue
+		}
+		out, err := tpl.Execute(data)
+		if test.err != "" {
+			if assert.NotNil(t, err, test.template) {
+				assert.Contains(t, err.Error(), test.err)
+			}
+			continue
+		}
+		assert.Nil(t, err, test.template)
+		assert.Equal(t, test.expected, out, test.template)
+	}
+}
+
+func TestBlockModifiersErrors(t *testing.T) {
+	for _, source := range []string{
+		`{% block content required %}content{% endblock %}`,
+		`{% block content required %}{{ x }}{% endblock %}`,
+		`{% block content unknown %}{% endblock %}`,
+		`{% block content scoped scoped %}{% endblock %}`,
+		`{% block a %}{% endblock b %}`,
+	} {
+		_, err := gonja.FromString(source)
+		assert.NotNil(t, err, source)
+	}
+}
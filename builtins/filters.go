@@ -175,7 +175,7 @@ func filterDefault(e *exec.Evaluator, in *exec.Value, params *exec.VarArgs) *exe
 	falsy := p.KwArgs["boolean"]
 	if falsy.Bool() && (in.IsError() || !in.IsTrue()) {
 		return defaultVal
-	} else if in.IsError() || in.IsNil() {
+	} else if in.IsError() || in.IsUndefined() {
 		return defaultVal
 	}
 	return in
@@ -36,13 +36,18 @@ func Range(va *exec.VarArgs) <-chan int {
 		// 	return nil, errors.New("range expect signature range([start, ]stop[, step])")
 	}
 	chnl := make(chan int)
+	done := va.Done()
 	go func() {
+		// Ensure that at the end of the loop we close the channel!
+		defer close(chnl)
 		for i := start; i < stop; i += step {
-			chnl <- i
+			select {
+			case chnl <- i:
+			case <-done:
+				// Rendering is over, nobody is listening anymore
+				return
+			}
 		}
-
-		// Ensure that at the end of the loop we close the channel!
-		close(chnl)
 	}()
 	return chnl
 }
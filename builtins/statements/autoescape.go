@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/paradime-io/gonja/exec"
+	"github.com/paradime-io/gonja/meta"
 	"github.com/paradime-io/gonja/nodes"
 	"github.com/paradime-io/gonja/parser"
 	"github.com/paradime-io/gonja/tokens"
@@ -32,6 +33,12 @@ func (stmt *AutoescapeStmt) Execute(r *exec.Renderer, tag *nodes.StatementBlock)
 	return nil
 }
 
+func (stmt *AutoescapeStmt) Children() []nodes.Node { return []nodes.Node{stmt.Wrapper} }
+
+func (stmt *AutoescapeStmt) Analyze(a *meta.Analysis) {
+	a.Scope(func() { a.Wrapper(stmt.Wrapper) })
+}
+
 func autoescapeParser(p *parser.Parser, args *parser.Parser) (nodes.Statement, error) {
 	stmt := &AutoescapeStmt{}
 
@@ -62,4 +69,5 @@ func autoescapeParser(p *parser.Parser, args *parser.Parser) (nodes.Statement, e
 
 func init() {
 	All.Register("autoescape", autoescapeParser)
+	exec.RegisterNodes(&AutoescapeStmt{})
 }
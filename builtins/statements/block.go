@@ -7,14 +7,21 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/paradime-io/gonja/exec"
+	"github.com/paradime-io/gonja/meta"
 	"github.com/paradime-io/gonja/nodes"
 	"github.com/paradime-io/gonja/parser"
 	"github.com/paradime-io/gonja/tokens"
 )
 
+// BlockStmt renders the most derived definition of a block.
+// Blocks only see the template level variables unless they are 'scoped',
+// in which case they also see the enclosing ones (ie. loop variables).
 type BlockStmt struct {
 	Location *tokens.Token
 	Name     string
+	Scoped   bool
+	Required bool
+	Wrapper  *nodes.Wrapper // the definition of the block in its template
 }
 
 func (stmt *BlockStmt) Position() *tokens.Token { return stmt.Location }
@@ -32,7 +39,20 @@ func (stmt *BlockStmt) Execute(r *exec.Renderer, tag *nodes.StatementBlock) erro
 		return errors.Errorf(`Unable to find block "%s"`, stmt.Name)
 	}
 
-	sub := r.Inherit()
+	owner := r.Root.BlockOwner(block)
+	if owner != nil && owner.Required[stmt.Name] {
+		return errors.Errorf(`Required block "%s" not found in %s`, stmt.Name, templateChain(r.Root))
+	}
+
+	var sub *exec.Renderer
+	if stmt.Scoped {
+		sub = r.Inherit()
+	} else {
+		sub = r.InheritTemplateScope()
+	}
+	if owner != nil {
+		sub.Current = owner
+	}
 	infos := &BlockInfos{Block: stmt, Renderer: sub, Blocks: blocks}
 
 	sub.Ctx.Set("super", infos.super)
@@ -60,6 +80,9 @@ func (bi *BlockInfos) super() string {
 	r := bi.Renderer
 	block, blocks := bi.Blocks[0], bi.Blocks[1:]
 	sub := r.Inherit()
+	if owner := r.Root.BlockOwner(block); owner != nil {
+		sub.Current = owner
+	}
 	var out strings.Builder
 	sub.Out = &out
 	infos := &BlockInfos{
@@ -73,6 +96,31 @@ func (bi *BlockInfos) super() string {
 	return out.String()
 }
 
+// templateChain lists a template and its parents, ie. 'child.tpl' > 'base.tpl'
+func templateChain(tpl *nodes.Template) string {
+	names := []string{}
+	for ; tpl != nil; tpl = tpl.Parent {
+		names = append(names, fmt.Sprintf("'%s'", tpl.Name))
+	}
+	return strings.Join(names, " > ")
+}
+
+func (stmt *BlockStmt) Children() []nodes.Node { return []nodes.Node{stmt.Wrapper} }
+
+// Block returns the name and the body of the block defined by the statement
+func (stmt *BlockStmt) Block() (string, *nodes.Wrapper) { return stmt.Name, stmt.Wrapper }
+
+func (stmt *BlockStmt) Analyze(a *meta.Analysis) {
+	scope := a.TemplateScope
+	if stmt.Scoped {
+		scope = a.Scope
+	}
+	scope(func() {
+		a.Declare("super")
+		a.Wrapper(a.Template.Blocks[stmt.Name])
+	})
+}
+
 func blockParser(p *parser.Parser, args *parser.Parser) (nodes.Statement, error) {
 	block := &BlockStmt{
 		Location: p.Current(),
@@ -86,18 +134,42 @@ func blockParser(p *parser.Parser, args *parser.Parser) (nodes.Statement, error)
 		return nil, errors.New("First argument for tag 'block' must be an identifier.")
 	}
 
-	if !args.End() {
-		return nil, errors.New("Tag 'block' takes exactly 1 argument (an identifier).")
+	for !args.End() {
+		modifier := args.Match(tokens.Name)
+		switch {
+		case modifier == nil:
+			return nil, errors.New("Tag 'block' only takes an identifier and the 'scoped' and 'required' modifiers.")
+		case modifier.Val == "scoped" && !block.Scoped:
+			block.Scoped = true
+		case modifier.Val == "required" && !block.Required:
+			block.Required = true
+		default:
+			return nil, args.Error(fmt.Sprintf("Unexpected block modifier '%s'.", modifier.Val), modifier)
+		}
 	}
 
 	wrapper, endargs, err := p.WrapUntil("endblock")
 	if err != nil {
 		return nil, err
 	}
+	if block.Required {
+		// Required blocks are only placeholders
+		for _, node := range wrapper.Nodes {
+			switch n := node.(type) {
+			case *nodes.Comment:
+				continue
+			case *nodes.Data:
+				if strings.TrimSpace(n.Data.Val) == "" {
+					continue
+				}
+			}
+			return nil, p.Error(fmt.Sprintf("Required block '%s' can only contain whitespaces and comments.", name.Val), node.Position())
+		}
+	}
 	if !endargs.End() {
 		endName := endargs.Match(tokens.Name)
 		if endName != nil {
-			if endName.Val != endName.Val {
+			if endName.Val != name.Val {
 				return nil, errors.Errorf(`Name for 'endblock' must equal to 'block'-tag's name ('%s' != '%s').`,
 					name.Val, endName.Val)
 			}
@@ -110,14 +182,19 @@ func blockParser(p *parser.Parser, args *parser.Parser) (nodes.Statement, error)
 
 	if !p.Template.Blocks.Exists(name.Val) {
 		p.Template.Blocks.Register(name.Val, wrapper)
+		if block.Required {
+			p.Template.Required[name.Val] = true
+		}
 	} else {
 		return nil, args.Error(fmt.Sprintf("Block named '%s' already defined", name.Val), nil)
 	}
 
 	block.Name = name.Val
+	block.Wrapper = wrapper
 	return block, nil
 }
 
 func init() {
 	All.Register("block", blockParser)
+	exec.RegisterNodes(&BlockStmt{})
 }
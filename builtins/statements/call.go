@@ -0,0 +1,118 @@
+package statements
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/paradime-io/gonja/exec"
+	"github.com/paradime-io/gonja/meta"
+	"github.com/paradime-io/gonja/nodes"
+	"github.com/paradime-io/gonja/parser"
+	"github.com/paradime-io/gonja/tokens"
+)
+
+// CallStmt calls a macro passing its body as 'caller'
+type CallStmt struct {
+	Location *tokens.Token
+	Call     *nodes.Call
+	Caller   *nodes.Macro
+}
+
+func (stmt *CallStmt) Position() *tokens.Token { return stmt.Location }
+func (stmt *CallStmt) String() string {
+	t := stmt.Position()
+	return fmt.Sprintf("CallStmt(Call=%s Line=%d Col=%d)", stmt.Call, t.Line, t.Col)
+}
+
+func (stmt *CallStmt) Execute(r *exec.Renderer, tag *nodes.StatementBlock) error {
+	// The caller body is evaluated in the scope of the call block
+	caller, err := exec.MacroNodeToFunc(stmt.Caller, r)
+	if err != nil {
+		return errors.Wrap(err, `Unable to parse caller`)
+	}
+
+	sub := r.Inherit()
+	sub.Ctx.Set(exec.CallerName, caller)
+
+	call := *stmt.Call
+	call.Kwargs = map[string]nodes.Expression{}
+	for key, value := range stmt.Call.Kwargs {
+		call.Kwargs[key] = value
+	}
+	call.Kwargs[exec.CallerName] = &nodes.Name{Name: &tokens.Token{
+		Type: tokens.Name,
+		Val:  exec.CallerName,
+		Pos:  stmt.Location.Pos,
+		Line: stmt.Location.Line,
+		Col:  stmt.Location.Col,
+	}}
+
+	value := sub.Eval(&call)
+	if value.IsError() {
+		return errors.Wrapf(value, `Unable to call '%s'`, stmt.Call.Func)
+	}
+	r.RenderValue(value)
+	return nil
+}
+
+func (stmt *CallStmt) Children() []nodes.Node { return []nodes.Node{stmt.Call, stmt.Caller} }
+
+func (stmt *CallStmt) Analyze(a *meta.Analysis) {
+	a.Expression(stmt.Call)
+	a.Macro(stmt.Caller)
+}
+
+func callParser(p *parser.Parser, args *parser.Parser) (nodes.Statement, error) {
+	stmt := &CallStmt{
+		Location: p.Current(),
+	}
+	stmt.Caller = &nodes.Macro{
+		Location: stmt.Location,
+		Name:     exec.CallerName,
+		Args:     []string{},
+		Kwargs:   []*nodes.Pair{},
+	}
+
+	// Optional caller signature
+	if args.Match(tokens.Lparen) != nil {
+		if err := parseMacroArgs(args, stmt.Caller); err != nil {
+			return nil, err
+		}
+	}
+
+	expr, err := args.ParseExpression()
+	if err != nil {
+		return nil, err
+	}
+	call, ok := expr.(*nodes.Call)
+	if !ok {
+		return nil, args.Error("Call-tag expects a macro call.", expr.Position())
+	}
+	stmt.Call = call
+
+	if !args.End() {
+		return nil, args.Error("Malformed call-tag.", nil)
+	}
+
+	// Body wrapping, the caller is a macro so loops do not extend into it
+	loopLevel := p.LoopLevel
+	p.LoopLevel = 0
+	wrapper, endargs, err := p.WrapUntil("endcall")
+	p.LoopLevel = loopLevel
+	if err != nil {
+		return nil, err
+	}
+	stmt.Caller.Wrapper = wrapper
+
+	if !endargs.End() {
+		return nil, endargs.Error("Arguments not allowed here.", nil)
+	}
+
+	return stmt, nil
+}
+
+func init() {
+	All.Register("call", callParser)
+	exec.RegisterNodes(&CallStmt{})
+}
@@ -0,0 +1,66 @@
+package statements
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/paradime-io/gonja/exec"
+	"github.com/paradime-io/gonja/meta"
+	"github.com/paradime-io/gonja/nodes"
+	"github.com/paradime-io/gonja/parser"
+	"github.com/paradime-io/gonja/tokens"
+)
+
+// DoStmt evaluates an expression for its side effects, discarding its result
+type DoStmt struct {
+	Location   *tokens.Token
+	Expression nodes.Expression
+}
+
+func (stmt *DoStmt) Position() *tokens.Token { return stmt.Location }
+func (stmt *DoStmt) String() string {
+	t := stmt.Position()
+	return fmt.Sprintf("DoStmt(Line=%d Col=%d)", t.Line, t.Col)
+}
+
+func (stmt *DoStmt) Execute(r *exec.Renderer, tag *nodes.StatementBlock) error {
+	value := r.Eval(stmt.Expression)
+	if value.IsError() {
+		return errors.Wrapf(value, `Unable to evaluate %s`, stmt.Expression)
+	}
+	return nil
+}
+
+func (stmt *DoStmt) Children() []nodes.Node { return nodes.Append(nil, stmt.Expression) }
+
+func (stmt *DoStmt) Analyze(a *meta.Analysis) {
+	a.Expression(stmt.Expression)
+}
+
+func doParser(p *parser.Parser, args *parser.Parser) (nodes.Statement, error) {
+	stmt := &DoStmt{
+		Location: p.Current(),
+	}
+
+	if args.End() {
+		return nil, args.Error("Tag 'do' expects an expression.", nil)
+	}
+
+	expr, err := args.ParseExpressionWithInlineIfs()
+	if err != nil {
+		return nil, err
+	}
+	stmt.Expression = expr
+
+	if !args.End() {
+		return nil, args.Error("Malformed 'do'-tag args.", args.Current())
+	}
+
+	return stmt, nil
+}
+
+func init() {
+	All.Register("do", doParser)
+	exec.RegisterNodes(&DoStmt{})
+}
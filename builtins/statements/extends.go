@@ -2,19 +2,23 @@ package statements
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/pkg/errors"
 
 	"github.com/paradime-io/gonja/exec"
+	"github.com/paradime-io/gonja/loaders"
+	"github.com/paradime-io/gonja/meta"
 	"github.com/paradime-io/gonja/nodes"
 	"github.com/paradime-io/gonja/parser"
 	"github.com/paradime-io/gonja/tokens"
 )
 
 type ExtendsStmt struct {
-	Location    *tokens.Token
-	Filename    string
-	WithContext bool
+	Location     *tokens.Token
+	Filename     string
+	FilenameExpr nodes.Expression // resolved at rendering when not a literal
+	WithContext  bool
 }
 
 func (stmt *ExtendsStmt) Position() *tokens.Token { return stmt.Location }
@@ -27,6 +31,78 @@ func (node *ExtendsStmt) Execute(r *exec.Renderer) error {
 	return nil
 }
 
+// ResolveParent evaluates the parent template name(s) of a dynamic extends.
+// When given a list, the first existing template is used.
+func (node *ExtendsStmt) ResolveParent(r *exec.Renderer) (*nodes.Template, error) {
+	if node.FilenameExpr == nil {
+		return nil, nil
+	}
+	value := r.Eval(node.FilenameExpr)
+	if value.IsError() {
+		return nil, errors.Wrap(value, `Unable to evaluate parent template name`)
+	}
+	names := []string{}
+	if value.IsList() {
+		value.Iterate(func(idx, count int, key, _ *exec.Value) bool {
+			names = append(names, key.String())
+			return true
+		}, func() {})
+	} else {
+		names = append(names, value.String())
+	}
+	return selectTemplate(names, func(name string) (*nodes.Template, error) {
+		return r.GetTemplate(r.ResolveTemplate(r.Current.Name, name))
+	})
+}
+
+// selectTemplate loads the first existing template among names.
+// Only missing templates are skipped, not the ones failing to parse or denied by a sandbox.
+func selectTemplate(names []string, load parser.TemplateParser) (*nodes.Template, error) {
+	if len(names) == 0 {
+		return nil, errors.New(`No parent template given`)
+	}
+	var err error
+	for _, name := range names {
+		var tpl *nodes.Template
+		tpl, err = load(name)
+		if err == nil {
+			return tpl, nil
+		}
+		if !loaders.IsNotFound(err) {
+			break
+		}
+	}
+	if len(names) == 1 {
+		return nil, errors.Wrapf(err, `Unable to parse parent template '%s'`, names[0])
+	}
+	return nil, errors.Wrapf(err, `Unable to parse any parent template of '%s'`, strings.Join(names, "', '"))
+}
+
+// literalNames returns the template names of a string or list of strings literal
+func literalNames(expr nodes.Expression) ([]string, bool) {
+	switch n := expr.(type) {
+	case *nodes.String:
+		return []string{n.Val}, true
+	case *nodes.List:
+		names := []string{}
+		for _, item := range n.Val {
+			str, ok := item.(*nodes.String)
+			if !ok {
+				return nil, false
+			}
+			names = append(names, str.Val)
+		}
+		return names, true
+	}
+	return nil, false
+}
+
+func (stmt *ExtendsStmt) Children() []nodes.Node { return nodes.Append(nil, stmt.FilenameExpr) }
+
+func (stmt *ExtendsStmt) Analyze(a *meta.Analysis) {
+	a.Reference("extends", stmt.Filename, stmt.FilenameExpr)
+}
+
 func extendsParser(p *parser.Parser, args *parser.Parser) (nodes.Statement, error) {
 	stmt := &ExtendsStmt{
 		Location: p.Current(),
@@ -36,20 +112,25 @@ func extendsParser(p *parser.Parser, args *parser.Parser) (nodes.Statement, erro
 		return nil, args.Error(`The 'extends' statement can only be defined at root level`, p.Current())
 	}
 
-	if p.Template.Parent != nil {
+	if p.Template.Parent != nil || hasExtends(p.Template) {
 		return nil, args.Error("This template has already one parent.", args.Current())
 	}
 
-	// var filename nodes.Node
-	if filename := args.Match(tokens.String); filename != nil {
-		stmt.Filename = filename.Val
-		tpl, err := p.TemplateParser(stmt.Filename)
+	expr, err := args.ParseExpressionWithInlineIfs()
+	if err != nil {
+		return nil, errors.Wrap(err, "Tag 'extends' requires a template filename.")
+	}
+
+	// Literals are resolved once for all, others on each rendering
+	if names, ok := literalNames(expr); ok {
+		tpl, err := selectTemplate(names, p.TemplateParser)
 		if err != nil {
-			return nil, errors.Wrapf(err, `Unable to parse parent template '%s'`, stmt.Filename)
+			return nil, err
 		}
+		stmt.Filename = tpl.Name
 		p.Template.Parent = tpl
 	} else {
-		return nil, args.Error("Tag 'extends' requires a template filename as string.", args.Current())
+		stmt.FilenameExpr = expr
 	}
 
 	if tok := args.MatchName("with", "without"); tok != nil {
@@ -67,6 +148,18 @@ func extendsParser(p *parser.Parser, args *parser.Parser) (nodes.Statement, erro
 	return stmt, nil
 }
 
+func hasExtends(tpl *nodes.Template) bool {
+	for _, node := range tpl.Nodes {
+		if block, ok := node.(*nodes.StatementBlock); ok {
+			if _, ok := block.Stmt.(*ExtendsStmt); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func init() {
 	All.Register("extends", extendsParser)
+	exec.RegisterNodes(&ExtendsStmt{})
 }
@@ -10,18 +10,19 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/paradime-io/gonja/exec"
+	"github.com/paradime-io/gonja/meta"
 	"github.com/paradime-io/gonja/nodes"
 	"github.com/paradime-io/gonja/parser"
 	"github.com/paradime-io/gonja/tokens"
 )
 
 type FilterStmt struct {
-	position    *tokens.Token
-	bodyWrapper *nodes.Wrapper
-	filterChain []*nodes.FilterCall
+	Location *tokens.Token
+	Wrapper  *nodes.Wrapper
+	Filters  []*nodes.FilterCall
 }
 
-func (stmt *FilterStmt) Position() *tokens.Token { return stmt.position }
+func (stmt *FilterStmt) Position() *tokens.Token { return stmt.Location }
 func (stmt *FilterStmt) String() string {
 	t := stmt.Position()
 	return fmt.Sprintf("FilterStmt(Line=%d Col=%d)", t.Line, t.Col)
@@ -33,14 +34,14 @@ func (node *FilterStmt) Execute(r *exec.Renderer, tag *nodes.StatementBlock) err
 	sub.Out = &out
 	// temp := bytes.NewBuffer(make([]byte, 0, 1024)) // 1 KiB size
 
-	err := sub.ExecuteWrapper(node.bodyWrapper)
+	err := sub.ExecuteWrapper(node.Wrapper)
 	if err != nil {
 		return err
 	}
 
 	value := exec.AsValue(out.String())
 
-	for _, call := range node.filterChain {
+	for _, call := range node.Filters {
 		value = r.Evaluator().ExecuteFilter(call, value)
 		if value.IsError() {
 			return errors.Wrapf(value, `Unable to apply filter %s (Line: %d Col: %d, near %s`,
@@ -53,16 +54,29 @@ func (node *FilterStmt) Execute(r *exec.Renderer, tag *nodes.StatementBlock) err
 	return nil
 }
 
+func (node *FilterStmt) Children() []nodes.Node {
+	children := make([]nodes.Node, 0, len(node.Filters)+1)
+	for _, call := range node.Filters {
+		children = append(children, call)
+	}
+	return append(children, node.Wrapper)
+}
+
+func (node *FilterStmt) Analyze(a *meta.Analysis) {
+	a.Filters(node.Filters)
+	a.Scope(func() { a.Wrapper(node.Wrapper) })
+}
+
 func filterParser(p *parser.Parser, args *parser.Parser) (nodes.Statement, error) {
 	stmt := &FilterStmt{
-		position: p.Current(),
+		Location: p.Current(),
 	}
 
 	wrapper, _, err := p.WrapUntil("endfilter")
 	if err != nil {
 		return nil, err
 	}
-	stmt.bodyWrapper = wrapper
+	stmt.Wrapper = wrapper
 
 	for !args.End() {
 		filterCall, err := args.ParseFilter()
@@ -70,7 +84,7 @@ func filterParser(p *parser.Parser, args *parser.Parser) (nodes.Statement, error
 			return nil, err
 		}
 
-		stmt.filterChain = append(stmt.filterChain, filterCall)
+		stmt.Filters = append(stmt.Filters, filterCall)
 
 		if args.Match(tokens.Pipe) == nil {
 			break
@@ -86,4 +100,5 @@ func filterParser(p *parser.Parser, args *parser.Parser) (nodes.Statement, error
 
 func init() {
 	All.Register("filter", filterParser)
+	exec.RegisterNodes(&FilterStmt{})
 }
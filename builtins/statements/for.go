@@ -3,168 +3,309 @@ package statements
 import (
 	"fmt"
 	"math"
+	"strings"
+
+	"github.com/pkg/errors"
 
 	"github.com/paradime-io/gonja/exec"
+	"github.com/paradime-io/gonja/meta"
 	"github.com/paradime-io/gonja/nodes"
 	"github.com/paradime-io/gonja/parser"
 	"github.com/paradime-io/gonja/tokens"
 )
 
 type ForStmt struct {
-	key             string
-	value           string // only for maps: for key, value in map
-	objectEvaluator nodes.Expression
-	ifCondition     nodes.Expression
-
-	bodyWrapper  *nodes.Wrapper
-	emptyWrapper *nodes.Wrapper
+	Key       string
+	Value     string // only for maps: for key, value in map
+	Iterable  nodes.Expression
+	Condition nodes.Expression
+	Recursive bool
+
+	Body  *nodes.Wrapper
+	Empty *nodes.Wrapper // the 'else' body
 }
 
-func (stmt *ForStmt) Position() *tokens.Token { return stmt.bodyWrapper.Position() }
+func (stmt *ForStmt) Position() *tokens.Token { return stmt.Body.Position() }
 func (stmt *ForStmt) String() string {
 	t := stmt.Position()
 	return fmt.Sprintf("ForStmt(Line=%d Col=%d)", t.Line, t.Col)
 }
 
+// LoopInfos is the 'loop' object exposed within a for loop body
 type LoopInfos struct {
-	index      int
-	index0     int
-	revindex   int
-	revindex0  int
-	first      bool
-	last       bool
-	length     int
-	depth      int
-	depth0     int
-	PrevItem   *exec.Value
-	NextItem   *exec.Value
-	_lastValue *exec.Value
+	Index     int
+	Index0    int
+	RevIndex  int
+	RevIndex0 int
+	First     bool
+	Last      bool
+	Length    int
+	Depth     int
+	Depth0    int
+	PrevItem  *exec.Value // nil on the first iteration
+	NextItem  *exec.Value // nil on the last iteration
+
+	lastChanged []*exec.Value
+	recurse     func(items *exec.Value) *exec.Value
+	truncated   bool // items beyond the loop iterations limit weren't collected
 }
 
+// Getattr exposes the loop attributes under their Jinja names,
+// along with the Go names of the historically exported ones
+func (li *LoopInfos) Getattr(name string) (*exec.Value, bool) {
+	switch name {
+	case "index":
+		return exec.AsValue(li.Index), true
+	case "index0":
+		return exec.AsValue(li.Index0), true
+	case "revindex", "revindex0", "length":
+		if li.truncated {
+			return exec.AsValue(errors.Errorf("loop.%s is unknown as the loop exceeds the loop iterations limit", name)), true
+		}
+		switch name {
+		case "revindex":
+			return exec.AsValue(li.RevIndex), true
+		case "revindex0":
+			return exec.AsValue(li.RevIndex0), true
+		}
+		return exec.AsValue(li.Length), true
+	case "first":
+		return exec.AsValue(li.First), true
+	case "last":
+		return exec.AsValue(li.Last), true
+	case "depth":
+		return exec.AsValue(li.Depth), true
+	case "depth0":
+		return exec.AsValue(li.Depth0), true
+	case "previtem", "PrevItem":
+		if li.PrevItem != nil {
+			return li.PrevItem, true
+		}
+	case "nextitem", "NextItem":
+		if li.NextItem != nil {
+			return li.NextItem, true
+		}
+	case "cycle", "Cycle":
+		return exec.AsValue(li.Cycle), true
+	case "changed", "Changed":
+		return exec.AsValue(li.Changed), true
+	}
+	return exec.AsValue(nil), false
+}
+
+// Cycle returns its arguments in turn, one per iteration
 func (li *LoopInfos) Cycle(va *exec.VarArgs) *exec.Value {
-	return va.Args[int(math.Mod(float64(li.index0), float64(len(va.Args))))]
+	if len(va.Args) == 0 {
+		return exec.AsValue(errors.New("no items for cycling given"))
+	}
+	return va.Args[int(math.Mod(float64(li.Index0), float64(len(va.Args))))]
 }
 
-func (li *LoopInfos) Changed(value *exec.Value) bool {
-	same := li._lastValue != nil && value.EqualValueTo(li._lastValue)
-	li._lastValue = value
+// Changed is true on the first call and then whenever
+// the given values differ from the previous call ones
+func (li *LoopInfos) Changed(va *exec.VarArgs) bool {
+	same := li.lastChanged != nil && len(li.lastChanged) == len(va.Args)
+	for idx := 0; same && idx < len(va.Args); idx++ {
+		same = va.Args[idx].EqualValueTo(li.lastChanged[idx])
+	}
+	li.lastChanged = va.Args
 	return !same
 }
 
-func (node *ForStmt) Execute(r *exec.Renderer, tag *nodes.StatementBlock) (forError error) {
-	obj := r.Eval(node.objectEvaluator)
+// Call renders the body of a recursive loop over the given items
+func (li *LoopInfos) Call(va *exec.VarArgs) *exec.Value {
+	if li.recurse == nil {
+		return exec.AsValue(errors.New("Tried to call a non recursive loop"))
+	}
+	if len(va.Args) != 1 || len(va.KwArgs) > 0 {
+		return exec.AsValue(errors.Errorf("loop() expects exactly 1 argument, got %d", len(va.Args)+len(va.KwArgs)))
+	}
+	return li.recurse(va.Args[0])
+}
+
+func (node *ForStmt) Execute(r *exec.Renderer, tag *nodes.StatementBlock) error {
+	obj := r.Eval(node.Iterable)
 	if obj.IsError() {
 		return obj
 	}
+	return node.iterate(r, tag, obj, 1)
+}
 
+// iterate renders the loop over obj at the given depth (starting at 1)
+func (node *ForStmt) iterate(r *exec.Renderer, tag *nodes.StatementBlock, obj *exec.Value, depth int) (forError error) {
 	// Create loop struct
 	items := exec.NewDict()
+	// Only rendered iterations are counted, but items are collected lazily:
+	// one more than the remaining iterations is enough to exceed the limit
+	remaining := r.RemainingIterations()
+	truncated := false
 
 	// First iteration: filter values to ensure proper LoopInfos
 	obj.Iterate(func(idx, count int, key, value *exec.Value) bool {
+		if forError = r.Cancelled(); forError != nil {
+			return false
+		}
+		if remaining >= 0 && len(items.Pairs) > remaining {
+			truncated = true
+			return false
+		}
 		sub := r.Inherit()
 		ctx := sub.Ctx
 		pair := &exec.Pair{}
 
 		// There's something to iterate over (correct type and at least 1 item)
 		// Update loop infos and public context
-		if node.value != "" && !key.IsString() && key.Len() == 2 {
+		if node.Value != "" && !key.IsString() && key.Len() == 2 {
 			key.Iterate(func(idx, count int, key, value *exec.Value) bool {
 				switch idx {
 				case 0:
-					ctx.Set(node.key, key)
+					ctx.Set(node.Key, key)
 					pair.Key = key
 				case 1:
-					ctx.Set(node.value, key)
+					ctx.Set(node.Value, key)
 					pair.Value = key
 				}
 				return true
 			}, func() {})
 		} else {
-			ctx.Set(node.key, key)
+			ctx.Set(node.Key, key)
 			pair.Key = key
 			if value != nil {
-				ctx.Set(node.value, value)
+				ctx.Set(node.Value, value)
 				pair.Value = value
 			}
 		}
 
-		if node.ifCondition != nil {
-			if !sub.Eval(node.ifCondition).IsTrue() {
+		if node.Condition != nil {
+			condition := sub.Eval(node.Condition)
+			if condition.IsError() && exec.IsUndefinedError(condition) {
+				forError = condition
+				return false
+			}
+			if !condition.IsTrue() {
 				return true
 			}
 		}
 		items.Pairs = append(items.Pairs, pair)
 		return true
-	}, func() {
-		// Nothing to iterate over (maybe wrong type or no items)
-		if node.emptyWrapper != nil {
+	}, func() {})
+
+	if forError != nil {
+		return forError
+	}
+
+	// Nothing to iterate over (maybe wrong type, no items or all filtered out)
+	if len(items.Pairs) == 0 {
+		if node.Empty != nil {
 			sub := r.Inherit()
-			err := sub.ExecuteWrapper(node.emptyWrapper)
-			if err != nil {
-				forError = err
-			}
+			return sub.ExecuteWrapper(node.Empty)
 		}
-	})
+		return nil
+	}
 
 	// 2nd pass: all values are defined, render
 	length := len(items.Pairs)
 	loop := &LoopInfos{
-		first:  true,
-		index0: -1,
+		Length:    length,
+		Depth:     depth,
+		Depth0:    depth - 1,
+		truncated: truncated,
+	}
+	if node.Recursive {
+		loop.recurse = func(children *exec.Value) *exec.Value {
+			if err := r.PushRecursion(node.Position()); err != nil {
+				return exec.AsValue(err)
+			}
+			defer r.PopRecursion()
+
+			var out strings.Builder
+			sub := r.Inherit()
+			sub.Out = &out
+			if err := node.iterate(sub, tag, children, depth+1); err != nil {
+				return exec.AsValue(err)
+			}
+			return exec.AsSafeValue(out.String())
+		}
 	}
 	for idx, pair := range items.Pairs {
+		if err := r.Cancelled(); err != nil {
+			return err
+		}
+		if err := r.CountIteration(node.Position()); err != nil {
+			return err
+		}
 		r.EndTag(tag.Trim)
 		sub := r.Inherit()
 		ctx := sub.Ctx
 
-		ctx.Set(node.key, pair.Key)
+		ctx.Set(node.Key, pair.Key)
 		if pair.Value != nil {
-			ctx.Set(node.value, pair.Value)
+			ctx.Set(node.Value, pair.Value)
 		}
 
 		ctx.Set("loop", loop)
-		loop.index0 = idx
-		loop.index = loop.index0 + 1
-		if idx == 1 {
-			loop.first = false
-		}
-		if idx+1 == length {
-			loop.last = true
-		}
-		loop.revindex = length - idx
-		loop.revindex0 = length - (idx + 1)
+		loop.Index0 = idx
+		loop.Index = idx + 1
+		loop.First = idx == 0
+		loop.Last = idx+1 == length
+		loop.RevIndex = length - idx
+		loop.RevIndex0 = length - (idx + 1)
 
 		if idx == 0 {
-			loop.PrevItem = exec.AsValue(nil)
+			loop.PrevItem = nil
 		} else {
-			pp := items.Pairs[idx-1]
-			if pp.Value != nil {
-				loop.PrevItem = exec.AsValue([2]*exec.Value{pp.Key, pp.Value})
-			} else {
-				loop.PrevItem = pp.Key
-			}
+			loop.PrevItem = pairItem(items.Pairs[idx-1])
 		}
 
 		if idx == length-1 {
-			loop.NextItem = exec.AsValue(nil)
+			loop.NextItem = nil
 		} else {
-			np := items.Pairs[idx+1]
-			if np.Value != nil {
-				loop.NextItem = exec.AsValue([2]*exec.Value{np.Key, np.Value})
-			} else {
-				loop.NextItem = np.Key
-			}
+			loop.NextItem = pairItem(items.Pairs[idx+1])
 		}
 
 		// Render elements with updated context
-		err := sub.ExecuteWrapper(node.bodyWrapper)
+		err := sub.ExecuteWrapper(node.Body)
 		if err != nil {
-			return err
+			switch errors.Cause(err) {
+			case errBreak:
+				return nil
+			case errContinue:
+				continue
+			default:
+				return err
+			}
 		}
 	}
 
-	return forError
+	return nil
+}
+
+// pairItem returns the loop item as seen from the template
+func pairItem(pair *exec.Pair) *exec.Value {
+	if pair.Value != nil {
+		return exec.AsValue([2]*exec.Value{pair.Key, pair.Value})
+	}
+	return pair.Key
+}
+
+func (node *ForStmt) Children() []nodes.Node {
+	children := nodes.Append(nil, node.Iterable, node.Condition)
+	children = append(children, node.Body)
+	if node.Empty != nil {
+		children = append(children, node.Empty)
+	}
+	return children
+}
+
+// Analyze declares the loop variables within the body, the else body not seeing them
+func (node *ForStmt) Analyze(a *meta.Analysis) {
+	a.Expression(node.Iterable)
+	a.Scope(func() {
+		a.Declare(node.Key, node.Value, "loop")
+		a.Expression(node.Condition)
+		a.Wrapper(node.Body)
+	})
+	a.Scope(func() { a.Wrapper(node.Empty) })
 }
 
 func forParser(p *parser.Parser, args *parser.Parser) (nodes.Statement, error) {
@@ -193,10 +334,10 @@ func forParser(p *parser.Parser, args *parser.Parser) (nodes.Statement, error) {
 	if err != nil {
 		return nil, err
 	}
-	stmt.objectEvaluator = objectEvaluator
-	stmt.key = keyToken.Val
+	stmt.Iterable = objectEvaluator
+	stmt.Key = keyToken.Val
 	if valueToken != nil {
-		stmt.value = valueToken.Val
+		stmt.Value = valueToken.Val
 	}
 
 	if args.MatchName("if") != nil {
@@ -204,7 +345,11 @@ func forParser(p *parser.Parser, args *parser.Parser) (nodes.Statement, error) {
 		if err != nil {
 			return nil, err
 		}
-		stmt.ifCondition = ifCondition
+		stmt.Condition = ifCondition
+	}
+
+	if args.MatchName("recursive") != nil {
+		stmt.Recursive = true
 	}
 
 	if !args.End() {
@@ -212,11 +357,13 @@ func forParser(p *parser.Parser, args *parser.Parser) (nodes.Statement, error) {
 	}
 
 	// Body wrapping
+	p.LoopLevel++
 	wrapper, endargs, err := p.WrapUntil("else", "endfor")
+	p.LoopLevel--
 	if err != nil {
 		return nil, err
 	}
-	stmt.bodyWrapper = wrapper
+	stmt.Body = wrapper
 
 	if !endargs.End() {
 		return nil, endargs.Error("Arguments not allowed here.", nil)
@@ -228,7 +375,7 @@ func forParser(p *parser.Parser, args *parser.Parser) (nodes.Statement, error) {
 		if err != nil {
 			return nil, err
 		}
-		stmt.emptyWrapper = wrapper
+		stmt.Empty = wrapper
 
 		if !endargs.End() {
 			return nil, endargs.Error("Arguments not allowed here.", nil)
@@ -240,4 +387,5 @@ func forParser(p *parser.Parser, args *parser.Parser) (nodes.Statement, error) {
 
 func init() {
 	All.Register("for", forParser)
+	exec.RegisterNodes(&ForStmt{})
 }
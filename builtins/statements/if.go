@@ -6,6 +6,7 @@ import (
 	log "github.com/sirupsen/logrus"
 
 	"github.com/paradime-io/gonja/exec"
+	"github.com/paradime-io/gonja/meta"
 	"github.com/paradime-io/gonja/nodes"
 	"github.com/paradime-io/gonja/parser"
 	"github.com/paradime-io/gonja/tokens"
@@ -43,6 +44,42 @@ func (node *IfStmt) Execute(r *exec.Renderer, tag *nodes.StatementBlock) error {
 	return nil
 }
 
+func (node *IfStmt) Children() []nodes.Node {
+	children := []nodes.Node{}
+	for idx, wrapper := range node.Wrappers {
+		if idx < len(node.Conditions) {
+			children = nodes.Append(children, node.Conditions[idx])
+		}
+		children = append(children, wrapper)
+	}
+	return children
+}
+
+// Analyze declares the variables set by every branch, provided there is an else branch
+func (node *IfStmt) Analyze(a *meta.Analysis) {
+	var declared map[string]bool
+	for idx, wrapper := range node.Wrappers {
+		if idx < len(node.Conditions) {
+			a.Expression(node.Conditions[idx])
+		}
+		branch := a.Scope(func() { a.Wrapper(wrapper) })
+		if declared == nil {
+			declared = branch
+			continue
+		}
+		for name := range declared {
+			if !branch[name] {
+				delete(declared, name)
+			}
+		}
+	}
+	if len(node.Wrappers) > len(node.Conditions) {
+		for name := range declared {
+			a.Declare(name)
+		}
+	}
+}
+
 func ifParser(p *parser.Parser, args *parser.Parser) (nodes.Statement, error) {
 	log.WithFields(log.Fields{
 		"arg":     args.Current(),
@@ -99,4 +136,5 @@ func ifParser(p *parser.Parser, args *parser.Parser) (nodes.Statement, error) {
 
 func init() {
 	All.Register("if", ifParser)
+	exec.RegisterNodes(&IfStmt{})
 }
@@ -6,6 +6,7 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/paradime-io/gonja/exec"
+	"github.com/paradime-io/gonja/meta"
 	"github.com/paradime-io/gonja/nodes"
 	"github.com/paradime-io/gonja/parser"
 	"github.com/paradime-io/gonja/tokens"
@@ -28,6 +29,8 @@ func (stmt *ImportStmt) String() string {
 func (stmt *ImportStmt) Execute(r *exec.Renderer, tag *nodes.StatementBlock) error {
 	var imported map[string]*nodes.Macro
 	macros := map[string]exec.Macro{}
+	// Macros are located in the imported template
+	sub := r.InheritWithoutNewScope()
 
 	if stmt.FilenameExpr != nil {
 		filenameValue := r.Eval(stmt.FilenameExpr)
@@ -36,18 +39,20 @@ func (stmt *ImportStmt) Execute(r *exec.Renderer, tag *nodes.StatementBlock) err
 		}
 
 		filename := filenameValue.String()
-		tpl, err := r.Loader.GetTemplate(filename)
+		tpl, err := r.Loader.GetTemplate(r.ResolveTemplate(r.Current.Name, filename))
 		if err != nil {
 			return errors.Wrapf(err, `Unable to load template '%s'`, filename)
 		}
 		imported = tpl.Root.Macros
+		sub.Current = tpl.Root
 
 	} else {
 		imported = stmt.Template.Macros
+		sub.Current = stmt.Template
 	}
 
 	for name, macro := range imported {
-		fn, err := exec.MacroNodeToFunc(macro, r)
+		fn, err := exec.MacroNodeToFunc(macro, sub)
 		if err != nil {
 			return errors.Wrapf(err, `Unable to import macro '%s'`, name)
 		}
@@ -75,6 +80,8 @@ func (stmt *FromImportStmt) String() string {
 }
 func (stmt *FromImportStmt) Execute(r *exec.Renderer, tag *nodes.StatementBlock) error {
 	var imported map[string]*nodes.Macro
+	// Macros are located in the imported template
+	sub := r.InheritWithoutNewScope()
 
 	if stmt.FilenameExpr != nil {
 		filenameValue := r.Eval(stmt.FilenameExpr)
@@ -83,19 +90,21 @@ func (stmt *FromImportStmt) Execute(r *exec.Renderer, tag *nodes.StatementBlock)
 		}
 
 		filename := filenameValue.String()
-		tpl, err := r.Loader.GetTemplate(filename)
+		tpl, err := r.Loader.GetTemplate(r.ResolveTemplate(r.Current.Name, filename))
 		if err != nil {
 			return errors.Wrapf(err, `Unable to load template '%s'`, filename)
 		}
 		imported = tpl.Root.Macros
+		sub.Current = tpl.Root
 
 	} else {
 		imported = stmt.Template.Macros
+		sub.Current = stmt.Template
 	}
 
 	for alias, name := range stmt.As {
 		node := imported[name]
-		fn, err := exec.MacroNodeToFunc(node, r)
+		fn, err := exec.MacroNodeToFunc(node, sub)
 		if err != nil {
 			return errors.Wrapf(err, `Unable to import macro '%s'`, name)
 		}
@@ -104,6 +113,13 @@ func (stmt *FromImportStmt) Execute(r *exec.Renderer, tag *nodes.StatementBlock)
 	return nil
 }
 
+func (stmt *ImportStmt) Children() []nodes.Node { return nodes.Append(nil, stmt.FilenameExpr) }
+
+func (stmt *ImportStmt) Analyze(a *meta.Analysis) {
+	a.Reference("import", stmt.Filename, stmt.FilenameExpr)
+	a.Declare(stmt.As)
+}
+
 func importParser(p *parser.Parser, args *parser.Parser) (nodes.Statement, error) {
 	stmt := &ImportStmt{
 		Location: p.Current(),
@@ -154,6 +170,15 @@ func importParser(p *parser.Parser, args *parser.Parser) (nodes.Statement, error
 	return stmt, nil
 }
 
+func (stmt *FromImportStmt) Children() []nodes.Node { return nodes.Append(nil, stmt.FilenameExpr) }
+
+func (stmt *FromImportStmt) Analyze(a *meta.Analysis) {
+	a.Reference("from", stmt.Filename, stmt.FilenameExpr)
+	for alias := range stmt.As {
+		a.Declare(alias)
+	}
+}
+
 func fromParser(p *parser.Parser, args *parser.Parser) (nodes.Statement, error) {
 	stmt := &FromImportStmt{
 		Location: p.Current(),
@@ -238,4 +263,5 @@ func fromParser(p *parser.Parser, args *parser.Parser) (nodes.Statement, error)
 func init() {
 	All.Register("import", importParser)
 	All.Register("from", fromParser)
+	exec.RegisterNodes(&ImportStmt{}, &FromImportStmt{})
 }
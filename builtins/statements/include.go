@@ -6,6 +6,8 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/paradime-io/gonja/exec"
+	"github.com/paradime-io/gonja/loaders"
+	"github.com/paradime-io/gonja/meta"
 	"github.com/paradime-io/gonja/nodes"
 	"github.com/paradime-io/gonja/parser"
 	"github.com/paradime-io/gonja/tokens"
@@ -32,6 +34,10 @@ func (stmt *IncludeStmt) Execute(r *exec.Renderer, tag *nodes.StatementBlock) er
 	if stmt.IsEmpty {
 		return nil
 	}
+	if err := r.PushRecursion(stmt.Location); err != nil {
+		return err
+	}
+	defer r.PopRecursion()
 	sub := r.Inherit()
 
 	if stmt.FilenameExpr != nil {
@@ -41,9 +47,9 @@ func (stmt *IncludeStmt) Execute(r *exec.Renderer, tag *nodes.StatementBlock) er
 		}
 
 		filename := filenameValue.String()
-		included, err := r.Loader.GetTemplate(filename)
+		included, err := r.Loader.GetTemplate(r.ResolveTemplate(r.Current.Name, filename))
 		if err != nil {
-			if stmt.IgnoreMissing {
+			if stmt.IgnoreMissing && loaders.IsNotFound(err) {
 				return nil
 			} else {
 				return errors.Wrapf(err, `Unable to load template '%s'`, filename)
@@ -65,6 +71,12 @@ type IncludeEmptyStmt struct{}
 // 	return nil
 // }
 
+func (stmt *IncludeStmt) Children() []nodes.Node { return nodes.Append(nil, stmt.FilenameExpr) }
+
+func (stmt *IncludeStmt) Analyze(a *meta.Analysis) {
+	a.Reference("include", stmt.Filename, stmt.FilenameExpr)
+}
+
 func includeParser(p *parser.Parser, args *parser.Parser) (nodes.Statement, error) {
 	stmt := &IncludeStmt{
 		Location: p.Current(),
@@ -100,7 +112,8 @@ func includeParser(p *parser.Parser, args *parser.Parser) (nodes.Statement, erro
 	if stmt.Filename != "" {
 		tpl, err := p.TemplateParser(stmt.Filename)
 		if err != nil {
-			if stmt.IgnoreMissing {
+			// Only missing templates are ignored, not sandbox violations nor parse errors
+			if stmt.IgnoreMissing && loaders.IsNotFound(err) {
 				stmt.IsEmpty = true
 			} else {
 				return nil, errors.Wrapf(err, `Unable to parse included template '%s'`, stmt.Filename)
@@ -119,4 +132,5 @@ func includeParser(p *parser.Parser, args *parser.Parser) (nodes.Statement, erro
 
 func init() {
 	All.Register("include", includeParser)
+	exec.RegisterNodes(&IncludeStmt{})
 }
@@ -0,0 +1,86 @@
+package statements
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/paradime-io/gonja/exec"
+	"github.com/paradime-io/gonja/meta"
+	"github.com/paradime-io/gonja/nodes"
+	"github.com/paradime-io/gonja/parser"
+	"github.com/paradime-io/gonja/tokens"
+)
+
+// Loop controls stop the rendering of the current loop body
+// and are caught by the enclosing ForStmt.
+var (
+	errBreak    = errors.New("break outside of a loop")
+	errContinue = errors.New("continue outside of a loop")
+)
+
+type BreakStmt struct {
+	Location *tokens.Token
+}
+
+func (stmt *BreakStmt) Position() *tokens.Token { return stmt.Location }
+func (stmt *BreakStmt) String() string {
+	t := stmt.Position()
+	return fmt.Sprintf("BreakStmt(Line=%d Col=%d)", t.Line, t.Col)
+}
+
+func (stmt *BreakStmt) Execute(r *exec.Renderer, tag *nodes.StatementBlock) error {
+	return errBreak
+}
+
+type ContinueStmt struct {
+	Location *tokens.Token
+}
+
+func (stmt *ContinueStmt) Position() *tokens.Token { return stmt.Location }
+func (stmt *ContinueStmt) String() string {
+	t := stmt.Position()
+	return fmt.Sprintf("ContinueStmt(Line=%d Col=%d)", t.Line, t.Col)
+}
+
+func (stmt *ContinueStmt) Execute(r *exec.Renderer, tag *nodes.StatementBlock) error {
+	return errContinue
+}
+
+// Analyze reads and declares no variable
+func (stmt *BreakStmt) Analyze(a *meta.Analysis) {}
+
+// Analyze reads and declares no variable
+func (stmt *ContinueStmt) Analyze(a *meta.Analysis) {}
+
+func breakParser(p *parser.Parser, args *parser.Parser) (nodes.Statement, error) {
+	stmt := &BreakStmt{
+		Location: p.Current(),
+	}
+	if p.LoopLevel <= 0 {
+		return nil, args.Error("'break' can only be used inside a loop.", stmt.Location)
+	}
+	if !args.End() {
+		return nil, args.Error("Tag 'break' does not take any argument.", args.Current())
+	}
+	return stmt, nil
+}
+
+func continueParser(p *parser.Parser, args *parser.Parser) (nodes.Statement, error) {
+	stmt := &ContinueStmt{
+		Location: p.Current(),
+	}
+	if p.LoopLevel <= 0 {
+		return nil, args.Error("'continue' can only be used inside a loop.", stmt.Location)
+	}
+	if !args.End() {
+		return nil, args.Error("Tag 'continue' does not take any argument.", args.Current())
+	}
+	return stmt, nil
+}
+
+func init() {
+	All.Register("break", breakParser)
+	All.Register("continue", continueParser)
+	exec.RegisterNodes(&BreakStmt{}, &ContinueStmt{})
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/paradime-io/gonja/exec"
+	"github.com/paradime-io/gonja/meta"
 	"github.com/paradime-io/gonja/nodes"
 	"github.com/paradime-io/gonja/parser"
 	"github.com/paradime-io/gonja/tokens"
@@ -77,6 +78,11 @@ func (node *MacroStmt) call(ctx *exec.Context, args ...*exec.Value) *exec.Value
 	return nil
 }
 
+func (stmt *MacroStmt) Analyze(a *meta.Analysis) {
+	a.Declare(stmt.Name)
+	a.Macro(stmt.Macro)
+}
+
 func macroParser(p *parser.Parser, args *parser.Parser) (nodes.Statement, error) {
 	stmt := &nodes.Macro{
 		Location: p.Current(),
@@ -93,34 +99,8 @@ func macroParser(p *parser.Parser, args *parser.Parser) (nodes.Statement, error)
 	if args.Match(tokens.Lparen) == nil {
 		return nil, args.Error("Expected '('.", nil)
 	}
-
-	for args.Match(tokens.Rparen) == nil {
-		argName := args.Match(tokens.Name)
-		if argName == nil {
-			return nil, args.Error("Expected argument name as identifier.", nil)
-		}
-
-		if args.Match(tokens.Assign) != nil {
-			// Default expression follows
-			expr, err := args.ParseExpression()
-			if err != nil {
-				return nil, err
-			}
-			stmt.Kwargs = append(stmt.Kwargs, &nodes.Pair{
-				Key:   &nodes.String{argName, argName.Val},
-				Value: expr,
-			})
-			// stmt.Kwargs[argName.Val] = expr
-		} else {
-			stmt.Args = append(stmt.Args, argName.Val)
-		}
-
-		if args.Match(tokens.Rparen) != nil {
-			break
-		}
-		if args.Match(tokens.Comma) == nil {
-			return nil, args.Error("Expected ',' or ')'.", nil)
-		}
+	if err := parseMacroArgs(args, stmt); err != nil {
+		return nil, err
 	}
 
 	// if args.MatchName("export") != nil {
@@ -131,8 +111,11 @@ func macroParser(p *parser.Parser, args *parser.Parser) (nodes.Statement, error)
 		return nil, args.Error("Malformed macro-tag.", nil)
 	}
 
-	// Body wrapping
+	// Body wrapping, loops do not extend into macros
+	loopLevel := p.LoopLevel
+	p.LoopLevel = 0
 	wrapper, endargs, err := p.WrapUntil("endmacro")
+	p.LoopLevel = loopLevel
 	if err != nil {
 		return nil, err
 	}
@@ -156,6 +139,40 @@ func macroParser(p *parser.Parser, args *parser.Parser) (nodes.Statement, error)
 	return &MacroStmt{stmt}, nil
 }
 
+// parseMacroArgs parses a macro signature up to the closing parenthesis,
+// the opening one being already consumed.
+func parseMacroArgs(args *parser.Parser, stmt *nodes.Macro) error {
+	for args.Match(tokens.Rparen) == nil {
+		argName := args.Match(tokens.Name)
+		if argName == nil {
+			return args.Error("Expected argument name as identifier.", nil)
+		}
+
+		if args.Match(tokens.Assign) != nil {
+			// Default expression follows
+			expr, err := args.ParseExpression()
+			if err != nil {
+				return err
+			}
+			stmt.Kwargs = append(stmt.Kwargs, &nodes.Pair{
+				Key:   &nodes.String{argName, argName.Val},
+				Value: expr,
+			})
+		} else {
+			stmt.Args = append(stmt.Args, argName.Val)
+		}
+
+		if args.Match(tokens.Rparen) != nil {
+			break
+		}
+		if args.Match(tokens.Comma) == nil {
+			return args.Error("Expected ',' or ')'.", nil)
+		}
+	}
+	return nil
+}
+
 func init() {
 	All.Register("macro", macroParser)
+	exec.RegisterNodes(&MacroStmt{})
 }
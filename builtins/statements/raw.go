@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/paradime-io/gonja/exec"
+	"github.com/paradime-io/gonja/meta"
 	"github.com/paradime-io/gonja/nodes"
 	"github.com/paradime-io/gonja/parser"
 	"github.com/paradime-io/gonja/tokens"
@@ -20,6 +21,8 @@ func (stmt *RawStmt) String() string {
 	return fmt.Sprintf("RawStmt(Line=%d Col=%d)", t.Line, t.Col)
 }
 
+func (stmt *RawStmt) Children() []nodes.Node { return []nodes.Node{stmt.Data} }
+
 func (stmt *RawStmt) Execute(r *exec.Renderer, tag *nodes.StatementBlock) error {
 	r.WriteString(stmt.Data.Data.Val)
 	// sub := r.Inherit()
@@ -33,6 +36,9 @@ func (stmt *RawStmt) Execute(r *exec.Renderer, tag *nodes.StatementBlock) error
 	return nil
 }
 
+// Analyze reads and declares no variable
+func (stmt *RawStmt) Analyze(a *meta.Analysis) {}
+
 func rawParser(p *parser.Parser, args *parser.Parser) (nodes.Statement, error) {
 	stmt := &RawStmt{}
 
@@ -57,4 +63,5 @@ func rawParser(p *parser.Parser, args *parser.Parser) (nodes.Statement, error) {
 
 func init() {
 	All.Register("raw", rawParser)
+	exec.RegisterNodes(&RawStmt{})
 }
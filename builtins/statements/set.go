@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"github.com/paradime-io/gonja/exec"
+	"github.com/paradime-io/gonja/meta"
 	"github.com/paradime-io/gonja/nodes"
 	"github.com/paradime-io/gonja/parser"
 	"github.com/paradime-io/gonja/tokens"
@@ -65,7 +66,7 @@ func (stmt *SetStmt) Execute(r *exec.Renderer, tag *nodes.StatementBlock) error
 		if n.Arg == nil {
 			return errors.Errorf(`No Arg was given`)
 		} else if err := target.Set(r.Eval(*n.Arg).String(), value.Interface()); err != nil {
-			return errors.Wrapf(err, `Unable to set value on "%s"`, n.Arg)
+			return errors.Wrapf(err, `Unable to set value on "%s"`, *n.Arg)
 		}
 	default:
 		return errors.Errorf(`Illegal set target node %s`, n)
@@ -74,6 +75,26 @@ func (stmt *SetStmt) Execute(r *exec.Renderer, tag *nodes.StatementBlock) error
 	return nil
 }
 
+func (stmt *SetStmt) Children() []nodes.Node {
+	children := nodes.Append(nil, stmt.Target)
+	if stmt.Expression != nil {
+		children = nodes.Append(children, *stmt.Expression)
+	}
+	if stmt.Wrapper != nil {
+		children = append(children, stmt.Wrapper)
+	}
+	return children
+}
+
+func (stmt *SetStmt) Analyze(a *meta.Analysis) {
+	if stmt.Expression != nil {
+		a.Expression(*stmt.Expression)
+	} else {
+		a.Scope(func() { a.Wrapper(stmt.Wrapper) })
+	}
+	a.Target(stmt.Target)
+}
+
 func setParser(p *parser.Parser, args *parser.Parser) (nodes.Statement, error) {
 	stmt := &SetStmt{
 		Location: p.Current(),
@@ -123,4 +144,5 @@ func setParser(p *parser.Parser, args *parser.Parser) (nodes.Statement, error) {
 
 func init() {
 	All.Register("set", setParser)
+	exec.RegisterNodes(&SetStmt{})
 }
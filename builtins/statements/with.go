@@ -6,6 +6,7 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/paradime-io/gonja/exec"
+	"github.com/paradime-io/gonja/meta"
 	"github.com/paradime-io/gonja/nodes"
 	"github.com/paradime-io/gonja/parser"
 	"github.com/paradime-io/gonja/tokens"
@@ -37,6 +38,22 @@ func (stmt *WithStmt) Execute(r *exec.Renderer, tag *nodes.StatementBlock) error
 	return sub.ExecuteWrapper(stmt.Wrapper)
 }
 
+func (stmt *WithStmt) Children() []nodes.Node {
+	return append(nodes.AppendKwargs(nil, stmt.Pairs), stmt.Wrapper)
+}
+
+func (stmt *WithStmt) Analyze(a *meta.Analysis) {
+	names := []string{}
+	for name, expr := range stmt.Pairs {
+		a.Expression(expr)
+		names = append(names, name)
+	}
+	a.Scope(func() {
+		a.Declare(names...)
+		a.Wrapper(stmt.Wrapper)
+	})
+}
+
 func withParser(p *parser.Parser, args *parser.Parser) (nodes.Statement, error) {
 	stmt := &WithStmt{
 		Location: p.Current(),
@@ -81,4 +98,5 @@ func withParser(p *parser.Parser, args *parser.Parser) (nodes.Statement, error)
 
 func init() {
 	All.Register("with", withParser)
+	exec.RegisterNodes(&WithStmt{})
 }
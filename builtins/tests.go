@@ -47,7 +47,7 @@ func testCallable(ctx *exec.Context, in *exec.Value, params *exec.VarArgs) (bool
 }
 
 func testDefined(ctx *exec.Context, in *exec.Value, params *exec.VarArgs) (bool, error) {
-	return !(in.IsError() || in.IsNil()), nil
+	return !(in.IsError() || in.IsUndefined()), nil
 }
 
 func testDivisibleby(ctx *exec.Context, in *exec.Value, params *exec.VarArgs) (bool, error) {
@@ -0,0 +1,102 @@
+package gonja
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/paradime-io/gonja/exec"
+)
+
+// CachedTemplate is a compiled template along with the versions
+// of the sources it has been compiled from (see loaders.Versioner).
+type CachedTemplate struct {
+	Template *exec.Template
+	// Versions by template name, including the statically included,
+	// imported and extended ones
+	Versions map[string]string
+}
+
+// Cache stores the templates compiled by an Environment.
+// Implementations must be safe for concurrent use.
+type Cache interface {
+	Get(name string) (*CachedTemplate, bool)
+	Set(name string, tpl *CachedTemplate)
+	// Delete removes the given templates, all of them if none is given
+	Delete(names ...string)
+}
+
+// LRUCache is a Cache holding a bounded number of templates,
+// evicting the least recently used ones first.
+type LRUCache struct {
+	size    int
+	mutex   sync.Mutex
+	order   *list.List // most recently used first
+	entries map[string]*list.Element
+}
+
+type lruEntry struct {
+	name string
+	tpl  *CachedTemplate
+}
+
+// NewLRUCache returns a cache of size templates. It is unbounded if size <= 0.
+func NewLRUCache(size int) *LRUCache {
+	return &LRUCache{
+		size:    size,
+		order:   list.New(),
+		entries: map[string]*list.Element{},
+	}
+}
+
+func (c *LRUCache) Get(name string) (*CachedTemplate, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	elem, ok := c.entries[name]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*lruEntry).tpl, true
+}
+
+func (c *LRUCache) Set(name string, tpl *CachedTemplate) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if elem, ok := c.entries[name]; ok {
+		elem.Value.(*lruEntry).tpl = tpl
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.entries[name] = c.order.PushFront(&lruEntry{name: name, tpl: tpl})
+	for c.size > 0 && c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry).name)
+	}
+}
+
+func (c *LRUCache) Delete(names ...string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if len(names) == 0 {
+		c.order.Init()
+		c.entries = map[string]*list.Element{}
+		return
+	}
+	for _, name := range names {
+		if elem, ok := c.entries[name]; ok {
+			c.order.Remove(elem)
+			delete(c.entries, name)
+		}
+	}
+}
+
+// Len returns the number of cached templates
+func (c *LRUCache) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.order.Len()
+}
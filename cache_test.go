@@ -0,0 +1,161 @@
+package gonja_test
+
+import (
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/paradime-io/gonja"
+	"github.com/paradime-io/gonja/config"
+	"github.com/paradime-io/gonja/loaders"
+)
+
+// countingLoader counts the templates read from a directory
+type countingLoader struct {
+	*loaders.FilesystemLoader
+	delay time.Duration
+	reads int32
+}
+
+func (l *countingLoader) Get(path string) (io.Reader, error) {
+	atomic.AddInt32(&l.reads, 1)
+	time.Sleep(l.delay)
+	return l.FilesystemLoader.Get(path)
+}
+
+func (l *countingLoader) GetSource(path string) (io.Reader, string, error) {
+	atomic.AddInt32(&l.reads, 1)
+	time.Sleep(l.delay)
+	return l.FilesystemLoader.GetSource(path)
+}
+
+func cacheEnv(t *testing.T, files map[string]string) (*gonja.Environment, *countingLoader, string) {
+	dir := t.TempDir()
+	for name, content := range files {
+		writeTemplate(t, dir, name, content)
+	}
+	loader := &countingLoader{FilesystemLoader: loaders.MustNewFileSystemLoader(dir)}
+	return gonja.NewEnvironment(config.NewConfig(), loader), loader, dir
+}
+
+func writeTemplate(t *testing.T, dir, name, content string) {
+	if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func render(t *testing.T, env *gonja.Environment, name string) string {
+	tpl, err := env.FromCache(name)
+	if !assert.Nil(t, err) {
+		return ""
+	}
+	out, err := tpl.Execute(nil)
+	assert.Nil(t, err)
+	return out
+}
+
+func TestLRUCache(t *testing.T) {
+	cache := gonja.NewLRUCache(2)
+	cache.Set("a", &gonja.CachedTemplate{})
+	cache.Set("b", &gonja.CachedTemplate{})
+	_, ok := cache.Get("a")
+	assert.True(t, ok)
+	cache.Set("c", &gonja.CachedTemplate{})
+
+	assert.Equal(t, 2, cache.Len())
+	_, ok = cache.Get("b")
+	assert.False(t, ok, "the least recently used template is evicted")
+	_, ok = cache.Get("a")
+	assert.True(t, ok)
+
+	cache.Delete("a")
+	_, ok = cache.Get("a")
+	assert.False(t, ok)
+	cache.Delete()
+	assert.Equal(t, 0, cache.Len())
+}
+
+func TestFromCache(t *testing.T) {
+	env, loader, _ := cacheEnv(t, map[string]string{"tpl": "Hello"})
+	assert.Equal(t, "Hello", render(t, env, "tpl"))
+	assert.Equal(t, "Hello", render(t, env, "tpl"))
+	assert.Equal(t, int32(1), loader.reads)
+
+	env.CleanCache("tpl")
+	assert.Equal(t, "Hello", render(t, env, "tpl"))
+	assert.Equal(t, int32(2), loader.reads)
+
+	_, err := env.FromCache("missing")
+	assert.NotNil(t, err)
+}
+
+func TestFromCacheReload(t *testing.T) {
+	env, loader, dir := cacheEnv(t, map[string]string{
+		"base":    "[{% block content %}{% endblock %}]",
+		"partial": "partial",
+		"tpl":     `{% extends "base" %}{% block content %}{% include "partial" %}{% endblock %}`,
+	})
+	assert.Equal(t, "[partial]", render(t, env, "tpl"))
+	assert.Equal(t, int32(3), loader.reads)
+
+	writeTemplate(t, dir, "partial", "updated partial")
+	assert.Equal(t, "[updated partial]", render(t, env, "tpl"))
+
+	writeTemplate(t, dir, "base", "<<{% block content %}{% endblock %}>>")
+	assert.Equal(t, "<<updated partial>>", render(t, env, "tpl"))
+
+	env.AutoReload = false
+	writeTemplate(t, dir, "base", "{% block content %}{% endblock %}")
+	assert.Equal(t, "<<updated partial>>", render(t, env, "tpl"))
+}
+
+func TestFromCacheDebug(t *testing.T) {
+	env, loader, _ := cacheEnv(t, map[string]string{"tpl": "Hello"})
+	env.Config.Debug = true
+	assert.Equal(t, "Hello", render(t, env, "tpl"))
+	assert.Equal(t, "Hello", render(t, env, "tpl"))
+	assert.Equal(t, int32(2), loader.reads)
+}
+
+func TestDynamicIncludeUsesCache(t *testing.T) {
+	env, loader, _ := cacheEnv(t, map[string]string{
+		"partial": "{{ i }}",
+		"tpl":     `{% for i in range(3) %}{% include name %}{% endfor %}`,
+	})
+	tpl, err := env.FromCache("tpl")
+	if !assert.Nil(t, err) {
+		return
+	}
+	for idx := 0; idx < 2; idx++ {
+		out, err := tpl.Execute(map[string]interface{}{"name": "partial"})
+		if assert.Nil(t, err) {
+			assert.Equal(t, "012", out)
+		}
+	}
+	assert.Equal(t, int32(2), loader.reads)
+}
+
+func TestFromCacheCompilesOnce(t *testing.T) {
+	env, loader, _ := cacheEnv(t, map[string]string{"tpl": "Hello"})
+	loader.delay = 20 * time.Millisecond
+
+	var wg sync.WaitGroup
+	for idx := 0; idx < 10; idx++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tpl, err := env.FromCache("tpl")
+			if assert.Nil(t, err) {
+				assert.Equal(t, "tpl", tpl.Name)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&loader.reads))
+}
@@ -0,0 +1,74 @@
+package gonja_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/paradime-io/gonja"
+)
+
+var callCases = []struct {
+	name     string
+	source   string
+	expected string
+}{
+	{"caller body",
+		`{% macro card(title) %}<{{ title }}>{{ caller() }}</{{ title }}>{% endmacro %}{% call card('div') %}content{% endcall %}`,
+		"<div>content</div>"},
+	{"caller arguments",
+		`{% macro each(items) %}{% for i in items %}{{ caller(i, sep='-') }}{% endfor %}{% endmacro %}{% call(item, sep=',') each([1, 2]) %}{{ item }}{{ sep }}{% endcall %}`,
+		"1-2-"},
+	{"call block scope",
+		`{% set name = 'outer' %}{% macro wrap() %}{% set name = 'inner' %}[{{ caller() }}]{% endmacro %}{% call wrap() %}{{ name }}{% endcall %}`,
+		"[outer]"},
+	{"caller detection",
+		`{% macro opt() %}{% if caller is defined %}{{ caller() }}{% else %}none{% endif %}{% endmacro %}{{ opt() }} {% call opt() %}body{% endcall %}`,
+		"none body"},
+	{"caller declared as argument",
+		`{% macro m(caller=None) %}{{ caller() }}{% endmacro %}{% call m() %}declared{% endcall %}`,
+		"declared"},
+	{"nested calls",
+		`{% macro box(n) %}{{ n }}({{ caller() }}){% endmacro %}{% call box('a') %}{% call box('b') %}x{% endcall %}{% endcall %}`,
+		"a(b(x))"},
+	{"caller in loop",
+		`{% macro item() %}<{{ caller() }}>{% endmacro %}{% for i in items %}{% call item() %}{{ i }}{{ loop.index }}{% endcall %}{% endfor %}`,
+		"<a1><b2>"},
+	{"caller output is safe",
+		`{% autoescape true %}{% macro m() %}{{ caller() }}{% endmacro %}{% call m() %}<b>{{ '<i>' }}</b>{% endcall %}{% endautoescape %}`,
+		"<b>&lt;i&gt;</b>"},
+}
+
+func TestCallBlock(t *testing.T) {
+	data := map[string]interface{}{"items": []string{"a", "b"}}
+	for _, tc := range callCases {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			tpl, err := gonja.FromString(test.source)
+			if !assert.Nil(t, err) {
+				return
+			}
+			out, err := tpl.Execute(data)
+			assert.Nil(t, err)
+			assert.Equal(t, test.expected, out)
+		})
+	}
+}
+
+func TestCallBlockErrors(t *testing.T) {
+	for _, source := range []string{
+		`{% call %}{% endcall %}`,
+		`{% call 'x' %}{% endcall %}`,
+		`{% call m() %}`,
+		`{% for i in items %}{% call m() %}{% break %}{% endcall %}{% endfor %}`,
+	} {
+		_, err := gonja.FromString(source)
+		assert.NotNil(t, err, source)
+	}
+
+	tpl, err := gonja.FromString(`{% macro m() %}{% endmacro %}{% call(x) m() %}{{ x }}{% endcall %}{% call undefined_macro() %}{% endcall %}`)
+	if assert.Nil(t, err) {
+		_, err = tpl.Execute(nil)
+		assert.NotNil(t, err)
+	}
+}
@@ -0,0 +1,79 @@
+package gonja_test
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/paradime-io/gonja"
+	"github.com/paradime-io/gonja/exec"
+)
+
+func TestExecuteContextCancelled(t *testing.T) {
+	tpl, err := gonja.FromString(`{% macro m() %}x{% endmacro %}{{ m() }}`)
+	if !assert.Nil(t, err) {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	out, err := tpl.ExecuteContext(ctx, nil)
+	assert.Equal(t, "", out)
+	var cancelErr *exec.CancelledError
+	if assert.True(t, errors.As(err, &cancelErr), "got %v", err) {
+		assert.True(t, errors.Is(err, context.Canceled))
+	}
+}
+
+func TestExecuteContextDeadline(t *testing.T) {
+	tpl, err := gonja.FromString(`{% for i in range(1000000000) %}{% for j in range(10) %}{{ i * j }}{% endfor %}{% endfor %}`)
+	if !assert.Nil(t, err) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err = tpl.ExecuteContext(ctx, nil)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "got %v", err)
+	assert.True(t, time.Since(start) < 5*time.Second, "rendering should stop shortly after the deadline")
+}
+
+func TestExecuteContextStopsRange(t *testing.T) {
+	tpl, err := gonja.FromString(`{% set r = range(10) %}{% for i in range(3) %}{{ i }}{% endfor %}`)
+	if !assert.Nil(t, err) {
+		return
+	}
+	before := runtime.NumGoroutine()
+	for i := 0; i < 10; i++ {
+		out, err := tpl.ExecuteContext(context.Background(), nil)
+		assert.Nil(t, err)
+		assert.Equal(t, "012", out)
+	}
+
+	// Unconsumed producers exit asynchronously once the rendering is over
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.True(t, runtime.NumGoroutine() <= before, "range goroutines leaked")
+}
+
+func TestExecuteWriterContextCancelled(t *testing.T) {
+	tpl, err := gonja.FromString(`{% for i in range(1000000000) %}{{ i }}{% endfor %}`)
+	if !assert.Nil(t, err) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	w := &chunkWriter{}
+	err = tpl.ExecuteWriterContext(ctx, nil, w)
+	var cancelErr *exec.CancelledError
+	assert.True(t, errors.As(err, &cancelErr), "got %v", err)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "got %v", err)
+}
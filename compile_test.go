@@ -0,0 +1,163 @@
+package gonja_test
+
+import (
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/paradime-io/gonja"
+	"github.com/paradime-io/gonja/config"
+	"github.com/paradime-io/gonja/exec"
+	"github.com/paradime-io/gonja/loaders"
+	tu "github.com/paradime-io/gonja/testutils"
+)
+
+// interpreted returns a copy of tpl whose expressions are interpreted
+func interpreted(tpl *exec.Template) *exec.Template {
+	copied := *tpl
+	copied.Program = nil
+	return &copied
+}
+
+func TestCompiledMatchesInterpreted(t *testing.T) {
+	for _, root := range []string{"testData", "testData/expressions", "testData/filters", "testData/functions", "testData/tests", "testData/statements"} {
+		env := tu.TestEnv(root)
+		env.Globals.Set("this_is_a_global_variable", "this is a global text")
+		matches, err := filepath.Glob(filepath.Join(root, "*.tpl"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, match := range matches {
+			name, _ := filepath.Rel(root, match)
+			t.Run(strings.TrimSuffix(match, ".tpl"), func(t *testing.T) {
+				tpl, err := env.FromFile(name)
+				if err != nil {
+					t.Skip(err)
+				}
+				rand.Seed(42)
+				expected, expectedErr := interpreted(tpl).Execute(tu.Fixtures)
+				rand.Seed(42)
+				out, err := tpl.Execute(tu.Fixtures)
+				assert.Equal(t, expected, out)
+				assert.Equal(t, expectedErr, err)
+			})
+		}
+	}
+}
+
+var compiledErrorCases = []struct {
+	name   string
+	source string
+}{
+	{"undefined", `{{ missing.attr }}`},
+	{"unknown filter", `{{ a|unknown }}`},
+	{"unknown test", `{{ a is unknown }}`},
+	{"filter argument", `{{ a|default(b.c.d) }}`},
+	{"not callable", `{{ a() }}`},
+	{"left error", `{{ (a|unknown) + 1 }}`},
+	{"right error", `{{ 1 + (a|unknown) }}`},
+	{"logical", `{{ a and (a|unknown) }}`},
+	{"pair", `{{ {"k": a|unknown} }}`},
+	{"negative string", `{{ -"a" }}`},
+	{"range", `{{ "abc"["a":] }}`},
+}
+
+func TestCompiledErrorsMatchInterpreted(t *testing.T) {
+	for _, test := range compiledErrorCases {
+		tc := test
+		t.Run(tc.name, func(t *testing.T) {
+			for _, undefined := range []exec.UndefinedPolicy{exec.ChainableUndefined, exec.StrictUndefined} {
+				env := gonja.NewEnvironment(config.NewConfig(), loaders.MapLoader{})
+				env.Undefined = undefined
+				tpl, err := env.FromString(tc.source)
+				if !assert.Nil(t, err) {
+					return
+				}
+				ctx := map[string]interface{}{"a": 1}
+				expected, expectedErr := interpreted(tpl).Execute(ctx)
+				out, err := tpl.Execute(ctx)
+				assert.Equal(t, expected, out)
+				assert.Equal(t, fmt.Sprint(expectedErr), fmt.Sprint(err))
+			}
+		})
+	}
+}
+
+func TestCompiledLateFilter(t *testing.T) {
+	env := gonja.NewEnvironment(config.NewConfig(), loaders.MapLoader{})
+	tpl, err := env.FromString(`{{ "a"|late }}`)
+	if !assert.Nil(t, err) {
+		return
+	}
+	env.Filters.Register("late", func(e *exec.Evaluator, in *exec.Value, params *exec.VarArgs) *exec.Value {
+		return exec.AsValue(in.String() + "!")
+	})
+	out, err := tpl.Execute(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "a!", out)
+}
+
+func TestCompiledReplacedFilter(t *testing.T) {
+	env := gonja.NewEnvironment(config.NewConfig(), loaders.MapLoader{})
+	tpl, err := env.FromString(`{{ "a"|upper }}`)
+	if !assert.Nil(t, err) {
+		return
+	}
+	err = env.Filters.Replace("upper", func(e *exec.Evaluator, in *exec.Value, params *exec.VarArgs) *exec.Value {
+		return exec.AsValue(in.String() + "?")
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+	out, err := tpl.Execute(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "a?", out)
+}
+
+type compiledItem struct {
+	Name string
+}
+
+func (i compiledItem) Upper() string { return strings.ToUpper(i.Name) }
+
+type compiledPtrItem struct {
+	Label string
+}
+
+func (i *compiledPtrItem) Name() string { return i.Label }
+
+func TestCompiledAttributes(t *testing.T) {
+	var missing *compiledPtrItem
+	for _, source := range []string{
+		`{% for i in items %}[{{ i.Name }}|{{ i.label }}|{{ i.upper is defined }}]{% endfor %}`,
+		`{% for i in items %}{% if i.upper is defined %}{{ i.upper() }}{% endif %}{% endfor %}`,
+		`{{ missing.Name }}`,
+		`{{ none.Name }}`,
+	} {
+		tpl, err := gonja.FromString(source)
+		if !assert.Nil(t, err) {
+			return
+		}
+		ctx := map[string]interface{}{
+			"items": []interface{}{
+				compiledItem{"a"},
+				&compiledItem{"b"},
+				&compiledPtrItem{"c"},
+				map[string]string{"Name": "d", "label": "D"},
+				map[string]interface{}{"upper": "e"},
+				"f",
+				compiledItem{"g"},
+			},
+			"missing": missing,
+			"none":    nil,
+		}
+		expected, expectedErr := interpreted(tpl).Execute(ctx)
+		out, err := tpl.Execute(ctx)
+		assert.Equal(t, expected, out)
+		assert.Equal(t, fmt.Sprint(expectedErr), fmt.Sprint(err))
+	}
+}
@@ -105,6 +105,8 @@ func (cfg *Config) Inherit() *Config {
 		VariableEndString:   cfg.VariableEndString,
 		CommentStartString:  cfg.CommentStartString,
 		CommentEndString:    cfg.CommentEndString,
+		LineStatementPrefix: cfg.LineStatementPrefix,
+		LineCommentPrefix:   cfg.LineCommentPrefix,
 		TrimBlocks:          cfg.TrimBlocks,
 		LstripBlocks:        cfg.LstripBlocks,
 		NewlineSequence:     cfg.NewlineSequence,
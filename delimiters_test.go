@@ -0,0 +1,61 @@
+package gonja_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/paradime-io/gonja"
+	"github.com/paradime-io/gonja/config"
+)
+
+var delimitersCases = []struct {
+	name     string
+	block    [2]string
+	variable [2]string
+	comment  [2]string
+	source   string
+	expected string
+}{
+	{"sql",
+		[2]string{"<%", "%>"}, [2]string{"<<", ">>"}, [2]string{"<#", "#>"},
+		"SELECT {{ col }} <# comment #>FROM <% if prod %><< schema >><% else %>dev<% endif %>.t",
+		"SELECT {{ col }} FROM analytics.t",
+	},
+	{"latex",
+		[2]string{`\BLOCK{`, "}"}, [2]string{`\VAR{`, "}"}, [2]string{`\#{`, "}"},
+		`\section{\VAR{ title }}\#{ ignored }\BLOCK{for i in items}\item{\VAR{ i ~ {'v': i}|length }}\BLOCK{endfor}`,
+		`\section{Report}\item{11}\item{21}`,
+	},
+	{"raw",
+		[2]string{"[%", "%]"}, [2]string{"[[", "]]"}, [2]string{"[#", "#]"},
+		"[% raw %]{{ [[ title ]] }}[% endraw %] [[ title ]]",
+		"{{ [[ title ]] }} Report",
+	},
+}
+
+func TestCustomDelimiters(t *testing.T) {
+	for _, tc := range delimitersCases {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			cfg := config.NewConfig()
+			cfg.BlockStartString, cfg.BlockEndString = test.block[0], test.block[1]
+			cfg.VariableStartString, cfg.VariableEndString = test.variable[0], test.variable[1]
+			cfg.CommentStartString, cfg.CommentEndString = test.comment[0], test.comment[1]
+			env := gonja.NewEnvironment(cfg, gonja.DefaultLoader)
+
+			tpl, err := env.FromString(test.source)
+			if !assert.Nil(t, err) {
+				return
+			}
+			out, err := tpl.Execute(map[string]interface{}{
+				"prod":   true,
+				"schema": "analytics",
+				"title":  "Report",
+				"items":  []int{1, 2},
+			})
+			assert.Nil(t, err)
+			assert.Equal(t, test.expected, out)
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package gonja_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/paradime-io/gonja"
+)
+
+var doCases = []struct {
+	name     string
+	source   string
+	expected string
+}{
+	{"append to a list",
+		`{% set l = [1] %}{% do l.append(2) %}{% for i in range(3, 5) %}{% do l.append(i) %}{% endfor %}{{ l }}`,
+		"[1, 2, 3, 4]"},
+	{"update a dict",
+		`{% do d.update({'b': 2}) %}{{ d.b }}`,
+		"2"},
+	{"discard the result",
+		`[{% do 1 + 2 %}{% do 'x' if true else 'y' %}]`,
+		"[]"},
+	{"call a function",
+		`[{% do record('x') %}{% do record('y') %}]`,
+		"[]"},
+	{"whitespace control",
+		"a\n{%- do 1 -%}\nb",
+		"ab"},
+}
+
+func TestDo(t *testing.T) {
+	for _, tc := range doCases {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			recorded := []string{}
+			data := map[string]interface{}{
+				"d": map[string]interface{}{"a": 1},
+				"record": func(s string) string {
+					recorded = append(recorded, s)
+					return s
+				},
+			}
+			tpl, err := gonja.FromString(test.source)
+			if !assert.Nil(t, err) {
+				return
+			}
+			out, err := tpl.Execute(data)
+			assert.Nil(t, err)
+			assert.Equal(t, test.expected, out)
+			if test.name == "call a function" {
+				assert.Equal(t, []string{"x", "y"}, recorded)
+			}
+		})
+	}
+}
+
+func TestDoErrors(t *testing.T) {
+	for _, source := range []string{`{% do %}`, `{% do 1 2 %}`} {
+		_, err := gonja.FromString(source)
+		assert.NotNil(t, err, source)
+	}
+
+	tpl, err := gonja.FromString("line\n  {% do missing() %}")
+	if !assert.Nil(t, err) {
+		return
+	}
+	_, err = tpl.Execute(nil)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "(Line: 2 Col: 3)")
+	}
+}
@@ -1,10 +1,12 @@
 package gonja
 
 import (
+	"io"
 	"io/ioutil"
 	"sync"
 
 	"github.com/goph/emperror"
+	"github.com/pkg/errors"
 
 	"github.com/paradime-io/gonja/builtins"
 	"github.com/paradime-io/gonja/config"
@@ -12,19 +14,38 @@ import (
 	"github.com/paradime-io/gonja/loaders"
 )
 
+// DefaultCacheSize is the number of templates cached by a new Environment
+const DefaultCacheSize = 400
+
 type Environment struct {
 	*exec.EvalConfig
 	Loader loaders.Loader
 
-	Cache      map[string]*exec.Template
-	CacheMutex sync.Mutex
+	// Cache holds the templates compiled by FromCache and GetTemplate.
+	// It replaces the former map and CacheMutex fields (see CHANGELOG.md).
+	Cache Cache
+	// AutoReload checks whether the cached templates are up to date
+	// when the loader is a loaders.Versioner
+	AutoReload bool
+
+	compilations     map[string]*compilation
+	compilationMutex sync.Mutex
+}
+
+// compilation is a template being compiled, waited for by concurrent callers
+type compilation struct {
+	done chan struct{}
+	tpl  *CachedTemplate
+	err  error
 }
 
 func NewEnvironment(cfg *config.Config, loader loaders.Loader) *Environment {
 	env := &Environment{
-		EvalConfig: exec.NewEvalConfig(cfg),
-		Loader:     loader,
-		Cache:      map[string]*exec.Template{},
+		EvalConfig:   exec.NewEvalConfig(cfg),
+		Loader:       loader,
+		Cache:        NewLRUCache(DefaultCacheSize),
+		AutoReload:   true,
+		compilations: map[string]*compilation{},
 	}
 	env.EvalConfig.Loader = env
 	env.Filters.Update(builtins.Filters)
@@ -41,20 +62,14 @@ func NewEnvironment(cfg *config.Config, loader loaders.Loader) *Environment {
 // it will remove the template caches of those filenames.
 // Or it will empty the whole template cache. It is thread-safe.
 func (env *Environment) CleanCache(filenames ...string) {
-	env.CacheMutex.Lock()
-	defer env.CacheMutex.Unlock()
-
-	if len(filenames) == 0 {
-		env.Cache = map[string]*exec.Template{}
-	}
-
-	for _, filename := range filenames {
-		delete(env.Cache, filename)
-	}
+	env.Cache.Delete(filenames...)
 }
 
 // FromCache is a convenient method to cache templates. It is thread-safe
-// and will only compile the template associated with a filename once.
+// and will only compile the template associated with a filename once,
+// concurrent callers waiting for the same compilation.
+// The template is compiled again when its source, or the one of a template
+// it statically includes, imports or extends, changed (see AutoReload).
 // If Environment.Debug is true (for example during development phase),
 // FromCache() will not cache the template and instead recompile it on any
 // call (to make changes to a template live instantaneously).
@@ -63,24 +78,124 @@ func (env *Environment) FromCache(filename string) (*exec.Template, error) {
 		// Recompile on any request
 		return env.FromFile(filename)
 	}
+	if cached, ok := env.cached(filename); ok {
+		return cached.Template, nil
+	}
 
-	env.CacheMutex.Lock()
-	defer env.CacheMutex.Unlock()
+	env.compilationMutex.Lock()
+	if current, ok := env.compilations[filename]; ok {
+		env.compilationMutex.Unlock()
+		<-current.done
+		if current.err != nil {
+			return nil, current.err
+		}
+		return current.tpl.Template, nil
+	}
+	// The template might have been cached while waiting for the lock
+	if cached, ok := env.cached(filename); ok {
+		env.compilationMutex.Unlock()
+		return cached.Template, nil
+	}
+	current := &compilation{done: make(chan struct{})}
+	env.compilations[filename] = current
+	env.compilationMutex.Unlock()
 
-	tpl, has := env.Cache[filename]
+	current.tpl, current.err = env.compile(filename)
+	if current.err == nil {
+		env.Cache.Set(filename, current.tpl)
+	}
+
+	env.compilationMutex.Lock()
+	delete(env.compilations, filename)
+	env.compilationMutex.Unlock()
+	close(current.done)
 
-	// Cache miss
-	if !has {
-		tpl, err := env.FromFile(filename)
-		if err != nil {
+	if current.err != nil {
+		return nil, current.err
+	}
+	return current.tpl.Template, nil
+}
+
+// cached returns the cached template of filename if it is up to date
+func (env *Environment) cached(filename string) (*CachedTemplate, bool) {
+	cached, ok := env.Cache.Get(filename)
+	if !ok {
+		return nil, false
+	}
+	if env.AutoReload && !env.upToDate(cached) {
+		env.Cache.Delete(filename)
+		return nil, false
+	}
+	return cached, true
+}
+
+func (env *Environment) upToDate(cached *CachedTemplate) bool {
+	versioner, ok := env.Loader.(loaders.Versioner)
+	if !ok {
+		return true
+	}
+	for name, version := range cached.Versions {
+		current, err := versioner.Version(name)
+		if err != nil || current != version {
+			return false
+		}
+	}
+	return true
+}
+
+// compile compiles filename, recording the versions of its sources
+func (env *Environment) compile(filename string) (*CachedTemplate, error) {
+	fd, version, err := loaders.GetSource(env.Loader, filename)
+	if err != nil {
+		return nil, emperror.With(err, "filename", filename)
+	}
+	buf, err := ioutil.ReadAll(fd)
+	if err != nil {
+		return nil, emperror.With(err, "filename", filename)
+	}
+	cached := &CachedTemplate{Versions: map[string]string{filename: version}}
+
+	// Templates loaded while parsing are recorded as dependencies.
+	// They never wait for a pending compilation which might be the one
+	// of the template including them.
+	cfg := *env.EvalConfig
+	cfg.Loader = &dependencies{env: env, cached: cached}
+	tpl, err := exec.NewTemplate(filename, string(buf), &cfg)
+	if err != nil {
+		return nil, err
+	}
+	tpl.Env = env.EvalConfig
+	cached.Template = tpl
+	return cached, nil
+}
+
+// dependencies loads the templates required to parse a template
+type dependencies struct {
+	env    *Environment
+	cached *CachedTemplate
+}
+
+func (deps *dependencies) GetTemplate(filename string) (*exec.Template, error) {
+	env := deps.env
+	if env.Config.Debug {
+		return env.FromFile(filename)
+	}
+	dep, ok := env.cached(filename)
+	if !ok {
+		var err error
+		if dep, err = env.compile(filename); err != nil {
 			return nil, err
 		}
-		env.Cache[filename] = tpl
-		return tpl, nil
+		env.Cache.Set(filename, dep)
 	}
+	for name, version := range dep.Versions {
+		deps.cached.Versions[name] = version
+	}
+	return dep.Template, nil
+}
 
-	// Cache hit
-	return tpl, nil
+func (deps *dependencies) ResolveTemplate(base, name string) string {
+	return deps.env.ResolveTemplate(base, name)
 }
 
 // FromString loads a template from string and returns a Template instance.
@@ -95,18 +210,49 @@ func (env *Environment) FromBytes(tpl []byte) (*exec.Template, error) {
 
 // FromFile loads a template from a filename and returns a Template instance.
 func (env *Environment) FromFile(filename string) (*exec.Template, error) {
+	source, err := env.read(filename)
+	if err != nil {
+		return nil, err
+	}
+	return exec.NewTemplate(filename, source, env.EvalConfig)
+}
+
+// FromSerialized loads a template written by exec.Template.Serialize,
+// skipping its lexing and parsing (see exec.Deserialize).
+func (env *Environment) FromSerialized(r io.Reader) (*exec.Template, error) {
+	return exec.Deserialize(r, env.EvalConfig)
+}
+
+func (env *Environment) read(filename string) (string, error) {
 	fd, err := env.Loader.Get(filename)
 	if err != nil {
-		return nil, emperror.With(err, "filename", filename)
+		return "", emperror.With(err, "filename", filename)
 	}
 	buf, err := ioutil.ReadAll(fd)
 	if err != nil {
-		return nil, emperror.With(err, "filename", filename)
+		return "", emperror.With(err, "filename", filename)
 	}
+	return string(buf), nil
+}
 
-	return exec.NewTemplate(filename, string(buf), env.EvalConfig)
+// ResolveTemplate returns the name of the template name referenced by the template base,
+// ie. './partial.html' is relative to the directory of base (see loaders.Resolve).
+func (env *Environment) ResolveTemplate(base, name string) string {
+	return loaders.Resolve(env.Loader, base, name)
+}
+
+// ListTemplates returns the names of the templates of the loader accepted by filter,
+// all of them if filter is nil. The loader must be a loaders.Lister.
+func (env *Environment) ListTemplates(filter func(name string) bool) ([]string, error) {
+	lister, ok := env.Loader.(loaders.Lister)
+	if !ok {
+		return nil, errors.Errorf(`Unable to list the templates of %T`, env.Loader)
+	}
+	return lister.ListTemplates(filter)
 }
 
+// GetTemplate returns the template used by an include, import or extends,
+// see FromCache.
 func (env *Environment) GetTemplate(filename string) (*exec.Template, error) {
-	return env.FromFile(filename)
+	return env.FromCache(filename)
 }
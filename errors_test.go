@@ -0,0 +1,109 @@
+package gonja_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/paradime-io/gonja"
+	"github.com/paradime-io/gonja/config"
+	"github.com/paradime-io/gonja/loaders"
+	"github.com/paradime-io/gonja/parser"
+)
+
+func TestParseErrorLocation(t *testing.T) {
+	_, err := gonja.FromString("Hello\n  {{ foo( }}")
+	located := parser.AsError(err)
+	if !assert.NotNil(t, located, "got %v", err) {
+		return
+	}
+	assert.Equal(t, string(parser.ParsePhase), string(located.Phase))
+	assert.Equal(t, "string", located.Template)
+	assert.Equal(t, 2, located.Line)
+	assert.Equal(t, 11, located.Col)
+	assert.Equal(t, "}}", located.Token.Val)
+	assert.Equal(t, "1 | Hello\n2 |   {{ foo( }}\n  |           ^^", located.Excerpt())
+}
+
+func TestLexErrorLocation(t *testing.T) {
+	_, err := gonja.FromString("Hello\n{# unclosed")
+	located := parser.AsError(err)
+	if assert.NotNil(t, located, "got %v", err) {
+		assert.Equal(t, string(parser.LexPhase), string(located.Phase))
+		assert.Equal(t, 2, located.Line)
+		assert.Contains(t, located.Err.Error(), "unclosed comment")
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	source := "first\n\tsecond {{ x }}\nthird"
+	assert.Equal(t, "1 | first\n2 | \tsecond {{ x }}\n  | \t          ^", parser.Excerpt(source, 2, 12, 1))
+	assert.Equal(t, "1 | first\n  | ^^^^^", parser.Excerpt(source, 1, 1, 10))
+	assert.Equal(t, "", parser.Excerpt(source, 4, 1, 1))
+	assert.Equal(t, "", parser.Excerpt("", 1, 1, 1))
+	// Columns are byte offsets, the caret is indented by runes
+	assert.Equal(t, "1 | héllo wörld {{ x }}\n  |                ^", parser.Excerpt("héllo wörld {{ x }}", 1, 18, 1))
+	assert.Equal(t, "1 | é {{ ünknöwn }}\n  |      ^^^^^^^^^^", parser.Excerpt("é {{ ünknöwn }}", 1, 7, 20))
+}
+
+func TestParseErrorExcerptMultibyte(t *testing.T) {
+	_, err := gonja.FromString(`héllo {{ "é" "ü" }}`)
+	located := parser.AsError(err)
+	if assert.NotNil(t, located, "got %v", err) {
+		assert.Equal(t, "ü", located.Token.Val)
+		assert.Equal(t, "1 | héllo {{ \"é\" \"ü\" }}\n  |              ^", located.Excerpt())
+	}
+}
+
+func TestRenderErrorStack(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"main.tpl":   "main\n{% include 'item.tpl' %}",
+		"item.tpl":   "{% for i in items %}\n  {{ fail(i) }}\n{% endfor %}",
+		"base.tpl":   "{% block content %}{% endblock %}\n{{ fail(0) }}",
+		"child.tpl":  "{% extends 'base.tpl' %}{% block content %}\n\n{{ fail(1) }}{% endblock %}",
+		"parent.tpl": "{% extends 'base.tpl' %}",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	env := gonja.NewEnvironment(config.NewConfig(), loaders.MustNewFileSystemLoader(dir))
+	data := map[string]interface{}{
+		"items": []int{1},
+		"fail":  func(i int) (string, error) { return "", assert.AnError },
+	}
+
+	cases := []struct {
+		template string
+		stack    [][3]interface{} // template, line, col
+	}{
+		{"main.tpl", [][3]interface{}{{"main.tpl", 2, 1}, {"item.tpl", 2, 3}}},
+		{"child.tpl", [][3]interface{}{{"base.tpl", 1, 1}, {"child.tpl", 3, 1}}},
+		{"parent.tpl", [][3]interface{}{{"base.tpl", 2, 1}}},
+	}
+	for _, test := range cases {
+		tpl, err := env.FromFile(test.template)
+		if !assert.Nil(t, err) {
+			continue
+		}
+		_, err = tpl.Execute(data)
+		located, ok := err.(*parser.Error)
+		if !assert.True(t, ok, "%s: got %v", test.template, err) {
+			continue
+		}
+		assert.Equal(t, string(parser.RenderPhase), string(located.Phase))
+		stack := located.Stack()
+		if assert.Len(t, stack, len(test.stack), test.template) {
+			for i, frame := range test.stack {
+				assert.Equal(t, frame[0], stack[i].Template, test.template)
+				assert.Equal(t, frame[1], stack[i].Line, test.template)
+				assert.Equal(t, frame[2], stack[i].Col, test.template)
+			}
+		}
+		assert.Contains(t, err.Error(), assert.AnError.Error())
+	}
+}
@@ -0,0 +1,37 @@
+package exec
+
+import (
+	"context"
+	"fmt"
+)
+
+// CancelledError is returned when a rendering is stopped
+// because its context has been cancelled or its deadline exceeded
+type CancelledError struct {
+	Err error
+}
+
+func (e *CancelledError) Error() string {
+	return fmt.Sprintf("Rendering cancelled: %s", e.Err)
+}
+
+// Cause returns the underlying context error (context.Canceled or context.DeadlineExceeded)
+func (e *CancelledError) Cause() error { return e.Err }
+
+// Unwrap allows errors.Is/As to reach the context error
+func (e *CancelledError) Unwrap() error { return e.Err }
+
+// Cancelled returns a *CancelledError once the rendering context is done, nil otherwise
+func (r *Renderer) Cancelled() error {
+	return cancelled(r.Context)
+}
+
+func cancelled(ctx context.Context) error {
+	if ctx == nil {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return &CancelledError{Err: err}
+	}
+	return nil
+}
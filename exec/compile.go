@@ -0,0 +1,567 @@
+package exec
+
+import (
+	"reflect"
+	"strings"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+
+	"github.com/paradime-io/gonja/nodes"
+)
+
+// compiled evaluates a compiled expression
+type compiled func(e *Evaluator) *Value
+
+// Program holds the expressions of a template compiled to closures.
+// Operators are resolved once when compiling instead of on every evaluation,
+// attributes once per type of the accessed values, and the closures call
+// each other directly. Filters and tests are looked up when rendering,
+// so that replacing them in the configuration applies to compiled templates.
+// Expressions missing from a program, ie. built while rendering
+// or belonging to an included template, are interpreted.
+type Program struct {
+	exprs map[nodes.Expression]compiled
+}
+
+// Compile compiles the expressions of root and of its parents.
+// The expressions evaluated by statements are looked up by Eval.
+func Compile(root *nodes.Template, cfg *EvalConfig) *Program {
+	c := &compiler{
+		cfg:     cfg,
+		program: &Program{exprs: map[nodes.Expression]compiled{}},
+	}
+	for tpl := root; tpl != nil; tpl = tpl.Parent {
+		nodes.Inspect(tpl, func(node nodes.Node) bool {
+			c.expression(node)
+			return true
+		})
+	}
+	return c.program
+}
+
+// Len returns the number of compiled expressions
+func (p *Program) Len() int {
+	return len(p.exprs)
+}
+
+func (p *Program) lookup(node nodes.Expression) compiled {
+	if p == nil || !hashable(node) {
+		return nil
+	}
+	return p.exprs[node]
+}
+
+// hashable returns true if node can be used as a key, nodes being usually pointers
+func hashable(node nodes.Node) bool {
+	return node != nil && reflect.TypeOf(node).Comparable()
+}
+
+type compiler struct {
+	cfg     *EvalConfig
+	program *Program
+}
+
+// expression returns the compiled node, nil if it isn't an expression.
+// Like Evaluator.Eval, the compiled expressions account for an evaluation step.
+func (c *compiler) expression(node nodes.Node) compiled {
+	if !hashable(node) {
+		return nil
+	}
+	if fn, ok := c.program.exprs[node]; ok {
+		return fn
+	}
+	eval := c.compile(node)
+	if eval == nil {
+		return nil
+	}
+	fn := func(e *Evaluator) *Value {
+		if err := e.usage.step(node); err != nil {
+			return AsValue(err)
+		}
+		return eval(e)
+	}
+	c.program.exprs[node] = fn
+	return fn
+}
+
+// child returns a compiled operand, interpreted if it can't be compiled
+func (c *compiler) child(node nodes.Node) compiled {
+	if fn := c.expression(node); fn != nil {
+		return fn
+	}
+	return func(e *Evaluator) *Value {
+		return e.Eval(node)
+	}
+}
+
+func (c *compiler) expressions(exprs []nodes.Expression) []compiled {
+	fns := make([]compiled, 0, len(exprs))
+	for _, expr := range exprs {
+		fns = append(fns, c.child(expr))
+	}
+	return fns
+}
+
+func (c *compiler) compile(node nodes.Node) compiled {
+	switch n := node.(type) {
+	case *nodes.String:
+		return literal(n.Val)
+	case *nodes.Integer:
+		return literal(n.Val)
+	case *nodes.Float:
+		return literal(n.Val)
+	case *nodes.Bool:
+		return literal(n.Val)
+	case *nodes.List:
+		return c.list(n.Val)
+	case *nodes.Tuple:
+		return c.list(n.Val)
+	case *nodes.Dict:
+		return c.dict(n)
+	case *nodes.Pair:
+		return c.pair(n)
+	case *nodes.Name:
+		return name(n)
+	case *nodes.Varargs:
+		return name(&n.Name)
+	case *nodes.Kwargs:
+		return name(&n.Name)
+	case *nodes.Call:
+		fn := c.child(n.Func)
+		args := c.callArgs(n)
+		return func(e *Evaluator) *Value {
+			return e.call(n, fn(e), args)
+		}
+	case *nodes.Getitem:
+		target := c.child(n.Node)
+		return func(e *Evaluator) *Value {
+			return e.getitem(n, target(e))
+		}
+	case *nodes.Getitemrange:
+		target := c.child(n.Node)
+		return func(e *Evaluator) *Value {
+			return e.getitemrange(n, target(e))
+		}
+	case *nodes.Getattr:
+		target := c.child(n.Node)
+		if n.Attr == "" {
+			return func(e *Evaluator) *Value {
+				return e.getattr(n, target(e))
+			}
+		}
+		cache := newAttrCache(n.Attr)
+		return func(e *Evaluator) *Value {
+			value := target(e)
+			if attr := cache.get(value); attr != nil {
+				return attr
+			}
+			return e.getattr(n, value)
+		}
+	case *nodes.Negation:
+		term := c.child(n.Term)
+		return func(e *Evaluator) *Value {
+			result := term(e)
+			if result.IsError() {
+				return result
+			}
+			return result.Negate()
+		}
+	case *nodes.BinaryExpression:
+		return c.binary(n)
+	case *nodes.UnaryExpression:
+		term := c.child(n.Term)
+		return func(e *Evaluator) *Value {
+			return unary(n, term(e))
+		}
+	case *nodes.FilteredExpression:
+		return c.filtered(n)
+	case *nodes.TestExpression:
+		return c.test(n)
+	case *nodes.InlineIfExpression:
+		condition := c.child(n.Condition)
+		trueBranch := c.child(n.TrueBranch)
+		falseBranch := c.child(n.FalseBranch)
+		return func(e *Evaluator) *Value {
+			value := condition(e)
+			if value.IsError() && IsUndefinedError(value) {
+				return AsValue(errors.Wrapf(value, `Unable to evaluate condition %s`, n.Condition))
+			}
+			if value.IsTrue() {
+				return trueBranch(e)
+			}
+			return falseBranch(e)
+		}
+	}
+	return nil
+}
+
+// literal returns a new value on each evaluation as values might be updated,
+// ie. by the 'safe' filter, sharing the reflected literal
+func literal(val interface{}) compiled {
+	rv := reflect.ValueOf(val)
+	return func(e *Evaluator) *Value {
+		return &Value{Val: rv}
+	}
+}
+
+func name(node *nodes.Name) compiled {
+	name := node.Name.Val
+	return func(e *Evaluator) *Value {
+		val, found := e.Ctx.lookup(name)
+		if !found {
+			return e.undefinedValue(node)
+		}
+		return ToValue(val)
+	}
+}
+
+// callArgs holds the compiled arguments of a call, nil when interpreted
+type callArgs struct {
+	args   []compiled
+	kwargs map[string]compiled
+}
+
+func (c *compiler) callArgs(node *nodes.Call) *callArgs {
+	args := &callArgs{
+		args:   c.expressions(node.Args),
+		kwargs: make(map[string]compiled, len(node.Kwargs)),
+	}
+	for key, kwarg := range node.Kwargs {
+		args.kwargs[key] = c.child(kwarg)
+	}
+	return args
+}
+
+func (a *callArgs) arg(e *Evaluator, idx int, node nodes.Expression) *Value {
+	if a == nil {
+		return e.Eval(node)
+	}
+	return a.args[idx](e)
+}
+
+func (a *callArgs) kwarg(e *Evaluator, key string, node nodes.Expression) *Value {
+	if a == nil {
+		return e.Eval(node)
+	}
+	return a.kwargs[key](e)
+}
+
+// attrKind tells how an attribute is resolved on a given type
+type attrKind int
+
+const (
+	attrInterpreted attrKind = iota // left to Evaluator.getattr
+	attrField
+	attrMethod
+	attrKey
+)
+
+// attrPath is the resolution of an attribute on a given type
+type attrPath struct {
+	typ    reflect.Type
+	kind   attrKind
+	field  []int // index of the struct field
+	method int   // index of the method
+}
+
+// attrCache resolves an attribute as Value.Getattr does, remembering the
+// resolution for the type last seen. Lookups the cache can't answer,
+// ie. missing attributes, return nil and are left to the interpreter.
+type attrCache struct {
+	name string
+	key  reflect.Value
+	last atomic.Pointer[attrPath]
+}
+
+var (
+	typeOfError      = reflect.TypeOf((*error)(nil)).Elem()
+	typeOfAttrGetter = reflect.TypeOf((*AttrGetter)(nil)).Elem()
+	typeOfString     = reflect.TypeOf("")
+)
+
+func newAttrCache(name string) *attrCache {
+	return &attrCache{name: name, key: reflect.ValueOf(name)}
+}
+
+func (c *attrCache) get(value *Value) *Value {
+	val := value.Val
+	if !val.IsValid() || value.undefined != nil {
+		return nil
+	}
+	path := c.last.Load()
+	if path == nil || path.typ != val.Type() {
+		path = c.resolve(val.Type())
+		c.last.Store(path)
+	}
+	if path.kind == attrInterpreted || val.Kind() == reflect.Ptr && val.IsNil() {
+		return nil
+	}
+	if path.kind == attrMethod {
+		return ToValue(val.Method(path.method))
+	}
+	if val.Kind() == reflect.Ptr {
+		val = val.Elem()
+	}
+	switch path.kind {
+	case attrField:
+		field, err := val.FieldByIndexErr(path.field)
+		if err != nil || !field.IsValid() {
+			return nil
+		}
+		return ToValue(field)
+	case attrKey:
+		item := val.MapIndex(c.key)
+		if !item.IsValid() {
+			return nil
+		}
+		return ToValue(item)
+	}
+	return nil
+}
+
+// resolve mirrors the lookup order of Value.Getattr then Value.Getitem
+func (c *attrCache) resolve(typ reflect.Type) *attrPath {
+	path := &attrPath{typ: typ}
+	resolved := typ
+	if resolved.Kind() == reflect.Ptr {
+		resolved = resolved.Elem()
+	}
+	if typ == typeOfValuePtr || typ.Implements(typeOfAttrGetter) ||
+		typ.Implements(typeOfError) || resolved.Implements(typeOfError) {
+		return path
+	}
+
+	switch resolved.Kind() {
+	case reflect.Struct:
+		if resolved == TypeDict {
+			return path
+		}
+		if field, ok := resolved.FieldByName(c.name); ok {
+			path.kind = attrField
+			path.field = field.Index
+			return path
+		}
+	case reflect.Map:
+		switch c.name {
+		case "get", "update", "items":
+			return path
+		}
+	default:
+		return path
+	}
+
+	if method, ok := typ.MethodByName(c.name); ok {
+		path.kind = attrMethod
+		path.method = method.Index
+	} else if method, ok := typ.MethodByName(strings.Title(c.name)); ok {
+		path.kind = attrMethod
+		path.method = method.Index
+	} else if resolved.Kind() == reflect.Map && resolved.Key() == typeOfString {
+		path.kind = attrKey
+	}
+	return path
+}
+
+func (c *compiler) list(items []nodes.Expression) compiled {
+	fns := c.expressions(items)
+	return func(e *Evaluator) *Value {
+		values := make(ValuesList, 0, len(fns))
+		for _, fn := range fns {
+			values = append(values, fn(e))
+		}
+		return AsValue(&values)
+	}
+}
+
+// pair is not accounted for as an evaluation step within a dict
+func (c *compiler) pair(node *nodes.Pair) compiled {
+	key := c.child(node.Key)
+	value := c.child(node.Value)
+	return func(e *Evaluator) *Value {
+		k := key(e)
+		if k.IsError() {
+			return AsValue(errors.Wrapf(k, `Unable to evaluate key "%s"`, node.Key))
+		}
+		v := value(e)
+		if v.IsError() {
+			return AsValue(errors.Wrapf(v, `Unable to evaluate value "%s"`, node.Value))
+		}
+		return AsValue(&Pair{k, v})
+	}
+}
+
+func (c *compiler) dict(node *nodes.Dict) compiled {
+	pairs := make([]compiled, 0, len(node.Pairs))
+	for _, pair := range node.Pairs {
+		pairs = append(pairs, c.pair(pair))
+	}
+	return func(e *Evaluator) *Value {
+		dict := &Dict{Pairs: make([]*Pair, 0, len(pairs))}
+		for idx, pair := range pairs {
+			p := pair(e)
+			if p.IsError() {
+				return AsValue(errors.Wrapf(p, `Unable to evaluate pair "%s"`, node.Pairs[idx]))
+			}
+			dict.Pairs = append(dict.Pairs, p.Interface().(*Pair))
+		}
+		return AsValue(dict)
+	}
+}
+
+func (c *compiler) binary(node *nodes.BinaryExpression) compiled {
+	left := c.child(node.Left)
+	right := c.child(node.Right)
+	evalLeft := func(e *Evaluator) *Value {
+		value := left(e)
+		if value.IsError() {
+			return AsValue(errors.Wrapf(value, `Unable to evaluate left parameter %s`, node.Left))
+		}
+		return value
+	}
+
+	switch node.Operator.Token.Val {
+	case "and":
+		return func(e *Evaluator) *Value {
+			l := evalLeft(e)
+			if l.IsError() {
+				return l
+			}
+			if !l.IsTrue() {
+				return AsValue(false)
+			}
+			return e.evalLogicalRight(node, right(e))
+		}
+	case "or":
+		return func(e *Evaluator) *Value {
+			l := evalLeft(e)
+			if l.IsError() {
+				return l
+			}
+			if l.IsTrue() {
+				return AsValue(true)
+			}
+			return e.evalLogicalRight(node, right(e))
+		}
+	}
+
+	operation, ok := binaryOperations[node.Operator.Token.Val]
+	return func(e *Evaluator) *Value {
+		l := evalLeft(e)
+		if l.IsError() {
+			return l
+		}
+		r := right(e)
+		if r.IsError() {
+			return AsValue(errors.Wrapf(r, `Unable to evaluate right parameter %s`, node.Right))
+		}
+		if !ok {
+			return AsValue(errors.Errorf(`Unknown operator "%s"`, node.Operator.Token))
+		}
+		return operation(node, l, r)
+	}
+}
+
+// compiledParams evaluates the arguments of a filter or a test call
+type compiledParams struct {
+	args   []compiled
+	kwargs map[string]compiled
+	nodes  []nodes.Expression
+	keys   map[string]nodes.Expression
+	named  bool // whether kwargs errors mention the key, as filters do
+}
+
+func (c *compiler) params(args []nodes.Expression, kwargs map[string]nodes.Expression, named bool) *compiledParams {
+	params := &compiledParams{
+		args:   c.expressions(args),
+		kwargs: make(map[string]compiled, len(kwargs)),
+		nodes:  args,
+		keys:   kwargs,
+		named:  named,
+	}
+	for key, kwarg := range kwargs {
+		params.kwargs[key] = c.child(kwarg)
+	}
+	return params
+}
+
+// eval mirrors Evaluator.filterParams and Evaluator.testParams
+func (p *compiledParams) eval(e *Evaluator) (*VarArgs, *Value) {
+	params := &VarArgs{
+		Args:   make([]*Value, 0, len(p.args)),
+		KwArgs: make(map[string]*Value, len(p.kwargs)),
+	}
+	for idx, arg := range p.args {
+		value := arg(e)
+		if value.IsError() {
+			return nil, AsValue(errors.Wrapf(value, `Unable to evaluate parameter %s`, p.nodes[idx]))
+		}
+		params.Args = append(params.Args, value)
+	}
+	for key, kwarg := range p.kwargs {
+		value := kwarg(e)
+		if value.IsError() {
+			if p.named {
+				return nil, AsValue(errors.Wrapf(value, `Unable to evaluate parameter %s=%s`, key, p.keys[key]))
+			}
+			return nil, AsValue(errors.Wrapf(value, `Unable to evaluate parameter %s`, p.keys[key]))
+		}
+		params.KwArgs[key] = value
+	}
+	return params, nil
+}
+
+// compiledFilter is a filter call whose arguments have been compiled
+type compiledFilter struct {
+	call      *nodes.FilterCall
+	params    *compiledParams
+	undefined bool // whether the filter accepts undefined values
+}
+
+func (c *compiler) filtered(node *nodes.FilteredExpression) compiled {
+	expr := c.child(node.Expression)
+	filters := make([]compiledFilter, 0, len(node.Filters))
+	for _, call := range node.Filters {
+		filters = append(filters, compiledFilter{
+			call:      call,
+			params:    c.params(call.Args, call.Kwargs, true),
+			undefined: undefinedFilters[call.Name],
+		})
+	}
+	return func(e *Evaluator) *Value {
+		value := expr(e)
+		for _, filter := range filters {
+			if err := rejectUndefined(value, filter.undefined); err != nil {
+				return AsValue(errors.Wrapf(err, `Unable to evaluate filter %s`, filter.call))
+			}
+			params, err := filter.params.eval(e)
+			if err != nil {
+				value = err
+			} else {
+				value = e.ExecuteFilterByName(filter.call.Name, value, params)
+			}
+			if value.IsError() {
+				return AsValue(errors.Wrapf(value, `Unable to evaluate filter %s`, filter.call))
+			}
+		}
+		return value
+	}
+}
+
+func (c *compiler) test(node *nodes.TestExpression) compiled {
+	expr := c.child(node.Expression)
+	call := node.Test
+	args := c.params(call.Args, call.Kwargs, false)
+	undefined := undefinedTests[call.Name]
+	return func(e *Evaluator) *Value {
+		value := expr(e)
+		if err := rejectUndefined(value, undefined); err != nil {
+			return AsValue(errors.Wrapf(err, `Unable to evaluate expresion %s`, node.Expression))
+		}
+		params, err := args.eval(e)
+		if err != nil {
+			return err
+		}
+		return e.ExecuteTestByName(call.Name, value, params)
+	}
+}
@@ -14,6 +14,8 @@ type EvalConfig struct {
 	Statements *StatementSet
 	Tests      *TestSet
 	Loader     TemplateLoader
+	Limits     *Limits
+	Undefined  UndefinedPolicy
 }
 
 func NewEvalConfig(cfg *config.Config) *EvalConfig {
@@ -34,6 +36,8 @@ func (cfg *EvalConfig) Inherit() *EvalConfig {
 		Statements: cfg.Statements,
 		Tests:      cfg.Tests,
 		Loader:     cfg.Loader,
+		Limits:     cfg.Limits,
+		Undefined:  cfg.Undefined,
 	}
 }
 
@@ -44,3 +48,12 @@ func (cfg *EvalConfig) GetTemplate(filename string) (*nodes.Template, error) {
 	}
 	return tpl.Root, nil
 }
+
+// ResolveTemplate returns the name of the template name referenced by
+// the template base, see TemplateResolver.
+func (cfg *EvalConfig) ResolveTemplate(base, name string) string {
+	if resolver, ok := cfg.Loader.(TemplateResolver); ok {
+		return resolver.ResolveTemplate(base, name)
+	}
+	return name
+}
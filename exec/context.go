@@ -32,6 +32,16 @@ func (ctx *Context) Get(name string) interface{} {
 	}
 }
 
+// lookup returns the value of name and whether it is set, walking the context once
+func (ctx *Context) lookup(name string) (interface{}, bool) {
+	for ; ctx != nil; ctx = ctx.parent {
+		if value, exists := ctx.data[name]; exists {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
 func (ctx *Context) Set(name string, value interface{}) {
 	ctx.data[name] = value
 }
@@ -1,6 +1,7 @@
 package exec
 
 import (
+	"context"
 	"math"
 	"reflect"
 	"strings"
@@ -13,18 +14,28 @@ import (
 var (
 	typeOfValuePtr   = reflect.TypeOf(new(Value))
 	typeOfExecCtxPtr = reflect.TypeOf(new(Context))
+	typeOfVarArgsPtr = reflect.TypeOf(new(VarArgs))
 )
 
 type Evaluator struct {
 	*EvalConfig
-	Ctx *Context
+	Ctx     *Context
+	Context context.Context
+	usage   *usage
+	program *Program
 }
 
 func (r *Renderer) Evaluator() *Evaluator {
-	return &Evaluator{
+	e := &Evaluator{
 		EvalConfig: r.EvalConfig,
 		Ctx:        r.Ctx,
+		Context:    r.Context,
+		usage:      r.usage,
 	}
+	if r.Template != nil {
+		e.program = r.Template.Program
+	}
+	return e
 }
 
 func (r *Renderer) Eval(node nodes.Expression) *Value {
@@ -32,7 +43,14 @@ func (r *Renderer) Eval(node nodes.Expression) *Value {
 	return e.Eval(node)
 }
 
+// Eval evaluates an expression, using its compiled version if any (see Compile)
 func (e *Evaluator) Eval(node nodes.Expression) *Value {
+	if fn := e.program.lookup(node); fn != nil {
+		return fn(e)
+	}
+	if err := e.usage.step(node); err != nil {
+		return AsValue(err)
+	}
 	switch n := node.(type) {
 	case *nodes.String:
 		return AsValue(n.Val)
@@ -79,7 +97,11 @@ func (e *Evaluator) Eval(node nodes.Expression) *Value {
 	case *nodes.TestExpression:
 		return e.EvalTest(n)
 	case *nodes.InlineIfExpression:
-		if e.Eval(n.Condition).IsTrue() {
+		condition := e.Eval(n.Condition)
+		if condition.IsError() && IsUndefinedError(condition) {
+			return AsValue(errors.Wrapf(condition, `Unable to evaluate condition %s`, n.Condition))
+		}
+		if condition.IsTrue() {
 			return e.Eval(n.TrueBranch)
 		} else {
 			return e.Eval(n.FalseBranch)
@@ -90,27 +112,49 @@ func (e *Evaluator) Eval(node nodes.Expression) *Value {
 }
 
 func (e *Evaluator) evalBinaryExpression(node *nodes.BinaryExpression) *Value {
-	var (
-		left  *Value
-		right *Value
-	)
-	left = e.Eval(node.Left)
+	left := e.Eval(node.Left)
 	if left.IsError() {
 		return AsValue(errors.Wrapf(left, `Unable to evaluate left parameter %s`, node.Left))
 	}
 
 	switch node.Operator.Token.Val {
 	// These operators allow lazy right expression evaluation
-	case "and", "or":
-	default:
-		right = e.Eval(node.Right)
-		if right.IsError() {
-			return AsValue(errors.Wrapf(right, `Unable to evaluate right parameter %s`, node.Right))
+	case "and":
+		if !left.IsTrue() {
+			return AsValue(false)
 		}
+		return e.evalLogicalRight(node, e.Eval(node.Right))
+	case "or":
+		if left.IsTrue() {
+			return AsValue(true)
+		}
+		return e.evalLogicalRight(node, e.Eval(node.Right))
 	}
 
-	switch node.Operator.Token.Val {
-	case "+":
+	right := e.Eval(node.Right)
+	if right.IsError() {
+		return AsValue(errors.Wrapf(right, `Unable to evaluate right parameter %s`, node.Right))
+	}
+	operation, ok := binaryOperations[node.Operator.Token.Val]
+	if !ok {
+		return AsValue(errors.Errorf(`Unknown operator "%s"`, node.Operator.Token))
+	}
+	return operation(node, left, right)
+}
+
+// evalLogicalRight returns the result of 'and' and 'or' once evaluated their right parameter
+func (e *Evaluator) evalLogicalRight(node *nodes.BinaryExpression, right *Value) *Value {
+	if right.IsError() {
+		return AsValue(errors.Wrapf(right, `Unable to evaluate right parameter %s`, node.Right))
+	}
+	return AsValue(right.IsTrue())
+}
+
+// binaryOperation computes a binary operator but 'and' and 'or'
+type binaryOperation func(node *nodes.BinaryExpression, left, right *Value) *Value
+
+var binaryOperations = map[string]binaryOperation{
+	"+": func(node *nodes.BinaryExpression, left, right *Value) *Value {
 		if left.IsList() {
 			if !right.IsList() {
 				return AsValue(errors.Wrapf(right, `Unable to concatenate list to %s`, node.Right))
@@ -138,14 +182,16 @@ func (e *Evaluator) evalBinaryExpression(node *nodes.BinaryExpression) *Value {
 		}
 		// Result will be an integer
 		return AsValue(left.Integer() + right.Integer())
-	case "-":
+	},
+	"-": func(node *nodes.BinaryExpression, left, right *Value) *Value {
 		if left.IsFloat() || right.IsFloat() {
 			// Result will be a float
 			return AsValue(left.Float() - right.Float())
 		}
 		// Result will be an integer
 		return AsValue(left.Integer() - right.Integer())
-	case "*":
+	},
+	"*": func(node *nodes.BinaryExpression, left, right *Value) *Value {
 		if left.IsFloat() || right.IsFloat() {
 			// Result will be float
 			return AsValue(left.Float() * right.Float())
@@ -155,72 +201,72 @@ func (e *Evaluator) evalBinaryExpression(node *nodes.BinaryExpression) *Value {
 		}
 		// Result will be int
 		return AsValue(left.Integer() * right.Integer())
-	case "/":
+	},
+	"/": func(node *nodes.BinaryExpression, left, right *Value) *Value {
 		// Float division
 		return AsValue(left.Float() / right.Float())
-	case "//":
+	},
+	"//": func(node *nodes.BinaryExpression, left, right *Value) *Value {
 		// Int division
 		return AsValue(int(left.Float() / right.Float()))
-	case "%":
+	},
+	"%": func(node *nodes.BinaryExpression, left, right *Value) *Value {
 		// Result will be int
 		return AsValue(left.Integer() % right.Integer())
-	case "**":
+	},
+	"**": func(node *nodes.BinaryExpression, left, right *Value) *Value {
 		return AsValue(math.Pow(left.Float(), right.Float()))
-	case "~":
+	},
+	"~": func(node *nodes.BinaryExpression, left, right *Value) *Value {
 		return AsValue(strings.Join([]string{left.String(), right.String()}, ""))
-	case "and":
-		if !left.IsTrue() {
-			return AsValue(false)
-		}
-		right = e.Eval(node.Right)
-		if right.IsError() {
-			return AsValue(errors.Wrapf(right, `Unable to evaluate right parameter %s`, node.Right))
-		}
-		return AsValue(right.IsTrue())
-	case "or":
-		if left.IsTrue() {
-			return AsValue(true)
-		}
-		right = e.Eval(node.Right)
-		if right.IsError() {
-			return AsValue(errors.Wrapf(right, `Unable to evaluate right parameter %s`, node.Right))
-		}
-		return AsValue(right.IsTrue())
-	case "<=":
+	},
+	"<=": func(node *nodes.BinaryExpression, left, right *Value) *Value {
 		if left.IsFloat() || right.IsFloat() {
 			return AsValue(left.Float() <= right.Float())
 		}
 		return AsValue(left.Integer() <= right.Integer())
-	case ">=":
+	},
+	">=": func(node *nodes.BinaryExpression, left, right *Value) *Value {
 		if left.IsFloat() || right.IsFloat() {
 			return AsValue(left.Float() >= right.Float())
 		}
 		return AsValue(left.Integer() >= right.Integer())
-	case "==":
+	},
+	"==": func(node *nodes.BinaryExpression, left, right *Value) *Value {
 		return AsValue(left.EqualValueTo(right))
-	case ">":
+	},
+	">": func(node *nodes.BinaryExpression, left, right *Value) *Value {
 		if left.IsFloat() || right.IsFloat() {
 			return AsValue(left.Float() > right.Float())
 		}
 		return AsValue(left.Integer() > right.Integer())
-	case "<":
+	},
+	"<": func(node *nodes.BinaryExpression, left, right *Value) *Value {
 		if left.IsFloat() || right.IsFloat() {
 			return AsValue(left.Float() < right.Float())
 		}
 		return AsValue(left.Integer() < right.Integer())
-	case "!=", "<>":
-		return AsValue(!left.EqualValueTo(right))
-	case "in":
+	},
+	"!=": notEqual,
+	"<>": notEqual,
+	"in": func(node *nodes.BinaryExpression, left, right *Value) *Value {
 		return AsValue(right.Contains(left))
-	case "is":
+	},
+	"is": func(node *nodes.BinaryExpression, left, right *Value) *Value {
 		return nil
-	default:
-		return AsValue(errors.Errorf(`Unknown operator "%s"`, node.Operator.Token))
-	}
+	},
+}
+
+func notEqual(node *nodes.BinaryExpression, left, right *Value) *Value {
+	return AsValue(!left.EqualValueTo(right))
 }
 
 func (e *Evaluator) evalUnaryExpression(expr *nodes.UnaryExpression) *Value {
-	result := e.Eval(expr.Term)
+	return unary(expr, e.Eval(expr.Term))
+}
+
+// unary applies the sign of expr to its evaluated term
+func unary(expr *nodes.UnaryExpression, result *Value) *Value {
 	if result.IsError() {
 		return AsValue(errors.Wrapf(result, `Unable to evaluate term %s`, expr.Term))
 	}
@@ -284,17 +330,30 @@ func (e *Evaluator) evalPair(node *nodes.Pair) *Value {
 }
 
 func (e *Evaluator) evalName(node *nodes.Name) *Value {
-	val := e.Ctx.Get(node.Name.Val)
+	val, found := e.Ctx.lookup(node.Name.Val)
+	if !found {
+		return e.undefinedValue(node)
+	}
 	return ToValue(val)
 }
 
 func (e *Evaluator) evalGetitem(node *nodes.Getitem) *Value {
-	value := e.Eval(node.Node)
+	return e.getitem(node, e.Eval(node.Node))
+}
+
+// getitem returns the item of the evaluated target of node
+func (e *Evaluator) getitem(node *nodes.Getitem, value *Value) *Value {
 	if value.IsError() {
 		return AsValue(errors.Wrapf(value, `Unable to evaluate target %s`, node.Node))
 	}
 
 	if node.Arg != nil {
+		if value.IsUndefined() {
+			if err := e.checkUndefined(value, node); err != nil {
+				return err
+			}
+			return e.undefinedValue(node)
+		}
 		key := e.Eval(*node.Arg)
 		item, found := value.Getitem(key.Interface())
 		if !found {
@@ -311,8 +370,13 @@ func (e *Evaluator) evalGetitem(node *nodes.Getitem) *Value {
 		return AsValue(errors.Errorf(`No Arg was given`))
 	}
 }
+
 func (e *Evaluator) evalGetitemrange(node *nodes.Getitemrange) *Value {
-	value := e.Eval(node.Node)
+	return e.getitemrange(node, e.Eval(node.Node))
+}
+
+// getitemrange returns the slice of the evaluated target of node
+func (e *Evaluator) getitemrange(node *nodes.Getitemrange, value *Value) *Value {
 	if value.IsError() {
 		return AsValue(errors.Wrapf(value, `Unable to evaluate target %s`, node.Node))
 	}
@@ -379,11 +443,22 @@ func (e *Evaluator) evalGetitemrange(node *nodes.Getitemrange) *Value {
 }
 
 func (e *Evaluator) evalGetattr(node *nodes.Getattr) *Value {
-	value := e.Eval(node.Node)
+	return e.getattr(node, e.Eval(node.Node))
+}
+
+// getattr returns the attribute of the evaluated target of node
+func (e *Evaluator) getattr(node *nodes.Getattr, value *Value) *Value {
 	if value.IsError() {
 		return AsValue(errors.Wrapf(value, `Unable to evaluate target %s`, node.Node))
 	}
 
+	if value.IsUndefined() {
+		if err := e.checkUndefined(value, node); err != nil {
+			return err
+		}
+		return e.undefinedValue(node)
+	}
+
 	if node.Attr != "" {
 		attr, found := value.Getattr(node.Attr)
 		if !found {
@@ -393,7 +468,7 @@ func (e *Evaluator) evalGetattr(node *nodes.Getattr) *Value {
 			if attr.IsError() {
 				return AsValue(errors.Wrapf(attr, `Unable to evaluate %s`, node))
 			}
-			return AsValue(nil)
+			return e.undefinedValue(node)
 			// return AsValue(errors.Errorf(`Unable to evaluate %s: attribute '%s' not found`, node, node.Attr))
 		}
 		return attr
@@ -403,7 +478,7 @@ func (e *Evaluator) evalGetattr(node *nodes.Getattr) *Value {
 			if item.IsError() {
 				return AsValue(errors.Wrapf(item, `Unable to evaluate %s`, node))
 			}
-			return AsValue(nil)
+			return e.undefinedValue(node)
 			// return AsValue(errors.Errorf(`Unable to evaluate %s: item %d not found`, node, node.Index))
 		}
 		return item
@@ -412,7 +487,12 @@ func (e *Evaluator) evalGetattr(node *nodes.Getattr) *Value {
 }
 
 func (e *Evaluator) evalCall(node *nodes.Call) *Value {
-	fn := e.Eval(node.Func)
+	return e.call(node, e.Eval(node.Func), nil)
+}
+
+// call calls the evaluated function of node,
+// its arguments being interpreted unless compiled ones are given
+func (e *Evaluator) call(node *nodes.Call, fn *Value, args *callArgs) *Value {
 	if fn.IsError() {
 		return AsValue(errors.Wrapf(fn, `Unable to evaluate function "%s"`, node.Func))
 	}
@@ -431,10 +511,10 @@ func (e *Evaluator) evalCall(node *nodes.Call) *Value {
 	callable := fn.Callable()
 	t := callable.Type()
 
-	if t.NumIn() == 1 && t.In(0) == reflect.TypeOf(&VarArgs{}) {
-		params, err = e.evalVarArgs(node)
+	if t.NumIn() == 1 && t.In(0) == typeOfVarArgsPtr {
+		params, err = e.evalVarArgs(node, args)
 	} else {
-		params, err = e.evalParams(node, fn)
+		params, err = e.evalParams(node, fn, args)
 	}
 	if err != nil {
 		return AsValue(errors.Wrapf(err, `Unable to evaluate parameters`))
@@ -610,13 +690,18 @@ func (e *Evaluator) evalVariable(node *nodes.Variable) (*Value, error) {
 	return &Value{Val: current, Safe: isSafe}, nil
 }
 
-func (e *Evaluator) evalVarArgs(node *nodes.Call) ([]reflect.Value, error) {
+func (e *Evaluator) evalVarArgs(node *nodes.Call, args *callArgs) ([]reflect.Value, error) {
+	ctx := e.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
 	params := &VarArgs{
-		Args:   []*Value{},
-		KwArgs: map[string]*Value{},
+		Args:    []*Value{},
+		KwArgs:  map[string]*Value{},
+		Context: context.WithValue(ctx, evalConfigKey{}, e.EvalConfig),
 	}
-	for _, param := range node.Args {
-		value := e.Eval(param)
+	for idx, param := range node.Args {
+		value := args.arg(e, idx, param)
 		if value.IsError() {
 			return nil, value
 		}
@@ -644,7 +729,7 @@ func (e *Evaluator) evalVarArgs(node *nodes.Call) ([]reflect.Value, error) {
 	}
 
 	for key, param := range node.Kwargs {
-		value := e.Eval(param)
+		value := args.kwarg(e, key, param)
 		if value.IsError() {
 			return nil, value
 		}
@@ -655,7 +740,7 @@ func (e *Evaluator) evalVarArgs(node *nodes.Call) ([]reflect.Value, error) {
 	return []reflect.Value{reflect.ValueOf(params)}, nil
 }
 
-func (e *Evaluator) evalParams(node *nodes.Call, fn *Value) ([]reflect.Value, error) {
+func (e *Evaluator) evalParams(node *nodes.Call, fn *Value, compiled *callArgs) ([]reflect.Value, error) {
 	args := node.Args
 	t := fn.Val.Type()
 
@@ -678,7 +763,7 @@ func (e *Evaluator) evalParams(node *nodes.Call, fn *Value) ([]reflect.Value, er
 	var fnArg reflect.Type
 
 	for idx, arg := range args {
-		pv := e.Eval(arg)
+		pv := compiled.arg(e, idx, arg)
 		if pv.IsError() {
 			return nil, pv
 		}
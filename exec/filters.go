@@ -54,6 +54,9 @@ func (e *Evaluator) EvaluateFiltered(expr *nodes.FilteredExpression) *Value {
 	value := e.Eval(expr.Expression)
 
 	for _, filter := range expr.Filters {
+		if err := rejectUndefined(value, undefinedFilters[filter.Name]); err != nil {
+			return AsValue(errors.Wrapf(err, `Unable to evaluate filter %s`, filter))
+		}
 		value = e.ExecuteFilter(filter, value)
 		if value.IsError() {
 			return AsValue(errors.Wrapf(value, `Unable to evaluate filter %s`, filter))
@@ -68,12 +71,21 @@ func (e *Evaluator) EvaluateFiltered(expr *nodes.FilteredExpression) *Value {
 
 // ExecuteFilter execute a filter node
 func (e *Evaluator) ExecuteFilter(fc *nodes.FilterCall, v *Value) *Value {
+	params, err := e.filterParams(fc)
+	if err != nil {
+		return err
+	}
+	return e.ExecuteFilterByName(fc.Name, v, params)
+}
+
+// filterParams evaluates the arguments of a filter call
+func (e *Evaluator) filterParams(fc *nodes.FilterCall) (*VarArgs, *Value) {
 	params := NewVarArgs()
 
 	for _, param := range fc.Args {
 		value := e.Eval(param)
 		if value.IsError() {
-			return AsValue(errors.Wrapf(value, `Unable to evaluate parameter %s`, param))
+			return nil, AsValue(errors.Wrapf(value, `Unable to evaluate parameter %s`, param))
 		}
 		params.Args = append(params.Args, value)
 	}
@@ -81,11 +93,11 @@ func (e *Evaluator) ExecuteFilter(fc *nodes.FilterCall, v *Value) *Value {
 	for key, param := range fc.Kwargs {
 		value := e.Eval(param)
 		if value.IsError() {
-			return AsValue(errors.Wrapf(value, `Unable to evaluate parameter %s=%s`, key, param))
+			return nil, AsValue(errors.Wrapf(value, `Unable to evaluate parameter %s=%s`, key, param))
 		}
 		params.KwArgs[key] = value
 	}
-	return e.ExecuteFilterByName(fc.Name, v, params)
+	return params, nil
 }
 
 // ExecuteFilterByName execute a filter given its name
@@ -0,0 +1,163 @@
+package exec
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/paradime-io/gonja/tokens"
+)
+
+// Limits bounds the resources a single rendering may consume.
+// A zero value disables the corresponding limit.
+type Limits struct {
+	// MaxOutputSize is the maximum number of rendered bytes
+	MaxOutputSize int
+	// MaxLoopIterations is the maximum number of loop iterations, all loops included
+	MaxLoopIterations int
+	// MaxRecursionDepth is the maximum nesting of macro calls and includes
+	MaxRecursionDepth int
+	// MaxEvalSteps is the maximum number of evaluated expressions
+	MaxEvalSteps int
+}
+
+// LimitExceededError is returned when a rendering exceeds one of its Limits
+type LimitExceededError struct {
+	Limit    string
+	Max      int
+	Template string
+	Token    *tokens.Token
+}
+
+func (e *LimitExceededError) Error() string {
+	msg := fmt.Sprintf("Limit exceeded: %s (max %d)", e.Limit, e.Max)
+	if e.Template != "" {
+		msg = fmt.Sprintf("%s in '%s'", msg, e.Template)
+	}
+	if e.Token != nil {
+		msg = fmt.Sprintf("%s (Line: %d Col: %d)", msg, e.Token.Line, e.Token.Col)
+	}
+	return msg
+}
+
+// usage tracks the resources consumed by a rendering.
+// It is shared by a renderer and all its sub renderers.
+type usage struct {
+	limits     *Limits
+	out        io.StringWriter
+	output     int
+	iterations int
+	depth      int
+	steps      int
+
+	template string
+	current  *tokens.Token
+	err      error
+}
+
+func newUsage(limits *Limits, out io.StringWriter) *usage {
+	return &usage{limits: limits, out: out}
+}
+
+func (u *usage) at(tpl *Template, tok *tokens.Token) {
+	if tpl != nil {
+		u.template = tpl.Name
+	}
+	u.current = tok
+}
+
+func (u *usage) exceed(limit string, max int, tok *tokens.Token) error {
+	if tok == nil {
+		tok = u.current
+	}
+	if u.err == nil {
+		u.err = &LimitExceededError{
+			Limit:    limit,
+			Max:      max,
+			Template: u.template,
+			Token:    tok,
+		}
+	}
+	return u.err
+}
+
+func (u *usage) write(size int) error {
+	if u == nil || u.limits == nil || u.limits.MaxOutputSize <= 0 {
+		return nil
+	}
+	u.output += size
+	if u.output > u.limits.MaxOutputSize {
+		return u.exceed("output size", u.limits.MaxOutputSize, nil)
+	}
+	return nil
+}
+
+func (u *usage) iterate(tok *tokens.Token) error {
+	if u == nil || u.limits == nil || u.limits.MaxLoopIterations <= 0 {
+		return nil
+	}
+	u.iterations++
+	if u.iterations > u.limits.MaxLoopIterations {
+		return u.exceed("loop iterations", u.limits.MaxLoopIterations, tok)
+	}
+	return nil
+}
+
+func (u *usage) step(node interface{ Position() *tokens.Token }) error {
+	if u == nil || u.limits == nil || u.limits.MaxEvalSteps <= 0 {
+		return nil
+	}
+	u.steps++
+	if u.steps > u.limits.MaxEvalSteps {
+		return u.exceed("evaluation steps", u.limits.MaxEvalSteps, node.Position())
+	}
+	return nil
+}
+
+// Exceeded returns the *LimitExceededError raised during the rendering, if any
+func (r *Renderer) Exceeded() error {
+	if r.usage == nil {
+		return nil
+	}
+	return r.usage.err
+}
+
+// CountIteration accounts for one more loop iteration started at tok
+func (r *Renderer) CountIteration(tok *tokens.Token) error {
+	return r.usage.iterate(tok)
+}
+
+// RemainingIterations returns the number of loop iterations left before
+// exceeding the limit, or -1 when loop iterations are not limited
+func (r *Renderer) RemainingIterations() int {
+	u := r.usage
+	if u == nil || u.limits == nil || u.limits.MaxLoopIterations <= 0 {
+		return -1
+	}
+	if u.iterations >= u.limits.MaxLoopIterations {
+		return 0
+	}
+	return u.limits.MaxLoopIterations - u.iterations
+}
+
+// PushRecursion must be called when entering a macro or an included template at tok.
+// Each successful call must be balanced by a call to PopRecursion.
+func (r *Renderer) PushRecursion(tok *tokens.Token) error {
+	u := r.usage
+	if u == nil || u.limits == nil || u.limits.MaxRecursionDepth <= 0 {
+		return nil
+	}
+	if u.depth >= u.limits.MaxRecursionDepth {
+		return u.exceed("recursion depth", u.limits.MaxRecursionDepth, tok)
+	}
+	u.depth++
+	return nil
+}
+
+// PopRecursion leaves a macro or an included template
+func (r *Renderer) PopRecursion() {
+	u := r.usage
+	if u == nil || u.limits == nil || u.limits.MaxRecursionDepth <= 0 {
+		return
+	}
+	u.depth--
+}
@@ -150,6 +150,13 @@ func MacroNodeToFunc(node *nodes.Macro, r *Renderer) (func(params *VarArgs) *Val
 	}
 
 	return func(params *VarArgs) *Value {
+		if err := r.Cancelled(); err != nil {
+			return AsValue(err)
+		}
+		if err := r.PushRecursion(node.Position()); err != nil {
+			return AsValue(err)
+		}
+		defer r.PopRecursion()
 		if err := init(); err != nil {
 			return AsValue(err)
 		}
@@ -158,6 +165,13 @@ func MacroNodeToFunc(node *nodes.Macro, r *Renderer) (func(params *VarArgs) *Val
 		sub := r.Inherit()
 		sub.Out = &out
 
+		// The body given by a call block is exposed as 'caller'
+		// unless the macro declares an argument with this name
+		params, caller := extractCaller(params, node)
+		if caller != nil {
+			sub.Ctx.Set(CallerName, caller)
+		}
+
 		mapping, mappingErr := TransformToMapping(params, node.Args, defaultKwargs, activateVarargs, activateKwargs)
 		if mappingErr != nil {
 			return AsValue(errors.Wrapf(mappingErr, `Wrong '%s' macro signature`, node.Name))
@@ -172,3 +186,32 @@ func MacroNodeToFunc(node *nodes.Macro, r *Renderer) (func(params *VarArgs) *Val
 		return AsSafeValue(out.String())
 	}, nil
 }
+
+// CallerName is the keyword argument through which a call block
+// passes its body to the called macro
+const CallerName = "caller"
+
+func extractCaller(params *VarArgs, node *nodes.Macro) (*VarArgs, *Value) {
+	caller, ok := params.KwArgs[CallerName]
+	if !ok {
+		return params, nil
+	}
+	for _, arg := range node.Args {
+		if arg == CallerName {
+			return params, nil
+		}
+	}
+	for _, pair := range node.Kwargs {
+		if key, ok := pair.Key.(*nodes.String); ok && key.Val == CallerName {
+			return params, nil
+		}
+	}
+	reduced := *params
+	reduced.KwArgs = map[string]*Value{}
+	for key, value := range params.KwArgs {
+		if key != CallerName {
+			reduced.KwArgs[key] = value
+		}
+	}
+	return &reduced, caller
+}
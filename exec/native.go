@@ -0,0 +1,144 @@
+package exec
+
+import (
+	"context"
+	"reflect"
+	"strings"
+
+	"github.com/paradime-io/gonja/config"
+	"github.com/paradime-io/gonja/nodes"
+	"github.com/paradime-io/gonja/parser"
+	"github.com/paradime-io/gonja/tokens"
+)
+
+// nativeOutput records the values rendered to the final output (see ExecuteNative)
+type nativeOutput struct {
+	count int
+	value *Value // first rendered value
+	text  string // its rendering
+}
+
+func (n *nativeOutput) record(value *Value, text string) {
+	if n.count == 0 {
+		n.value = value
+		n.text = text
+	}
+	n.count++
+}
+
+// ExecuteNative executes the template like Execute but returns a native Go value
+// instead of a string, like Jinja's NativeEnvironment:
+//   - the value of the output when it is the only thing rendered,
+//     ie. `{{ [1, 2] + extra }}` returns a []interface{}
+//   - the rendered string parsed as a literal otherwise,
+//     ie. `[{{ a }}, {{ b }}]` or `{{ a }}{{ b }}` rendering `12` returns an int
+//   - the rendered string itself if it isn't a literal.
+//
+// Lists are returned as []interface{} and dicts as map[string]interface{},
+// or map[interface{}]interface{} if one of their keys isn't a string,
+// the keys which can't be used as Go map keys, ie. lists, being rendered as strings.
+// Nil is returned if nothing is rendered.
+func (tpl *Template) ExecuteNative(ctx map[string]interface{}) (interface{}, error) {
+	return tpl.ExecuteNativeContext(context.Background(), ctx)
+}
+
+// ExecuteNativeContext executes the template like ExecuteNative but stops as soon as
+// ctx is cancelled or its deadline exceeded, returning a *CancelledError.
+func (tpl *Template) ExecuteNativeContext(ctx context.Context, data map[string]interface{}) (interface{}, error) {
+	var b strings.Builder
+	native := &nativeOutput{}
+	if err := tpl.execute(ctx, data, &b, native); err != nil {
+		return nil, err
+	}
+	out := b.String()
+	if native.count == 1 && out == native.text {
+		return nativeValue(native.value), nil
+	}
+	if out == "" {
+		return nil, nil
+	}
+	if value, ok := parseLiteral(out, tpl.Env.Config); ok {
+		return value, nil
+	}
+	return out, nil
+}
+
+// parseLiteral parses source as a literal expression, ie. `[1, 'a']`
+func parseLiteral(source string, cfg *config.Config) (interface{}, bool) {
+	wrapped := cfg.VariableStartString + " " + source + " " + cfg.VariableEndString
+	p := parser.NewParser("native", cfg, tokens.LexWithConfig(wrapped, cfg))
+	root, err := p.Parse()
+	if err != nil || len(root.Nodes) != 1 {
+		return nil, false
+	}
+	output, ok := root.Nodes[0].(*nodes.Output)
+	if !ok {
+		return nil, false
+	}
+	return nodes.Literal(output.Expression)
+}
+
+// nativeValue converts the values built while rendering, ie. lists and dicts
+// of *Value, to plain Go values
+func nativeValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case *Value:
+		if v == nil {
+			return nil
+		}
+		return nativeValue(v.Interface())
+	case ValuesList:
+		return nativeList(v)
+	case *ValuesList:
+		return nativeList(*v)
+	case []interface{}:
+		list := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			list = append(list, nativeValue(item))
+		}
+		return list
+	case *Dict:
+		return nativeDict(v)
+	case map[string]interface{}:
+		dict := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			dict[key] = nativeValue(item)
+		}
+		return dict
+	}
+	return value
+}
+
+func nativeList(values ValuesList) []interface{} {
+	list := make([]interface{}, 0, len(values))
+	for _, item := range values {
+		list = append(list, nativeValue(item))
+	}
+	return list
+}
+
+// nativeKey returns key as a map key, its string output if it isn't hashable, ie. a list
+func nativeKey(key *Value) interface{} {
+	k := key.Interface()
+	if k == nil || !reflect.TypeOf(k).Comparable() {
+		return key.String()
+	}
+	return k
+}
+
+func nativeDict(d *Dict) interface{} {
+	for _, pair := range d.Pairs {
+		if !pair.Key.IsString() {
+			dict := make(map[interface{}]interface{}, len(d.Pairs))
+			for _, pair := range d.Pairs {
+				dict[nativeKey(pair.Key)] = nativeValue(pair.Value)
+			}
+			return dict
+		}
+	}
+	dict := make(map[string]interface{}, len(d.Pairs))
+	for _, pair := range d.Pairs {
+		dict[pair.Key.String()] = nativeValue(pair.Value)
+	}
+	return dict
+}
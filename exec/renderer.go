@@ -1,11 +1,16 @@
 package exec
 
 import (
+	"context"
+	"fmt"
+	"io"
 	"strings"
 
 	"github.com/pkg/errors"
 
 	"github.com/paradime-io/gonja/nodes"
+	"github.com/paradime-io/gonja/parser"
+	"github.com/paradime-io/gonja/tokens"
 )
 
 // TrimState stores and apply trim policy
@@ -36,20 +41,28 @@ type Renderer struct {
 	Ctx      *Context
 	Template *Template
 	Root     *nodes.Template
-	Out      *strings.Builder
+	Current  *nodes.Template // template the rendered nodes belong to
+	Out      io.StringWriter
 	Trim     *TrimState
+	Context  context.Context
+	usage    *usage
+	native   *nativeOutput
+	scope    *Context // the template level context, seen by non scoped blocks
 }
 
 // NewRenderer initialize a new renderer
-func NewRenderer(ctx *Context, out *strings.Builder, cfg *EvalConfig, tpl *Template) *Renderer {
+func NewRenderer(ctx *Context, out io.StringWriter, cfg *EvalConfig, tpl *Template) *Renderer {
 	var buffer strings.Builder
 	r := &Renderer{
 		EvalConfig: cfg,
 		Ctx:        ctx,
 		Template:   tpl,
 		Root:       tpl.Root,
+		Current:    tpl.Root,
 		Out:        out,
 		Trim:       &TrimState{Buffer: &buffer},
+		Context:    context.Background(),
+		usage:      newUsage(cfg.Limits, out),
 	}
 	r.Ctx.Set("self", Self(r))
 	return r
@@ -62,8 +75,23 @@ func (r *Renderer) Inherit() *Renderer {
 		Ctx:        r.Ctx.Inherit(),
 		Template:   r.Template,
 		Root:       r.Root,
+		Current:    r.Current,
 		Out:        r.Out,
 		Trim:       r.Trim,
+		Context:    r.Context,
+		usage:      r.usage,
+		native:     r.native,
+		scope:      r.scope,
+	}
+	return sub
+}
+
+// InheritTemplateScope creates a new sub renderer only seeing the template level
+// variables, ie. neither the loop variables nor the ones set within a loop or a macro
+func (r *Renderer) InheritTemplateScope() *Renderer {
+	sub := r.Inherit()
+	if r.scope != nil {
+		sub.Ctx = r.scope.Inherit()
 	}
 	return sub
 }
@@ -74,8 +102,13 @@ func (r *Renderer) InheritWithoutNewScope() *Renderer {
 		Ctx:        r.Ctx,
 		Template:   r.Template,
 		Root:       r.Root,
+		Current:    r.Current,
 		Out:        r.Out,
 		Trim:       r.Trim,
+		Context:    r.Context,
+		usage:      r.usage,
+		native:     r.native,
+		scope:      r.scope,
 	}
 	return sub
 }
@@ -110,16 +143,27 @@ func (r *Renderer) WriteString(txt string) (int, error) {
 			r.Trim.Should = false
 		}
 	}
+	// Captured output (ie. macro calls) is accounted for once rendered in the final output
+	if r.Out == r.usage.out {
+		if err := r.usage.write(len(txt)); err != nil {
+			return 0, err
+		}
+	}
 	return r.Trim.Buffer.WriteString(txt)
 }
 
 // RenderValue properly render a value
 func (r *Renderer) RenderValue(value *Value) {
+	var txt string
 	if r.Autoescape && value.IsString() && !value.Safe {
-		r.WriteString(value.Escaped())
+		txt = value.Escaped()
 	} else {
-		r.WriteString(value.String())
+		txt = value.String()
+	}
+	if r.native != nil && r.Out == r.usage.out {
+		r.native.record(value, txt)
 	}
+	r.WriteString(txt)
 }
 
 func (r *Renderer) StartTag(trim *nodes.Trim, lstrip bool) {
@@ -145,32 +189,49 @@ func (r *Renderer) Tag(trim *nodes.Trim, lstrip bool) {
 
 // Visit implements the nodes.Visitor interface
 func (r *Renderer) Visit(node nodes.Node) (nodes.Visitor, error) {
+	if err := r.Cancelled(); err != nil {
+		return nil, err
+	}
+	if err := r.Exceeded(); err != nil {
+		return nil, err
+	}
 	switch n := node.(type) {
 	case *nodes.Comment:
-		r.Tag(n.Trim, false)
+		// Line comments are dropped without affecting the surrounding whitespace
+		if n.Start.Type != tokens.LinecommentBegin {
+			r.Tag(n.Trim, false)
+		}
 		return nil, nil
 	case *nodes.Data:
-		r.WriteString(n.Data.Val)
+		r.usage.at(r.Template, n.Position())
+		if _, err := r.WriteString(n.Data.Val); err != nil {
+			return nil, err
+		}
 		return nil, nil
 	case *nodes.Output:
+		r.usage.at(r.Template, n.Position())
 		r.StartTag(n.Trim, false)
 		value := r.Eval(n.Expression)
 		if value.IsError() {
-			return nil, errors.Wrapf(value, `Unable to render expression '%s'`, n.Expression)
+			return nil, r.locate(errors.Wrapf(value, `Unable to render expression '%s'`, n.Expression), n.Position())
 		}
 		r.RenderValue(value)
+		if err := r.Exceeded(); err != nil {
+			return nil, err
+		}
 		r.EndTag(n.Trim)
 		return nil, nil
 	case *nodes.StatementBlock:
+		r.usage.at(r.Template, n.Position())
 		r.Tag(n.Trim, n.LStrip)
-		r.Trim.ShouldBlock = r.Config.TrimBlocks
+		r.Trim.ShouldBlock = r.Config.TrimBlocks && !n.LineStatement
 		stmt, ok := n.Stmt.(Statement)
 		if ok {
 			// Silently ignore non executable statements
 			// return nil, nil
 			// return nil, errors.Errorf(`Unable to execute statement '%s'`, n.Stmt)
 			if err := stmt.Execute(r, n); err != nil {
-				return nil, errors.Wrapf(err, `Unable to execute statement '%s'`, n.Stmt)
+				return nil, r.locate(errors.Wrapf(err, `Unable to execute statement '%s'`, n.Stmt), n.Position())
 			}
 		}
 		return nil, nil
@@ -184,7 +245,7 @@ func (r *Renderer) ExecuteWrapper(wrapper *nodes.Wrapper) error {
 	sub := r.Inherit()
 	err := nodes.Walk(sub, wrapper)
 	sub.Tag(wrapper.Trim, wrapper.LStrip)
-	r.Trim.ShouldBlock = r.Config.TrimBlocks
+	r.Trim.ShouldBlock = r.Config.TrimBlocks && !wrapper.LineStatement
 	return err
 }
 
@@ -192,30 +253,136 @@ func (r *Renderer) ExecuteWrapperWithoutNewScope(wrapper *nodes.Wrapper) error {
 	sub := r.InheritWithoutNewScope()
 	err := nodes.Walk(sub, wrapper)
 	sub.Tag(wrapper.Trim, wrapper.LStrip)
-	r.Trim.ShouldBlock = r.Config.TrimBlocks
+	r.Trim.ShouldBlock = r.Config.TrimBlocks && !wrapper.LineStatement
 	return err
 }
 
 func (r *Renderer) LStrip() {
 }
 
+// ParentResolver is implemented by statements resolving
+// the parent template at rendering time (ie. dynamic extends)
+type ParentResolver interface {
+	ResolveParent(r *Renderer) (*nodes.Template, error)
+}
+
+// resolveParents returns tpl with its dynamic parents resolved.
+// Parsed templates are shared so the ones concerned are copied, never updated.
+func (r *Renderer) resolveParents(tpl *nodes.Template, children []string) (*nodes.Template, error) {
+	for _, child := range children {
+		if child == tpl.Name {
+			return nil, errors.Errorf(`Template '%s' extends itself`, tpl.Name)
+		}
+	}
+	children = append(children, tpl.Name)
+
+	if tpl.Parent != nil {
+		parent, err := r.resolveParents(tpl.Parent, children)
+		if err != nil {
+			return nil, err
+		}
+		if parent == tpl.Parent {
+			return tpl, nil
+		}
+		derived := *tpl
+		derived.Parent = parent
+		return &derived, nil
+	}
+
+	for _, node := range tpl.Nodes {
+		block, ok := node.(*nodes.StatementBlock)
+		if !ok {
+			continue
+		}
+		resolver, ok := block.Stmt.(ParentResolver)
+		if !ok {
+			continue
+		}
+		sub := r.Inherit()
+		sub.Current = tpl
+		parent, err := resolver.ResolveParent(sub)
+		if err != nil {
+			return nil, sub.locate(err, block.Position())
+		}
+		if parent == nil {
+			continue
+		}
+		parent, err = r.resolveParents(parent, children)
+		if err != nil {
+			return nil, err
+		}
+		derived := *tpl
+		derived.Parent = parent
+		return &derived, nil
+	}
+	return tpl, nil
+}
+
 func (r *Renderer) Execute() error {
+	r.scope = r.Ctx
+	resolved, err := r.resolveParents(r.Root, nil)
+	if err != nil {
+		return err
+	}
+	if resolved != r.Root {
+		r.Root = resolved
+		r.Ctx.Set("self", Self(r))
+	}
+
 	// Determine the parent to be executed (for template inheritance)
 	root := r.Root
 	for root.Parent != nil {
 		root = root.Parent
 	}
+	r.Current = root
 
-	err := nodes.Walk(r, root)
+	err = nodes.Walk(r, root)
 	if err == nil {
 		r.Flush(false)
 	}
 	return err
 }
 
+// locate returns err located at tok in the current template.
+// Errors already located in the current template are returned as is,
+// errors located in another one (ie. included) are wrapped, forming a template stack trace.
+func (r *Renderer) locate(err error, tok *tokens.Token) error {
+	name := r.Template.Name
+	if r.Current != nil {
+		name = r.Current.Name
+	}
+	if located := parser.AsError(err); located != nil && located.Template == name {
+		return err
+	}
+	if undefined, ok := errors.Cause(err).(*UndefinedError); ok && undefined.Token != nil {
+		// Points at the undefined name rather than at the enclosing node
+		tok = undefined.Token
+	}
+	e := &parser.Error{
+		Template: name,
+		Phase:    parser.RenderPhase,
+		Token:    tok,
+		Err:      err,
+	}
+	if tok != nil {
+		e.Line = tok.Line
+		e.Col = tok.Col
+	}
+	if r.Template.Name == name {
+		e.Source = r.Template.Source
+	}
+	return e
+}
+
+// String flushes the pending output and returns the rendered content
+// when the renderer writes to a fmt.Stringer such as a strings.Builder.
 func (r *Renderer) String() string {
 	r.Flush(false)
-	out := r.Out.String()
+	stringer, ok := r.Out.(fmt.Stringer)
+	if !ok {
+		return ""
+	}
+	out := stringer.String()
 	if !r.Config.KeepTrailingNewline {
 		out = strings.TrimSuffix(out, "\n")
 	}
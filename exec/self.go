@@ -20,8 +20,12 @@ func getBlocks(tpl *nodes.Template) map[string]*nodes.Wrapper {
 func Self(r *Renderer) map[string]func() string {
 	blocks := map[string]func() string{}
 	for name, block := range getBlocks(r.Root) {
+		block := block
 		blocks[name] = func() string {
 			sub := r.Inherit()
+			if owner := r.Root.BlockOwner(block); owner != nil {
+				sub.Current = owner
+			}
 			var out strings.Builder
 			sub.Out = &out
 			sub.ExecuteWrapper(block)
@@ -0,0 +1,121 @@
+package exec
+
+import (
+	"encoding/gob"
+	"io"
+
+	"github.com/pkg/errors"
+
+	"github.com/paradime-io/gonja/nodes"
+)
+
+// SerializationVersion is the version of the format written by Serialize.
+// Templates serialized with another version are rejected by Deserialize
+// and have to be parsed again.
+const SerializationVersion = 1
+
+const serializationFormat = "gonja-template"
+
+type serializationHeader struct {
+	Format  string
+	Version int
+	Name    string
+	Source  string
+}
+
+// blockDefinition is implemented by the statements defining a block, ie. BlockStmt.
+// The blocks of a template share their body with these statements, which gob
+// doesn't preserve, so they are linked back once deserialized.
+type blockDefinition interface {
+	Block() (string, *nodes.Wrapper)
+}
+
+// RegisterNodes makes node types serializable. The statements, and custom
+// nodes in general, must be registered from an init function of their
+// package; serializing a template holding an unregistered node fails.
+func RegisterNodes(nodes ...nodes.Node) {
+	for _, node := range nodes {
+		gob.Register(node)
+	}
+}
+
+func init() {
+	RegisterNodes(
+		&nodes.Template{}, &nodes.Data{}, &nodes.Comment{}, &nodes.Output{},
+		&nodes.StatementBlock{}, &nodes.Wrapper{}, &nodes.Macro{},
+		&nodes.FilteredExpression{}, &nodes.FilterCall{}, &nodes.TestExpression{}, &nodes.TestCall{},
+		&nodes.String{}, &nodes.Integer{}, &nodes.Float{}, &nodes.Bool{},
+		&nodes.Name{}, &nodes.Varargs{}, &nodes.Kwargs{},
+		&nodes.List{}, &nodes.Tuple{}, &nodes.Dict{}, &nodes.Pair{},
+		&nodes.Variable{}, &nodes.Call{}, &nodes.Getitem{}, &nodes.Getitemrange{}, &nodes.Getattr{},
+		&nodes.Negation{}, &nodes.UnaryExpression{}, &nodes.BinaryExpression{}, &nodes.InlineIfExpression{},
+	)
+}
+
+// Serialize writes the parsed template, along with the templates it
+// statically extends, includes or imports, so it can be loaded back with
+// Deserialize without being lexed and parsed again. Only the source of the
+// template itself is written, to locate its rendering errors.
+func (tpl *Template) Serialize(w io.Writer) error {
+	enc := gob.NewEncoder(w)
+	header := serializationHeader{
+		Format:  serializationFormat,
+		Version: SerializationVersion,
+		Name:    tpl.Name,
+		Source:  tpl.Source,
+	}
+	if err := enc.Encode(header); err != nil {
+		return errors.Wrapf(err, `Unable to serialize template '%s'`, tpl.Name)
+	}
+	if err := enc.Encode(tpl.Root); err != nil {
+		return errors.Wrapf(err, `Unable to serialize template '%s'`, tpl.Name)
+	}
+	return nil
+}
+
+// Deserialize loads a template written by Serialize, to be rendered with cfg
+func Deserialize(r io.Reader, cfg *EvalConfig) (*Template, error) {
+	dec := gob.NewDecoder(r)
+	var header serializationHeader
+	if err := dec.Decode(&header); err != nil {
+		return nil, errors.Wrap(err, `Unable to deserialize template`)
+	}
+	if header.Format != serializationFormat {
+		return nil, errors.New(`Unable to deserialize template: not a serialized template`)
+	}
+	if header.Version != SerializationVersion {
+		return nil, errors.Errorf(`Unable to deserialize template '%s': unsupported version %d (expected %d)`,
+			header.Name, header.Version, SerializationVersion)
+	}
+	root := &nodes.Template{}
+	if err := dec.Decode(root); err != nil {
+		return nil, errors.Wrapf(err, `Unable to deserialize template '%s'`, header.Name)
+	}
+	for tpl := root; tpl != nil; tpl = tpl.Parent {
+		linkBlocks(tpl)
+	}
+	return &Template{
+		Name:    header.Name,
+		Source:  header.Source,
+		Env:     cfg,
+		Root:    root,
+		Program: Compile(root, cfg),
+	}, nil
+}
+
+// linkBlocks makes the blocks of tpl the bodies of its block statements again
+func linkBlocks(tpl *nodes.Template) {
+	nodes.Inspect(tpl, func(node nodes.Node) bool {
+		block, ok := node.(*nodes.StatementBlock)
+		if !ok {
+			return true
+		}
+		if definition, ok := block.Stmt.(blockDefinition); ok {
+			name, wrapper := definition.Block()
+			if _, exists := tpl.Blocks[name]; exists {
+				tpl.Blocks[name] = wrapper
+			}
+		}
+		return true
+	})
+}
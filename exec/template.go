@@ -2,6 +2,7 @@ package exec
 
 import (
 	"bytes"
+	"context"
 	"io"
 	"strings"
 
@@ -16,6 +17,12 @@ type TemplateLoader interface {
 	GetTemplate(string) (*Template, error)
 }
 
+// TemplateResolver is implemented by the template loaders resolving the names
+// of the templates referenced by another one, ie. './partial.html'
+type TemplateResolver interface {
+	ResolveTemplate(base, name string) string
+}
+
 type Template struct {
 	Name   string
 	Reader io.Reader
@@ -29,6 +36,9 @@ type Template struct {
 
 	Root   *nodes.Template
 	Macros MacroSet
+	// Program holds the compiled expressions of the template,
+	// they are interpreted if nil (see Compile)
+	Program *Program
 }
 
 func NewTemplate(name string, source string, cfg *EvalConfig) (*Template, error) {
@@ -37,34 +47,113 @@ func NewTemplate(name string, source string, cfg *EvalConfig) (*Template, error)
 		Env:    cfg,
 		Name:   name,
 		Source: source,
-		Tokens: tokens.Lex(source),
+		Tokens: tokens.LexWithConfig(source, cfg.Config),
 	}
 
 	// Parse it
 	t.Parser = parser.NewParser(name, cfg.Config, t.Tokens)
 	t.Parser.Statements = *t.Env.Statements
-	t.Parser.TemplateParser = t.Env.GetTemplate
+	t.Parser.TemplateParser = func(filename string) (*nodes.Template, error) {
+		return t.Env.GetTemplate(t.Env.ResolveTemplate(name, filename))
+	}
 	root, err := t.Parser.Parse()
 	if err != nil {
+		if located := parser.AsError(err); located != nil && located.Template == name && located.Source == "" {
+			located.Source = source
+		}
 		return nil, err
 	}
 	t.Root = root
+	t.Program = Compile(root, cfg)
 
 	return t, nil
 }
 
-func (tpl *Template) execute(ctx map[string]interface{}, out io.StringWriter) error {
+// Output is the destination of a template rendering.
+// It holds back a trailing newline until more content is written
+// so it can be dropped at the end of the rendering (see KeepTrailingNewline),
+// and remembers the first write error.
+type Output struct {
+	w           io.Writer
+	keepNewline bool
+	pending     bool
+	err         error
+}
+
+// NewOutput wraps a writer as a rendering destination.
+func NewOutput(w io.Writer, keepTrailingNewline bool) *Output {
+	return &Output{w: w, keepNewline: keepTrailingNewline}
+}
+
+// WriteString implements io.StringWriter
+func (o *Output) WriteString(s string) (int, error) {
+	if o.err != nil {
+		return 0, o.err
+	}
+	if len(s) == 0 {
+		return 0, nil
+	}
+	n := len(s)
+	if o.pending {
+		s = "\n" + s
+		o.pending = false
+	}
+	if strings.HasSuffix(s, "\n") {
+		s = s[:len(s)-1]
+		o.pending = true
+	}
+	if _, err := io.WriteString(o.w, s); err != nil {
+		o.err = err
+		return 0, err
+	}
+	return n, nil
+}
+
+// Close writes the trailing newline if it must be kept
+// and returns the first error encountered while writing.
+func (o *Output) Close() error {
+	if o.pending && o.keepNewline && o.err == nil {
+		o.pending = false
+		if _, err := io.WriteString(o.w, "\n"); err != nil {
+			o.err = err
+		}
+	}
+	return o.err
+}
+
+// execute renders the template directly into out,
+// recording the rendered values into native if not nil
+func (tpl *Template) execute(goCtx context.Context, ctx map[string]interface{}, out io.Writer, native *nativeOutput) error {
+	// Background producers (ie. range) are stopped as soon as the rendering ends
+	goCtx, cancel := context.WithCancel(goCtx)
+	defer cancel()
+
 	exCtx := tpl.Env.Globals.Inherit()
 	exCtx.Update(ctx)
 
-	var builder strings.Builder
-	renderer := NewRenderer(exCtx, &builder, tpl.Env, tpl)
+	output := NewOutput(out, tpl.Env.KeepTrailingNewline)
+	renderer := NewRenderer(exCtx, output, tpl.Env, tpl)
+	renderer.Context = goCtx
+	renderer.native = native
 
 	err := renderer.Execute()
+	// Errors raised by a cancellation or an exceeded limit are reported as such,
+	// whatever the node or value they went through
+	if limitErr := renderer.Exceeded(); limitErr != nil {
+		return limitErr
+	}
 	if err != nil {
+		if cancelErr := cancelled(goCtx); cancelErr != nil {
+			return cancelErr
+		}
+		if _, located := err.(*parser.Error); located {
+			return err
+		}
 		return errors.Wrap(err, `Unable to Execute template`)
 	}
-	out.WriteString(renderer.String())
+	if err := output.Close(); err != nil {
+		return errors.Wrap(err, `Unable to write template output`)
+	}
 
 	return nil
 }
@@ -74,35 +163,40 @@ func (tpl *Template) newBufferAndExecute(ctx map[string]interface{}) (*bytes.Buf
 	// Create output buffer
 	// We assume that the rendered template will be 30% larger
 	// buffer := bytes.NewBuffer(make([]byte, 0, int(float64(tpl.size)*1.3)))
-	if err := tpl.execute(ctx, &buffer); err != nil {
+	if err := tpl.execute(context.Background(), ctx, &buffer, nil); err != nil {
 		return nil, err
 	}
 	return &buffer, nil
 }
 
-// // Executes the template with the given context and writes to writer (io.Writer)
-// // on success. Context can be nil. Nothing is written on error; instead the error
-// // is being returned.
-// func (tpl *Template) ExecuteWriter(ctx *Context, writer io.Writer) error {
-// 	buf, err := tpl.newBufferAndExecute(ctx)
-// 	if err != nil {
-// 		return err
-// 	}
-// 	_, err = buf.WriteTo(writer)
-// 	if err != nil {
-// 		return err
-// 	}
-// 	return nil
-// }
-
-// // // Same as ExecuteWriter. The only difference between both functions is that
-// // // this function might already have written parts of the generated template in the
-// // // case of an execution error because there's no intermediate buffer involved for
-// // // performance reasons. This is handy if you need high performance template
-// // // generation or if you want to manage your own pool of buffers.
-// // func (tpl *Template) ExecuteWriterUnbuffered(ctx *Context, writer io.Writer) error {
-// // 	return tpl.newTemplateWriterAndExecute(ctx, writer)
-// // }
+// ExecuteWriter executes the template with the given context and streams
+// the rendered content to writer as it is produced, keeping memory usage bounded.
+// Context can be nil. Parts of the output might already have been written
+// in case of an execution error; use ExecuteWriterBuffered to avoid this.
+func (tpl *Template) ExecuteWriter(ctx map[string]interface{}, writer io.Writer) error {
+	return tpl.ExecuteWriterContext(context.Background(), ctx, writer)
+}
+
+// ExecuteWriterContext streams the template like ExecuteWriter but stops as soon
+// as ctx is cancelled or its deadline exceeded, returning a *CancelledError.
+func (tpl *Template) ExecuteWriterContext(ctx context.Context, data map[string]interface{}, writer io.Writer) error {
+	return tpl.execute(ctx, data, writer, nil)
+}
+
+// ExecuteWriterBuffered executes the template with the given context and writes
+// to writer (io.Writer) on success. Context can be nil. Nothing is written
+// on error; instead the error is being returned.
+func (tpl *Template) ExecuteWriterBuffered(ctx map[string]interface{}, writer io.Writer) error {
+	var buf bytes.Buffer
+	if err := tpl.ExecuteWriterContext(context.Background(), ctx, &buf); err != nil {
+		return err
+	}
+	_, err := buf.WriteTo(writer)
+	if err != nil {
+		return err
+	}
+	return nil
+}
 
 // Executes the template and returns the rendered template as a []byte
 func (tpl *Template) ExecuteBytes(ctx map[string]interface{}) ([]byte, error) {
@@ -115,8 +209,14 @@ func (tpl *Template) ExecuteBytes(ctx map[string]interface{}) ([]byte, error) {
 
 // Executes the template and returns the rendered template as a string
 func (tpl *Template) Execute(ctx map[string]interface{}) (string, error) {
+	return tpl.ExecuteContext(context.Background(), ctx)
+}
+
+// ExecuteContext executes the template like Execute but stops as soon as ctx
+// is cancelled or its deadline exceeded, returning a *CancelledError.
+func (tpl *Template) ExecuteContext(ctx context.Context, data map[string]interface{}) (string, error) {
 	var b strings.Builder
-	err := tpl.execute(ctx, &b)
+	err := tpl.execute(ctx, data, &b, nil)
 	if err != nil {
 		return "", err
 	}
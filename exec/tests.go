@@ -55,11 +55,23 @@ func (e *Evaluator) EvalTest(expr *nodes.TestExpression) *Value {
 	// if value.IsError() {
 	// 	return AsValue(errors.Wrapf(value, `Unable to evaluate expresion %s`, expr.Expression))
 	// }
+	if err := rejectUndefined(value, undefinedTests[expr.Test.Name]); err != nil {
+		return AsValue(errors.Wrapf(err, `Unable to evaluate expresion %s`, expr.Expression))
+	}
 
 	return e.ExecuteTest(expr.Test, value)
 }
 
 func (e *Evaluator) ExecuteTest(tc *nodes.TestCall, v *Value) *Value {
+	params, err := e.testParams(tc)
+	if err != nil {
+		return err
+	}
+	return e.ExecuteTestByName(tc.Name, v, params)
+}
+
+// testParams evaluates the arguments of a test call
+func (e *Evaluator) testParams(tc *nodes.TestCall) (*VarArgs, *Value) {
 	params := &VarArgs{
 		Args:   []*Value{},
 		KwArgs: map[string]*Value{},
@@ -68,7 +80,7 @@ func (e *Evaluator) ExecuteTest(tc *nodes.TestCall, v *Value) *Value {
 	for _, param := range tc.Args {
 		value := e.Eval(param)
 		if value.IsError() {
-			return AsValue(errors.Wrapf(value, `Unable to evaluate parameter %s`, param))
+			return nil, AsValue(errors.Wrapf(value, `Unable to evaluate parameter %s`, param))
 		}
 		params.Args = append(params.Args, value)
 	}
@@ -76,12 +88,11 @@ func (e *Evaluator) ExecuteTest(tc *nodes.TestCall, v *Value) *Value {
 	for key, param := range tc.Kwargs {
 		value := e.Eval(param)
 		if value.IsError() {
-			return AsValue(errors.Wrapf(value, `Unable to evaluate parameter %s`, param))
+			return nil, AsValue(errors.Wrapf(value, `Unable to evaluate parameter %s`, param))
 		}
 		params.KwArgs[key] = value
 	}
-
-	return e.ExecuteTestByName(tc.Name, v, params)
+	return params, nil
 }
 
 func (e *Evaluator) ExecuteTestByName(name string, in *Value, params *VarArgs) *Value {
@@ -89,7 +100,11 @@ func (e *Evaluator) ExecuteTestByName(name string, in *Value, params *VarArgs) *
 		return AsValue(errors.Errorf(`Test "%s" not found`, name))
 	}
 	test, _ := (*e.Tests)[name]
+	return runTest(e, name, test, in, params)
+}
 
+// runTest runs a resolved test
+func runTest(e *Evaluator, name string, test TestFunction, in *Value, params *VarArgs) *Value {
 	result, err := test(e.Ctx, in, params)
 	if err != nil {
 		return AsValue(errors.Wrapf(err, `Unable to execute test %s`, name))
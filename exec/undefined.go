@@ -0,0 +1,126 @@
+package exec
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/pkg/errors"
+
+	"github.com/paradime-io/gonja/nodes"
+	"github.com/paradime-io/gonja/tokens"
+)
+
+// UndefinedPolicy defines how names and attributes missing from the context behave.
+// Policies follow Jinja's Undefined classes.
+type UndefinedPolicy int
+
+const (
+	// Undefined renders as an empty string but fails on attribute access.
+	// This is the default policy.
+	Undefined UndefinedPolicy = iota
+	// ChainableUndefined renders as an empty string and allows attribute access
+	// on missing values (ie. a.b.c with a missing).
+	ChainableUndefined
+	// StrictUndefined fails on any use but the defined/undefined tests and the default filter.
+	StrictUndefined
+	// DebugUndefined renders missing variables back verbatim ({{ name }})
+	// and fails on attribute access.
+	DebugUndefined
+)
+
+// Filters and tests allowed to receive an undefined value under StrictUndefined
+var (
+	undefinedFilters = map[string]bool{"default": true, "d": true}
+	undefinedTests   = map[string]bool{"defined": true, "undefined": true}
+)
+
+// UndefinedError is returned when an undefined name or attribute is used
+// in a way its UndefinedPolicy does not allow.
+// Its location is reported by the *parser.Error wrapping it once rendered.
+type UndefinedError struct {
+	Name     string
+	Template string
+	Token    *tokens.Token
+}
+
+func (e *UndefinedError) Error() string {
+	return fmt.Sprintf("'%s' is undefined", e.Name)
+}
+
+// IsUndefinedError returns true if err has been caused by an UndefinedError
+func IsUndefinedError(err error) bool {
+	_, ok := errors.Cause(err).(*UndefinedError)
+	return ok
+}
+
+// undefined marks a value resulting from a missing name or attribute
+type undefined struct {
+	name   string
+	policy UndefinedPolicy
+}
+
+// IsUndefined returns true if the value results from a missing name or attribute
+func (v *Value) IsUndefined() bool {
+	return v.undefined != nil
+}
+
+// undefinedName returns a readable name for an expression
+func undefinedName(node nodes.Expression) string {
+	switch n := node.(type) {
+	case *nodes.Name:
+		return n.Name.Val
+	case *nodes.Getattr:
+		if n.Attr != "" {
+			return fmt.Sprintf("%s.%s", undefinedName(n.Node), n.Attr)
+		}
+		return fmt.Sprintf("%s.%d", undefinedName(n.Node), n.Index)
+	case *nodes.Getitem:
+		if n.Arg != nil {
+			return fmt.Sprintf("%s[%s]", undefinedName(n.Node), undefinedName(*n.Arg))
+		}
+	case *nodes.String:
+		return fmt.Sprintf("'%s'", n.Val)
+	case *nodes.Integer:
+		return fmt.Sprintf("%d", n.Val)
+	}
+	return node.String()
+}
+
+func (e *Evaluator) undefinedError(name string, tok *tokens.Token) *Value {
+	err := &UndefinedError{Name: name, Token: tok}
+	if e.usage != nil {
+		err.Template = e.usage.template
+	}
+	return AsValue(err)
+}
+
+// undefinedValue returns the value of a missing name or attribute according to the policy
+func (e *Evaluator) undefinedValue(node nodes.Expression) *Value {
+	name := undefinedName(node)
+	if e.Undefined == StrictUndefined {
+		return e.undefinedError(name, node.Position())
+	}
+	return &Value{
+		Val:       reflect.ValueOf(nil),
+		undefined: &undefined{name: name, policy: e.Undefined},
+	}
+}
+
+// checkUndefined fails when accessing an attribute of an undefined value isn't allowed
+func (e *Evaluator) checkUndefined(value *Value, node nodes.Expression) *Value {
+	if value.IsUndefined() && value.undefined.policy != ChainableUndefined {
+		return e.undefinedError(value.undefined.name, node.Position())
+	}
+	return nil
+}
+
+// rejectUndefined fails when an undefined value can't be used as input of a filter or test
+func rejectUndefined(value *Value, allowed bool) *Value {
+	if !value.IsError() || !IsUndefinedError(value) {
+		return nil
+	}
+	if _, direct := value.Interface().(*UndefinedError); direct && allowed {
+		return nil
+	}
+	return value
+}
@@ -15,8 +15,9 @@ import (
 )
 
 type Value struct {
-	Val  reflect.Value
-	Safe bool // used to indicate whether a Value needs explicit escaping in the template
+	Val       reflect.Value
+	Safe      bool       // used to indicate whether a Value needs explicit escaping in the template
+	undefined *undefined // set when resulting from a missing name or attribute
 }
 
 // AsValue converts any given Value to a gonja.Value
@@ -149,6 +150,14 @@ func (v *Value) Error() string {
 	return ""
 }
 
+// Cause returns the error held by the value, nil if it isn't an error
+func (v *Value) Cause() error {
+	if v.IsError() {
+		return v.Interface().(error)
+	}
+	return nil
+}
+
 // String returns a string for the underlying value. If this value is not
 // of type string, gonja tries to convert it. Currently the following
 // types for underlying values are supported:
@@ -164,6 +173,9 @@ func (v *Value) Error() string {
 // to their respective type name.
 func (v *Value) String() string {
 	if v.IsNil() {
+		if v.IsUndefined() && v.undefined.policy == DebugUndefined {
+			return fmt.Sprintf("{{ %s }}", v.undefined.name)
+		}
 		return ""
 	}
 	resolved := v.getResolvedValue()
@@ -521,7 +533,7 @@ func (v *Value) CanSlice() bool {
 // function's first argument for every value with the following arguments:
 //
 //	idx      current 0-index
-//	count    total amount of items
+//	count    total amount of items, -1 for channels which are received lazily
 //	key      *Value for the key or item
 //	value    *Value (only for maps, the respective value for a specific key)
 //
@@ -646,20 +658,20 @@ func (v *Value) IterateOrder(fn func(idx, count int, key, value *Value) bool, em
 		}
 		return // done
 	case reflect.Chan:
-		items := []reflect.Value{}
+		// Items are received one at a time so that iterating over a
+		// channel, ie. a huge range, can stop early
+		idx := 0
 		for {
 			value, ok := resolved.Recv()
 			if !ok {
 				break
 			}
-			items = append(items, value)
-		}
-		count := len(items)
-		if count > 0 {
-			for idx, value := range items {
-				fn(idx, count, &Value{Val: value}, nil)
+			if !fn(idx, -1, &Value{Val: value}, nil) {
+				return
 			}
-		} else {
+			idx++
+		}
+		if idx == 0 {
 			empty()
 		}
 		return
@@ -831,11 +843,23 @@ func ToValue(data interface{}) *Value {
 	return &Value{Val: val, Safe: isSafe}
 }
 
+// AttrGetter is implemented by values exposing their own attributes
+// to templates instead of their fields and methods.
+type AttrGetter interface {
+	Getattr(name string) (*Value, bool)
+}
+
 func (v *Value) Getattr(name string) (*Value, bool) {
 	if v.IsNil() {
 		return AsValue(errors.New(`Can't use getattr on None`)), false
 	}
 
+	if v.Val.CanInterface() && v.Val.Type() != typeOfValuePtr {
+		if getter, ok := v.Val.Interface().(AttrGetter); ok {
+			return getter.Getattr(name)
+		}
+	}
+
 	var resolvedVal reflect.Value
 	if v.Val.Kind() == reflect.Ptr {
 		resolvedVal = v.Val.Elem()
@@ -872,12 +896,15 @@ func (v *Value) Getattr(name string) (*Value, bool) {
 			}), true
 		case "update":
 			return AsValue(func(d *Value) error {
-				for _, key := range d.Val.MapKeys() {
-					if err := d.Set(key.String(), AsValue(v.Val.MapIndex(key))); err != nil {
-						return err
-					}
+				if !d.IsDict() {
+					return errors.Errorf(`Can't update a dict from "%s"`, d.String())
 				}
-				return nil
+				var err error
+				d.Iterate(func(idx, count int, key, value *Value) bool {
+					err = v.Set(key.String(), value.Interface())
+					return err == nil
+				}, func() {})
+				return err
 			}), true
 		case "items":
 			return AsValue(func() (*Value, error) {
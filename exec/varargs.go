@@ -1,6 +1,7 @@
 package exec
 
 import (
+	"context"
 	"sort"
 	"strings"
 
@@ -9,8 +10,9 @@ import (
 
 // VarArgs represents pythonic variadic args/kwargs
 type VarArgs struct {
-	Args   []*Value
-	KwArgs map[string]*Value
+	Args    []*Value
+	KwArgs  map[string]*Value
+	Context context.Context
 }
 
 func NewVarArgs() *VarArgs {
@@ -20,6 +22,28 @@ func NewVarArgs() *VarArgs {
 	}
 }
 
+// Done returns a channel closed when the calling rendering ends.
+// Functions producing values in background (ie. channels) must stop when it is closed.
+// It returns nil (blocks forever) when called outside of a rendering.
+func (va *VarArgs) Done() <-chan struct{} {
+	if va.Context == nil {
+		return nil
+	}
+	return va.Context.Done()
+}
+
+type evalConfigKey struct{}
+
+// Config returns the configuration of the calling template, ie. whether it
+// autoescapes its output. It returns nil when called outside of a rendering.
+func (va *VarArgs) Config() *EvalConfig {
+	if va.Context == nil {
+		return nil
+	}
+	cfg, _ := va.Context.Value(evalConfigKey{}).(*EvalConfig)
+	return cfg
+}
+
 // First returns the first argument or nil AsValue
 func (va *VarArgs) First() *Value {
 	if len(va.Args) > 0 {
@@ -46,8 +70,9 @@ type KwArg struct {
 func (va *VarArgs) Expect(args int, kwargs []*KwArg) *ReducedVarArgs {
 	rva := &ReducedVarArgs{VarArgs: va}
 	reduced := &VarArgs{
-		Args:   va.Args,
-		KwArgs: map[string]*Value{},
+		Args:    va.Args,
+		KwArgs:  map[string]*Value{},
+		Context: va.Context,
 	}
 	reduceIdx := -1
 	unexpectedArgs := []string{}
@@ -0,0 +1,77 @@
+package gonja_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/paradime-io/gonja"
+	"github.com/paradime-io/gonja/config"
+)
+
+// chunkWriter records every write it receives
+type chunkWriter struct {
+	chunks []string
+}
+
+func (w *chunkWriter) Write(p []byte) (int, error) {
+	w.chunks = append(w.chunks, string(p))
+	return len(p), nil
+}
+
+func (w *chunkWriter) String() string {
+	return strings.Join(w.chunks, "")
+}
+
+const streamSource = `{% for row in rows %}{{ row }},   
+  {%- if loop.last %}end{% endif %}
+{% endfor %}`
+
+func TestExecuteWriterStreams(t *testing.T) {
+	for _, keep := range []bool{false, true} {
+		cfg := config.NewConfig()
+		cfg.KeepTrailingNewline = keep
+		cfg.TrimBlocks = true
+		env := gonja.NewEnvironment(cfg, gonja.DefaultLoader)
+		tpl, err := env.FromString(streamSource)
+		if !assert.Nil(t, err) {
+			return
+		}
+		data := map[string]interface{}{"rows": []int{1, 2, 3}}
+
+		expected, err := tpl.Execute(data)
+		assert.Nil(t, err)
+
+		w := &chunkWriter{}
+		err = tpl.ExecuteWriter(data, w)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, w.String(), "keep_trailing_newline=%t", keep)
+		assert.True(t, len(w.chunks) > 1, "output should be written in several chunks")
+		for _, chunk := range w.chunks {
+			assert.NotEqual(t, "", chunk)
+		}
+	}
+}
+
+func TestExecuteWriterOnError(t *testing.T) {
+	env := gonja.NewEnvironment(config.NewConfig(), gonja.DefaultLoader)
+	tpl, err := env.FromString(`before{{ 'x' }}{{ fail() }}after`)
+	if !assert.Nil(t, err) {
+		return
+	}
+	data := map[string]interface{}{
+		"fail": func() (string, error) { return "", assert.AnError },
+	}
+
+	var streamed bytes.Buffer
+	err = tpl.ExecuteWriter(data, &streamed)
+	assert.NotNil(t, err)
+	assert.Equal(t, "beforex", streamed.String())
+
+	var buffered bytes.Buffer
+	err = tpl.ExecuteWriterBuffered(data, &buffered)
+	assert.NotNil(t, err)
+	assert.Equal(t, "", buffered.String())
+}
@@ -3,8 +3,11 @@ package django_test
 import (
 	"testing"
 
+	"github.com/stretchr/testify/assert"
+
 	"github.com/paradime-io/gonja"
 	"github.com/paradime-io/gonja/ext/django"
+	"github.com/paradime-io/gonja/meta"
 	tu "github.com/paradime-io/gonja/testutils"
 )
 
@@ -32,3 +35,19 @@ func TestDjangoStatements(t *testing.T) {
 	env := Env(root)
 	tu.GlobTemplateTests(t, root, env)
 }
+
+func TestDjangoAnalysis(t *testing.T) {
+	env := Env("./testData")
+	tpl, err := env.FromString(`{% cycle a b as row %}{{ row }}{% firstof c d %}
+{%- ifchanged e %}{{ f }}{% else %}{{ g }}{% endifchanged %}
+{%- ifequal h i %}{{ j }}{% else %}{{ k }}{% endifequal %}{% ifnotequal l m %}{{ n }}{% endifnotequal %}
+{%- widthratio o p 100 as width %}{{ width }}{% spaceless %}{{ q }}{% endspaceless %}
+{%- lorem %}{% comment %}{{ hidden }}{% endcomment %}{% templatetag openblock %}`)
+	if !assert.Nil(t, err) {
+		return
+	}
+	analysis := meta.Analyze(tpl.Root)
+	assert.True(t, analysis.Complete())
+	assert.Equal(t, []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k", "l", "m", "n", "o", "p", "q"},
+		analysis.Undeclared())
+}
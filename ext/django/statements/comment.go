@@ -3,6 +3,7 @@ package statements
 import (
 	"fmt"
 
+	"github.com/paradime-io/gonja/meta"
 	"github.com/paradime-io/gonja/nodes"
 	"github.com/paradime-io/gonja/parser"
 	"github.com/paradime-io/gonja/tokens"
@@ -18,6 +19,9 @@ func (stmt *CommentStmt) String() string {
 	return fmt.Sprintf("Block(Line=%d Col=%d)", t.Line, t.Col)
 }
 
+// Analyze reads and declares no variable
+func (stmt *CommentStmt) Analyze(a *meta.Analysis) {}
+
 func commentParser(p *parser.Parser, args *parser.Parser) (nodes.Statement, error) {
 	commentNode := &CommentStmt{p.Current()}
 
@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/paradime-io/gonja/exec"
+	"github.com/paradime-io/gonja/meta"
 	"github.com/paradime-io/gonja/nodes"
 	"github.com/paradime-io/gonja/parser"
 	"github.com/paradime-io/gonja/tokens"
@@ -78,6 +79,13 @@ func (stmt *CycleStatement) Execute(r *exec.Renderer, tag *nodes.StatementBlock)
 	return nil
 }
 
+func (stmt *CycleStatement) Analyze(a *meta.Analysis) {
+	for _, arg := range stmt.args {
+		a.Expression(arg)
+	}
+	a.Declare(stmt.asName)
+}
+
 // HINT: We're not supporting the old comma-separated list of expressions argument-style
 func cycleParser(p *parser.Parser, args *parser.Parser) (nodes.Statement, error) {
 	cycleNode := &CycleStatement{
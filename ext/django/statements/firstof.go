@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	"github.com/paradime-io/gonja/exec"
+	"github.com/paradime-io/gonja/meta"
 	"github.com/paradime-io/gonja/nodes"
 	"github.com/paradime-io/gonja/parser"
 	"github.com/paradime-io/gonja/tokens"
@@ -21,6 +22,8 @@ func (stmt *FirstofStmt) String() string {
 	return fmt.Sprintf("FirstofStmt(Args=%s, Line=%d Col=%d)", stmt.Args, t.Line, t.Col)
 }
 
+func (stmt *FirstofStmt) Children() []nodes.Node { return nodes.Append(nil, stmt.Args...) }
+
 func (stmt *FirstofStmt) Execute(r *exec.Renderer, tag *nodes.StatementBlock) error {
 	for _, arg := range stmt.Args {
 		val := r.Eval(arg)
@@ -37,6 +40,12 @@ func (stmt *FirstofStmt) Execute(r *exec.Renderer, tag *nodes.StatementBlock) er
 	return nil
 }
 
+func (stmt *FirstofStmt) Analyze(a *meta.Analysis) {
+	for _, arg := range stmt.Args {
+		a.Expression(arg)
+	}
+}
+
 func firstofParser(p *parser.Parser, args *parser.Parser) (nodes.Statement, error) {
 	stmt := &FirstofStmt{
 		Location: p.Current(),
@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/paradime-io/gonja/exec"
+	"github.com/paradime-io/gonja/meta"
 	"github.com/paradime-io/gonja/nodes"
 	"github.com/paradime-io/gonja/parser"
 	"github.com/paradime-io/gonja/tokens"
@@ -27,6 +28,14 @@ func (stmt *IfChangedStmt) String() string {
 	return fmt.Sprintf("IfChangedStmt(Line=%d Col=%d)", t.Line, t.Col)
 }
 
+func (stmt *IfChangedStmt) Children() []nodes.Node {
+	children := append(nodes.Append(nil, stmt.watchedExpr...), stmt.thenWrapper)
+	if stmt.elseWrapper != nil {
+		children = append(children, stmt.elseWrapper)
+	}
+	return children
+}
+
 func (stmt *IfChangedStmt) Execute(r *exec.Renderer, tag *nodes.StatementBlock) error {
 	if len(stmt.watchedExpr) == 0 {
 		// Check against own rendered body
@@ -84,6 +93,14 @@ func (stmt *IfChangedStmt) Execute(r *exec.Renderer, tag *nodes.StatementBlock)
 	return nil
 }
 
+func (stmt *IfChangedStmt) Analyze(a *meta.Analysis) {
+	for _, expr := range stmt.watchedExpr {
+		a.Expression(expr)
+	}
+	a.Scope(func() { a.Wrapper(stmt.thenWrapper) })
+	a.Scope(func() { a.Wrapper(stmt.elseWrapper) })
+}
+
 func ifchangedParser(p *parser.Parser, args *parser.Parser) (nodes.Statement, error) {
 	stmt := &IfChangedStmt{
 		Location: p.Current(),
@@ -3,6 +3,7 @@ package statements
 import (
 	"fmt"
 
+	"github.com/paradime-io/gonja/meta"
 	"github.com/paradime-io/gonja/nodes"
 	"github.com/paradime-io/gonja/parser"
 	"github.com/paradime-io/gonja/tokens"
@@ -42,6 +43,13 @@ func (stmt *IfNotEqualStmt) String() string {
 // 	return nil
 // }
 
+func (stmt *IfNotEqualStmt) Analyze(a *meta.Analysis) {
+	a.Expression(stmt.var1)
+	a.Expression(stmt.var2)
+	a.Scope(func() { a.Wrapper(stmt.thenWrapper) })
+	a.Scope(func() { a.Wrapper(stmt.elseWrapper) })
+}
+
 func ifNotEqualParser(p *parser.Parser, args *parser.Parser) (nodes.Statement, error) {
 	ifnotequalNode := &IfNotEqualStmt{}
 
@@ -9,6 +9,7 @@ import (
 	// "github.com/pkg/errors"
 
 	"github.com/paradime-io/gonja/exec"
+	"github.com/paradime-io/gonja/meta"
 	"github.com/paradime-io/gonja/nodes"
 	"github.com/paradime-io/gonja/parser"
 	"github.com/paradime-io/gonja/tokens"
@@ -43,6 +44,9 @@ func (stmt *LoremStmt) Execute(r *exec.Renderer, tag *nodes.StatementBlock) erro
 	return nil
 }
 
+// Analyze reads and declares no variable
+func (stmt *LoremStmt) Analyze(a *meta.Analysis) {}
+
 func loremParser(p *parser.Parser, args *parser.Parser) (nodes.Statement, error) {
 	stmt := &LoremStmt{
 		Location: p.Current(),
@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/paradime-io/gonja/exec"
+	"github.com/paradime-io/gonja/meta"
 	"github.com/paradime-io/gonja/nodes"
 	"github.com/paradime-io/gonja/parser"
 	"github.com/paradime-io/gonja/tokens"
@@ -24,6 +25,8 @@ func (stmt *SpacelessStmt) String() string {
 
 var spacelessRegexp = regexp.MustCompile(`(?U:(<.*>))([\t\n\v\f\r ]+)(?U:(<.*>))`)
 
+func (stmt *SpacelessStmt) Children() []nodes.Node { return []nodes.Node{stmt.wrapper} }
+
 func (stmt *SpacelessStmt) Execute(r *exec.Renderer, tag *nodes.StatementBlock) error {
 	var out strings.Builder
 
@@ -48,6 +51,10 @@ func (stmt *SpacelessStmt) Execute(r *exec.Renderer, tag *nodes.StatementBlock)
 	return nil
 }
 
+func (stmt *SpacelessStmt) Analyze(a *meta.Analysis) {
+	a.Scope(func() { a.Wrapper(stmt.wrapper) })
+}
+
 func spacelessParser(p *parser.Parser, args *parser.Parser) (nodes.Statement, error) {
 	stmt := &SpacelessStmt{
 		Location: p.Current(),
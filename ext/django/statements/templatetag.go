@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/paradime-io/gonja/exec"
+	"github.com/paradime-io/gonja/meta"
 	"github.com/paradime-io/gonja/nodes"
 	"github.com/paradime-io/gonja/parser"
 	"github.com/paradime-io/gonja/tokens"
@@ -36,6 +37,9 @@ func (node *TemplateTagStmt) Execute(r *exec.Renderer, tag *nodes.StatementBlock
 	return nil
 }
 
+// Analyze reads and declares no variable
+func (stmt *TemplateTagStmt) Analyze(a *meta.Analysis) {}
+
 func templateTagParser(p *parser.Parser, args *parser.Parser) (nodes.Statement, error) {
 	stmt := &TemplateTagStmt{}
 
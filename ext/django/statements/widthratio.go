@@ -5,6 +5,7 @@ import (
 	"math"
 
 	"github.com/paradime-io/gonja/exec"
+	"github.com/paradime-io/gonja/meta"
 	"github.com/paradime-io/gonja/nodes"
 	"github.com/paradime-io/gonja/parser"
 	"github.com/paradime-io/gonja/tokens"
@@ -23,6 +24,10 @@ func (stmt *WidthRatioStmt) String() string {
 	return fmt.Sprintf("WidthRatioStmt(Line=%d Col=%d)", t.Line, t.Col)
 }
 
+func (stmt *WidthRatioStmt) Children() []nodes.Node {
+	return nodes.Append(nil, stmt.current, stmt.max, stmt.width)
+}
+
 func (stmt *WidthRatioStmt) Execute(r *exec.Renderer, tag *nodes.StatementBlock) error {
 	current := r.Eval(stmt.current)
 	if current.IsError() {
@@ -50,6 +55,14 @@ func (stmt *WidthRatioStmt) Execute(r *exec.Renderer, tag *nodes.StatementBlock)
 	return nil
 }
 
+// Analyze declares the variable set by the 'as' form
+func (stmt *WidthRatioStmt) Analyze(a *meta.Analysis) {
+	a.Expression(stmt.current)
+	a.Expression(stmt.max)
+	a.Expression(stmt.width)
+	a.Declare(stmt.ctxName)
+}
+
 func widthratioParser(p *parser.Parser, args *parser.Parser) (nodes.Statement, error) {
 	stmt := &WidthRatioStmt{
 		Location: p.Current(),
@@ -0,0 +1,102 @@
+package i18n
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// contextSeparator separates the context from the message id in catalog keys
+const contextSeparator = "\x04"
+
+// Catalog holds the translations of a locale
+type Catalog struct {
+	Locale   string
+	Headers  map[string]string
+	NPlurals int
+	Plural   PluralFunc
+
+	messages map[string][]string // translated forms by message id
+}
+
+// NewCatalog returns an empty catalog for locale using the germanic plural rule
+func NewCatalog(locale string) *Catalog {
+	return &Catalog{
+		Locale:   locale,
+		Headers:  map[string]string{},
+		NPlurals: 2,
+		Plural:   germanic,
+		messages: map[string][]string{},
+	}
+}
+
+// Add registers the translated forms of a message.
+// Messages without any translation are ignored.
+func (c *Catalog) Add(msgid string, forms ...string) {
+	for _, form := range forms {
+		if form != "" {
+			c.messages[msgid] = forms
+			return
+		}
+	}
+}
+
+// Len returns the number of translated messages
+func (c *Catalog) Len() int {
+	return len(c.messages)
+}
+
+// setHeaders parses the metadata stored as the translation of the empty message id
+func (c *Catalog) setHeaders(header string) error {
+	for _, line := range strings.Split(header, "\n") {
+		kv := strings.SplitN(line, ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		c.Headers[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	if forms, ok := c.Headers["Plural-Forms"]; ok {
+		nplurals, plural, err := ParsePluralForms(forms)
+		if err != nil {
+			return errors.Wrapf(err, `Invalid catalog for locale '%s'`, c.Locale)
+		}
+		c.NPlurals = nplurals
+		c.Plural = plural
+	}
+	return nil
+}
+
+// Gettext returns the translation of msgid, msgid itself when missing
+func (c *Catalog) Gettext(msgid string) string {
+	if c != nil {
+		if forms, ok := c.messages[msgid]; ok && forms[0] != "" {
+			return forms[0]
+		}
+	}
+	return msgid
+}
+
+// Ngettext returns the translation of singular or plural depending on n
+func (c *Catalog) Ngettext(singular, plural string, n int) string {
+	if c != nil {
+		if forms, ok := c.messages[singular]; ok {
+			idx := c.Plural(n)
+			if idx >= 0 && idx < len(forms) && forms[idx] != "" {
+				return forms[idx]
+			}
+		}
+	}
+	if germanic(n) == 0 {
+		return singular
+	}
+	return plural
+}
+
+// Pgettext returns the translation of msgid within the given context
+func (c *Catalog) Pgettext(context, msgid string) string {
+	translated := c.Gettext(context + contextSeparator + msgid)
+	if translated == context+contextSeparator+msgid {
+		return msgid
+	}
+	return translated
+}
@@ -0,0 +1,101 @@
+package i18n
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/paradime-io/gonja/config"
+	"github.com/paradime-io/gonja/loaders"
+)
+
+type Config struct {
+	// Locale used when the rendering does not select one (see WithLocale)
+	Locale string
+	// Collapse the whitespaces of trans blocks by default
+	Trimmed bool
+	// Catalogs by locale
+	Catalogs map[string]*Catalog
+}
+
+func NewConfig() *Config {
+	return &Config{
+		Locale:   "",
+		Trimmed:  false,
+		Catalogs: map[string]*Catalog{},
+	}
+}
+
+func (cfg *Config) Inherit() config.Inheritable {
+	catalogs := map[string]*Catalog{}
+	for locale, catalog := range cfg.Catalogs {
+		catalogs[locale] = catalog
+	}
+	return &Config{
+		Locale:   cfg.Locale,
+		Trimmed:  cfg.Trimmed,
+		Catalogs: catalogs,
+	}
+}
+
+// DefaultConfig is a configuration with default values
+var DefaultConfig = NewConfig()
+
+// AddCatalog registers a catalog, replacing the one of the same locale
+func (cfg *Config) AddCatalog(catalog *Catalog) {
+	cfg.Catalogs[catalog.Locale] = catalog
+}
+
+// Load reads the .po or .mo catalog of locale from filename through loader
+func (cfg *Config) Load(loader loaders.Loader, locale, filename string) error {
+	fd, err := loader.Get(filename)
+	if err != nil {
+		return errors.Wrapf(err, `Unable to load the '%s' catalog`, locale)
+	}
+	catalog, err := ParseCatalog(locale, fd)
+	if err != nil {
+		return errors.Wrapf(err, `Unable to parse '%s'`, filename)
+	}
+	cfg.AddCatalog(catalog)
+	return nil
+}
+
+// Catalog returns the catalog of locale, falling back on its language
+// (ie. 'fr' for 'fr_CA'). It returns nil when there is none.
+func (cfg *Config) Catalog(locale string) *Catalog {
+	if catalog, ok := cfg.Catalogs[locale]; ok {
+		return catalog
+	}
+	if idx := strings.IndexAny(locale, "_-"); idx > 0 {
+		return cfg.Catalogs[locale[:idx]]
+	}
+	return nil
+}
+
+// catalogFor returns the catalog of the locale selected for a rendering
+func (cfg *Config) catalogFor(ctx context.Context) *Catalog {
+	locale, ok := LocaleFrom(ctx)
+	if !ok {
+		locale = cfg.Locale
+	}
+	return cfg.Catalog(locale)
+}
+
+type localeKey struct{}
+
+// WithLocale selects the locale of the renderings using ctx:
+//
+//	tpl.ExecuteContext(i18n.WithLocale(ctx, "fr"), data)
+func WithLocale(ctx context.Context, locale string) context.Context {
+	return context.WithValue(ctx, localeKey{}, locale)
+}
+
+// LocaleFrom returns the locale selected with WithLocale
+func LocaleFrom(ctx context.Context) (string, bool) {
+	if ctx == nil {
+		return "", false
+	}
+	locale, ok := ctx.Value(localeKey{}).(string)
+	return locale, ok
+}
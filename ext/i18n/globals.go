@@ -0,0 +1,107 @@
+package i18n
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/paradime-io/gonja/exec"
+	u "github.com/paradime-io/gonja/utils"
+)
+
+// Globals returns the gettext functions translating with cfg catalogs:
+//
+//	{{ _('Hello %(name)s!', name=user.name) }}
+//	{{ gettext('Hello') }}
+//	{{ ngettext('%(num)d apple', '%(num)d apples', apples|length) }}
+//
+// As with trans blocks, translated messages are trusted when autoescaping:
+// only the interpolated variables are escaped.
+func (cfg *Config) Globals() map[string]interface{} {
+	gettext := func(va *exec.VarArgs) *exec.Value {
+		if len(va.Args) != 1 {
+			return exec.AsValue(errors.Errorf(`gettext expects 1 argument, got %d`, len(va.Args)))
+		}
+		msg := cfg.catalogFor(va.Context).Gettext(va.Args[0].String())
+		return translated(msg, va.KwArgs, autoescape(va))
+	}
+	ngettext := func(va *exec.VarArgs) *exec.Value {
+		if len(va.Args) != 3 {
+			return exec.AsValue(errors.Errorf(`ngettext expects 3 arguments, got %d`, len(va.Args)))
+		}
+		if !va.Args[2].IsNumber() {
+			return exec.AsValue(errors.Errorf(`ngettext expects a number, got '%s'`, va.Args[2].String()))
+		}
+		n := va.Args[2].Integer()
+		msg := cfg.catalogFor(va.Context).Ngettext(va.Args[0].String(), va.Args[1].String(), n)
+		vars := map[string]*exec.Value{"num": va.Args[2]}
+		for name, value := range va.KwArgs {
+			vars[name] = value
+		}
+		return translated(msg, vars, autoescape(va))
+	}
+	return map[string]interface{}{
+		"_":        gettext,
+		"gettext":  gettext,
+		"ngettext": ngettext,
+	}
+}
+
+// autoescape returns whether the template calling a function escapes its output
+func autoescape(va *exec.VarArgs) bool {
+	cfg := va.Config()
+	return cfg != nil && cfg.Autoescape
+}
+
+// translated interpolates a translated message, marked as safe when autoescaping
+func translated(msg string, vars map[string]*exec.Value, autoescape bool) *exec.Value {
+	out, err := interpolate(msg, vars, autoescape)
+	if err != nil {
+		return exec.AsValue(err)
+	}
+	if autoescape {
+		return exec.AsSafeValue(out)
+	}
+	return exec.AsValue(out)
+}
+
+// interpolate replaces the python style placeholders, ie. '%(name)s', of msg.
+// '%%' stands for '%'.
+func interpolate(msg string, vars map[string]*exec.Value, escape bool) (string, error) {
+	if !strings.Contains(msg, "%") {
+		return msg, nil
+	}
+	var out strings.Builder
+	for idx := 0; idx < len(msg); idx++ {
+		c := msg[idx]
+		if c != '%' || idx+1 >= len(msg) {
+			out.WriteByte(c)
+			continue
+		}
+		switch msg[idx+1] {
+		case '%':
+			out.WriteByte('%')
+			idx++
+		case '(':
+			end := strings.IndexByte(msg[idx:], ')')
+			if end < 0 || idx+end+1 >= len(msg) {
+				return "", errors.Errorf(`Malformed placeholder in "%s"`, msg)
+			}
+			name := msg[idx+2 : idx+end]
+			value, ok := vars[name]
+			if !ok {
+				return "", errors.Errorf(`Missing variable '%s' in "%s"`, name, msg)
+			}
+			if escape && !value.Safe {
+				out.WriteString(u.Escape(value.String()))
+			} else {
+				out.WriteString(value.String())
+			}
+			// Skip the conversion type
+			idx += end + 1
+		default:
+			out.WriteByte(c)
+		}
+	}
+	return out.String(), nil
+}
@@ -0,0 +1,221 @@
+package i18n_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/paradime-io/gonja"
+	"github.com/paradime-io/gonja/ext/i18n"
+	"github.com/paradime-io/gonja/meta"
+	tu "github.com/paradime-io/gonja/testutils"
+)
+
+func Env(t *testing.T) *gonja.Environment {
+	env := tu.TestEnv("./testData")
+	cfg := i18n.NewConfig()
+	cfg.Locale = "fr"
+	if err := cfg.Load(env.Loader, "fr", "locale/fr.po"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Load(env.Loader, "pl", "locale/pl.mo"); err != nil {
+		t.Fatal(err)
+	}
+	env.Statements.Update(i18n.Statements)
+	env.Globals.Update(cfg.Globals())
+	env.Config.Ext["i18n"] = cfg
+	return env
+}
+
+var transCases = []struct {
+	name     string
+	locale   string
+	source   string
+	expected string
+}{
+	{"simple", "", `{% trans %}Hello{% endtrans %}`, "Bonjour"},
+	{"untranslated", "", `{% trans %}Good morning{% endtrans %}`, "Good morning"},
+	{"empty translation", "", `{% trans %}Untranslated{% endtrans %}`, "Untranslated"},
+	{"fuzzy", "", `{% trans %}Goodbye{% endtrans %}`, "Goodbye"},
+	{"variable", "", `{% trans %}Hello {{ name }}!{% endtrans %}`, "Bonjour John !"},
+	{"assigned variable", "", `{% trans name=user|upper %}Hello {{ name }}!{% endtrans %}`, "Bonjour JOHN !"},
+	{"escaped variable", "", `{% trans name="<b>" %}Hello {{ name }}!{% endtrans %}`, "Bonjour &lt;b&gt; !"},
+	{"safe variable", "", `{% trans name="<b>"|safe %}Hello {{ name }}!{% endtrans %}`, "Bonjour <b> !"},
+	{"percent", "", `{% trans %}100% sure{% endtrans %}`, "sûr à 100%"},
+	{"percent and variable", "", `{% trans rate=10 %}{{ rate }}% off{% endtrans %}`, "10% de remise"},
+	{"trimmed", "", "{% trans trimmed %}\n  Welcome to\n  {{ site }}\n{% endtrans %}", "Bienvenue sur gonja"},
+	{"not trimmed", "", "{% trans %}Welcome to {{ site }}{% endtrans %}", "Bienvenue sur gonja"},
+	{"comment", "", `{% trans %}Hel{# ignored #}lo{% endtrans %}`, "Bonjour"},
+	{"singular", "", `{% trans count=1 %}{{ count }} user{% pluralize %}{{ count }} users{% endtrans %}`, "1 utilisateur"},
+	{"plural", "", `{% trans count=3 %}{{ count }} user{% pluralize %}{{ count }} users{% endtrans %}`, "3 utilisateurs"},
+	{"french zero", "", `{% trans count=0 %}{{ count }} user{% pluralize %}{{ count }} users{% endtrans %}`, "0 utilisateur"},
+	{"plural count", "", `{% trans %}{{ users }} user{% pluralize users %}{{ users }} users{% endtrans %}`, "2 users"},
+	{"plural first variable", "", `{% trans %}{{ count }} user{% pluralize %}{{ count }} users{% endtrans %}`, "2 utilisateurs"},
+	{"polish one", "pl", `{% trans count=1 %}{{ count }} user{% pluralize %}{{ count }} users{% endtrans %}`, "1 użytkownik"},
+	{"polish few", "pl", `{% trans count=23 %}{{ count }} user{% pluralize %}{{ count }} users{% endtrans %}`, "23 użytkownicy"},
+	{"polish many", "pl", `{% trans count=12 %}{{ count }} user{% pluralize %}{{ count }} users{% endtrans %}`, "12 użytkowników"},
+	{"region fallback", "pl_PL", `{% trans %}Hello{% endtrans %}`, "Cześć"},
+	{"unknown locale", "de", `{% trans %}Hello{% endtrans %}`, "Hello"},
+	{"unknown locale plural", "de", `{% trans count=2 %}{{ count }} user{% pluralize %}{{ count }} users{% endtrans %}`, "2 users"},
+	{"gettext", "", `{{ gettext('Hello') }}`, "Bonjour"},
+	{"underscore", "", `{{ _('Hello %(name)s!', name=name) }}`, "Bonjour John !"},
+	{"underscore escaped", "", `{{ _('Hello %(name)s!', name='<b>') }}`, "Bonjour &lt;b&gt; !"},
+	{"underscore markup", "", `{{ _('<b>%(name)s</b>', name='<i>') }}`, "<b>&lt;i&gt;</b>"},
+	{"underscore without autoescape", "", `{% autoescape false %}{{ _('<b>%(name)s</b>', name='<i>') }}{% endautoescape %}`, "<b><i></b>"},
+	{"ngettext markup", "", `{{ ngettext('<b>%(num)d</b> apple', '<b>%(num)d</b> apples', 4) }}`, "<b>4</b> apples"},
+	{"ngettext", "", `{{ ngettext('%(num)d apple', '%(num)d apples', 4) }}`, "4 pommes"},
+	{"ngettext singular", "", `{{ ngettext('%(num)d apple', '%(num)d apples', 1) }}`, "1 pomme"},
+	{"ngettext polish", "pl", `{{ ngettext('%(count)s user', '%(count)s users', 5, count=5) }}`, "5 użytkowników"},
+}
+
+func TestTrans(t *testing.T) {
+	env := Env(t)
+	data := map[string]interface{}{
+		"name":  "John",
+		"user":  "john",
+		"site":  "gonja",
+		"count": 2,
+		"users": 2,
+	}
+	for _, tc := range transCases {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			tpl, err := env.FromString(test.source)
+			if !assert.Nil(t, err) {
+				return
+			}
+			ctx := context.Background()
+			if test.locale != "" {
+				ctx = i18n.WithLocale(ctx, test.locale)
+			}
+			out, err := tpl.ExecuteContext(ctx, data)
+			if assert.Nil(t, err) {
+				assert.Equal(t, test.expected, out)
+			}
+		})
+	}
+}
+
+var transErrorCases = []struct {
+	name   string
+	source string
+	err    string
+}{
+	{"statement", `{% trans %}{% if true %}x{% endif %}{% endtrans %}`, "A trans block can only contain text and variables."},
+	{"expression", `{% trans %}{{ name|upper }}{% endtrans %}`, "A trans block can only contain text and variables."},
+	{"pluralize without variable", `{% trans %}user{% pluralize %}users{% endtrans %}`, "Tag 'pluralize' requires a variable to count."},
+	{"twice", `{% trans a=1, a=2 %}{{ a }}{% endtrans %}`, "Variable 'a' defined twice."},
+	{"unclosed", `{% trans %}Hello`, "endtrans"},
+}
+
+func TestTransErrors(t *testing.T) {
+	env := Env(t)
+	for _, tc := range transErrorCases {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			_, err := env.FromString(test.source)
+			if assert.NotNil(t, err) {
+				assert.Contains(t, err.Error(), test.err)
+			}
+		})
+	}
+}
+
+func TestTransRenderErrors(t *testing.T) {
+	env := Env(t)
+	tpl, err := env.FromString(`{% trans count="many" %}{{ count }} user{% pluralize %}{{ count }} users{% endtrans %}`)
+	if !assert.Nil(t, err) {
+		return
+	}
+	_, err = tpl.Execute(nil)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "'count' must be a number to pluralize")
+	}
+
+	tpl, err = env.FromString(`{{ _('Hello %(name)s!') }}`)
+	if !assert.Nil(t, err) {
+		return
+	}
+	_, err = tpl.Execute(nil)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "Missing variable 'name'")
+	}
+}
+
+func TestParsePO(t *testing.T) {
+	po := `
+msgid ""
+msgstr "Plural-Forms: nplurals=3; plural=n%10==1 && n%100!=11 ? 0 : n != 0 ? 1 : 2;\n"
+
+msgctxt "month"
+msgid "May"
+msgstr "Mai"
+
+msgid "May"
+msgstr "peut"
+
+#~ msgid "Old"
+#~ msgstr "Vieux"
+`
+	catalog, err := i18n.ParsePO("ro", strings.NewReader(po))
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, 3, catalog.NPlurals)
+	assert.Equal(t, 2, catalog.Len())
+	assert.Equal(t, "Mai", catalog.Pgettext("month", "May"))
+	assert.Equal(t, "June", catalog.Pgettext("month", "June"))
+	assert.Equal(t, "peut", catalog.Gettext("May"))
+	assert.Equal(t, "Old", catalog.Gettext("Old"))
+	assert.Equal(t, 0, catalog.Plural(21))
+	assert.Equal(t, 1, catalog.Plural(11))
+	assert.Equal(t, 2, catalog.Plural(0))
+
+	_, err = i18n.ParsePO("ro", strings.NewReader(`msgfoo "bar"`))
+	assert.NotNil(t, err)
+	_, err = i18n.ParsePO("ro", strings.NewReader(`"dangling"`))
+	assert.NotNil(t, err)
+}
+
+var pluralCases = []struct {
+	expr     string
+	n        int
+	expected int
+}{
+	{"0", 5, 0},
+	{"n != 1", 1, 0},
+	{"n != 1", 0, 1},
+	{"(n > 1)", 1, 0},
+	{"(n > 1)", 2, 1},
+	{"n==1 ? 0 : n==2 ? 1 : 2", 2, 1},
+	{"n==1 ? 0 : n==2 ? 1 : 2", 7, 2},
+	{"(n%10==1 && n%100!=11 ? 0 : n%10>=2 && n%10<=4 && (n%100<10 || n%100>=20) ? 1 : 2)", 101, 0},
+	{"(n%10==1 && n%100!=11 ? 0 : n%10>=2 && n%10<=4 && (n%100<10 || n%100>=20) ? 1 : 2)", 14, 2},
+	{"(n%10==1 && n%100!=11 ? 0 : n%10>=2 && n%10<=4 && (n%100<10 || n%100>=20) ? 1 : 2)", 22, 1},
+	{"!(n - 1)", 1, 1},
+	{"n / 2 + -1", 6, 2},
+}
+
+func TestCompilePlural(t *testing.T) {
+	for _, tc := range pluralCases {
+		test := tc
+		plural, err := i18n.CompilePlural(test.expr)
+		if assert.Nil(t, err, test.expr) {
+			assert.Equal(t, test.expected, plural(test.n), "%s with n=%d", test.expr, test.n)
+		}
+	}
+	for _, expr := range []string{"", "n ==", "(n > 1", "n ? 1", "x"} {
+		_, err := i18n.CompilePlural(expr)
+		assert.NotNil(t, err, expr)
+	}
+}
+
+func TestTransAnalysis(t *testing.T) {
+	env := Env(t)
+	tpl, err := env.FromString(`{% trans n=users|length %}{{ n }} user of {{ site }}{% pluralize %}{{ n }} users of {{ site }}{% endtrans %}`)
+	if assert.Nil(t, err) {
+		assert.Equal(t, []string{"site", "users"}, meta.FindUndeclaredVariables(tpl.Root))
+	}
+}
@@ -0,0 +1,106 @@
+package i18n
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	moMagicLittleEndian = 0x950412de
+	moMagicBigEndian    = 0xde120495
+)
+
+// ParseMO reads a catalog from the content of a compiled gettext .mo file
+func ParseMO(locale string, r io.Reader) (*Catalog, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrapf(err, `Unable to read the '%s' catalog`, locale)
+	}
+	if len(data) < 28 {
+		return nil, errors.Errorf(`Invalid '%s' catalog: file too short`, locale)
+	}
+
+	var order binary.ByteOrder
+	switch binary.LittleEndian.Uint32(data) {
+	case moMagicLittleEndian:
+		order = binary.LittleEndian
+	case moMagicBigEndian:
+		order = binary.BigEndian
+	default:
+		return nil, errors.Errorf(`Invalid '%s' catalog: bad magic number`, locale)
+	}
+	if major := order.Uint32(data[4:]) >> 16; major > 1 {
+		return nil, errors.Errorf(`Invalid '%s' catalog: unsupported revision %d`, locale, major)
+	}
+	count := int(order.Uint32(data[8:]))
+	originals := int(order.Uint32(data[12:]))
+	translations := int(order.Uint32(data[16:]))
+
+	// str reads the idx-th string of the table starting at offset
+	str := func(table, idx int) (string, error) {
+		pos := table + idx*8
+		if pos < 0 || pos+8 > len(data) {
+			return "", errors.Errorf(`Invalid '%s' catalog: string table out of bounds`, locale)
+		}
+		length := int(order.Uint32(data[pos:]))
+		offset := int(order.Uint32(data[pos+4:]))
+		if offset < 0 || length < 0 || offset+length > len(data) {
+			return "", errors.Errorf(`Invalid '%s' catalog: string out of bounds`, locale)
+		}
+		return string(data[offset : offset+length]), nil
+	}
+
+	catalog := NewCatalog(locale)
+	var header string
+	for idx := 0; idx < count; idx++ {
+		original, err := str(originals, idx)
+		if err != nil {
+			return nil, err
+		}
+		translation, err := str(translations, idx)
+		if err != nil {
+			return nil, err
+		}
+		// Plural entries are stored as "singular\x00plural"
+		msgid := original
+		if nul := strings.IndexByte(original, 0); nul >= 0 {
+			msgid = original[:nul]
+		}
+		if msgid == "" {
+			header = translation
+			continue
+		}
+		catalog.Add(msgid, strings.Split(translation, "\x00")...)
+	}
+
+	if err := catalog.setHeaders(header); err != nil {
+		return nil, err
+	}
+	return catalog, nil
+}
+
+// isMO tells whether data starts like a compiled catalog
+func isMO(data []byte) bool {
+	if len(data) < 4 {
+		return false
+	}
+	magic := binary.LittleEndian.Uint32(data)
+	return magic == moMagicLittleEndian || magic == moMagicBigEndian
+}
+
+// ParseCatalog reads either a .po or a .mo catalog, guessed from its content
+func ParseCatalog(locale string, r io.Reader) (*Catalog, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrapf(err, `Unable to read the '%s' catalog`, locale)
+	}
+	if isMO(data) {
+		return ParseMO(locale, bytes.NewReader(data))
+	}
+	return ParsePO(locale, bytes.NewReader(data))
+}
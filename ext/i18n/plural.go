@@ -0,0 +1,287 @@
+package i18n
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// PluralFunc returns the index of the plural form to use for n
+type PluralFunc func(n int) int
+
+// germanic is the plural rule used when a catalog does not define one
+func germanic(n int) int {
+	if n != 1 {
+		return 1
+	}
+	return 0
+}
+
+// ParsePluralForms parses a gettext Plural-Forms header value,
+// ie. "nplurals=2; plural=(n != 1);"
+func ParsePluralForms(header string) (int, PluralFunc, error) {
+	nplurals := 0
+	var expr string
+	for _, part := range strings.Split(header, ";") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch strings.TrimSpace(kv[0]) {
+		case "nplurals":
+			n, err := strconv.Atoi(strings.TrimSpace(kv[1]))
+			if err != nil || n <= 0 {
+				return 0, nil, errors.Errorf(`Invalid nplurals in "%s"`, header)
+			}
+			nplurals = n
+		case "plural":
+			expr = kv[1]
+		}
+	}
+	if nplurals == 0 || expr == "" {
+		return 0, nil, errors.Errorf(`Invalid plural forms "%s"`, header)
+	}
+	fn, err := CompilePlural(expr)
+	if err != nil {
+		return 0, nil, err
+	}
+	return nplurals, fn, nil
+}
+
+// CompilePlural compiles a gettext plural expression (a C expression of n)
+func CompilePlural(expr string) (PluralFunc, error) {
+	p := &pluralParser{src: expr}
+	if err := p.tokenize(); err != nil {
+		return nil, err
+	}
+	fn, err := p.ternary()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, errors.Errorf(`Unexpected "%s" in plural expression "%s"`, p.tokens[p.pos], expr)
+	}
+	return fn, nil
+}
+
+type pluralParser struct {
+	src    string
+	tokens []string
+	pos    int
+}
+
+var pluralOperators = []string{"==", "!=", "<=", ">=", "&&", "||", "<", ">", "!", "?", ":", "+", "-", "*", "/", "%", "(", ")"}
+
+func (p *pluralParser) tokenize() error {
+	src := p.src
+	for i := 0; i < len(src); {
+		c := rune(src[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == 'n':
+			p.tokens = append(p.tokens, "n")
+			i++
+		case unicode.IsDigit(c):
+			j := i
+			for j < len(src) && unicode.IsDigit(rune(src[j])) {
+				j++
+			}
+			p.tokens = append(p.tokens, src[i:j])
+			i = j
+		default:
+			matched := false
+			for _, op := range pluralOperators {
+				if strings.HasPrefix(src[i:], op) {
+					p.tokens = append(p.tokens, op)
+					i += len(op)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return errors.Errorf(`Unexpected character '%c' in plural expression "%s"`, c, src)
+			}
+		}
+	}
+	return nil
+}
+
+func (p *pluralParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *pluralParser) match(ops ...string) string {
+	current := p.peek()
+	for _, op := range ops {
+		if current == op {
+			p.pos++
+			return op
+		}
+	}
+	return ""
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func (p *pluralParser) ternary() (PluralFunc, error) {
+	cond, err := p.or()
+	if err != nil || p.match("?") == "" {
+		return cond, err
+	}
+	yes, err := p.ternary()
+	if err != nil {
+		return nil, err
+	}
+	if p.match(":") == "" {
+		return nil, errors.Errorf(`Expected ':' in plural expression "%s"`, p.src)
+	}
+	no, err := p.ternary()
+	if err != nil {
+		return nil, err
+	}
+	return func(n int) int {
+		if cond(n) != 0 {
+			return yes(n)
+		}
+		return no(n)
+	}, nil
+}
+
+// binary parses a left associative sequence of operators of the same precedence
+func (p *pluralParser) binary(operand func() (PluralFunc, error), ops ...string) (PluralFunc, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for op := p.match(ops...); op != ""; op = p.match(ops...) {
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryOp(op, left, right)
+	}
+	return left, nil
+}
+
+func binaryOp(op string, left, right PluralFunc) PluralFunc {
+	return func(n int) int {
+		l, r := left(n), right(n)
+		switch op {
+		case "||":
+			return boolToInt(l != 0 || r != 0)
+		case "&&":
+			return boolToInt(l != 0 && r != 0)
+		case "==":
+			return boolToInt(l == r)
+		case "!=":
+			return boolToInt(l != r)
+		case "<":
+			return boolToInt(l < r)
+		case "<=":
+			return boolToInt(l <= r)
+		case ">":
+			return boolToInt(l > r)
+		case ">=":
+			return boolToInt(l >= r)
+		case "+":
+			return l + r
+		case "-":
+			return l - r
+		case "*":
+			return l * r
+		case "/":
+			if r == 0 {
+				return 0
+			}
+			return l / r
+		case "%":
+			if r == 0 {
+				return 0
+			}
+			return l % r
+		}
+		return 0
+	}
+}
+
+func (p *pluralParser) or() (PluralFunc, error) {
+	return p.binary(p.and, "||")
+}
+
+func (p *pluralParser) and() (PluralFunc, error) {
+	return p.binary(p.equality, "&&")
+}
+
+func (p *pluralParser) equality() (PluralFunc, error) {
+	return p.binary(p.relational, "==", "!=")
+}
+
+func (p *pluralParser) relational() (PluralFunc, error) {
+	return p.binary(p.additive, "<=", ">=", "<", ">")
+}
+
+func (p *pluralParser) additive() (PluralFunc, error) {
+	return p.binary(p.multiplicative, "+", "-")
+}
+
+func (p *pluralParser) multiplicative() (PluralFunc, error) {
+	return p.binary(p.unary, "*", "/", "%")
+}
+
+func (p *pluralParser) unary() (PluralFunc, error) {
+	switch p.match("!", "-") {
+	case "!":
+		operand, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return func(n int) int { return boolToInt(operand(n) == 0) }, nil
+	case "-":
+		operand, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return func(n int) int { return -operand(n) }, nil
+	}
+	return p.primary()
+}
+
+func (p *pluralParser) primary() (PluralFunc, error) {
+	tok := p.peek()
+	switch {
+	case tok == "n":
+		p.pos++
+		return func(n int) int { return n }, nil
+	case tok == "(":
+		p.pos++
+		inner, err := p.ternary()
+		if err != nil {
+			return nil, err
+		}
+		if p.match(")") == "" {
+			return nil, errors.Errorf(`Expected ')' in plural expression "%s"`, p.src)
+		}
+		return inner, nil
+	case tok != "" && unicode.IsDigit(rune(tok[0])):
+		p.pos++
+		value, err := strconv.Atoi(tok)
+		if err != nil {
+			return nil, errors.Wrapf(err, `Invalid number in plural expression "%s"`, p.src)
+		}
+		return func(int) int { return value }, nil
+	case tok == "":
+		return nil, errors.Errorf(`Unexpected end of plural expression "%s"`, p.src)
+	}
+	return nil, errors.Errorf(`Unexpected "%s" in plural expression "%s"`, tok, p.src)
+}
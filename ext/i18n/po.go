@@ -0,0 +1,148 @@
+package i18n
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// poEntry is a message being read from a .po file
+type poEntry struct {
+	context  *string
+	msgid    *string
+	plural   *string
+	forms    map[int]*string
+	fuzzy    bool
+	obsolete bool
+}
+
+// ParsePO reads a catalog from the content of a gettext .po file.
+// Fuzzy entries are ignored, like gettext does.
+func ParsePO(locale string, r io.Reader) (*Catalog, error) {
+	catalog := NewCatalog(locale)
+	entry := &poEntry{forms: map[int]*string{}}
+	var current *string // string continued by the next quoted lines
+	var header string
+
+	flush := func() {
+		if entry.msgid != nil && !entry.obsolete {
+			key := *entry.msgid
+			if entry.context != nil {
+				key = *entry.context + contextSeparator + key
+			}
+			count := 0
+			for idx := range entry.forms {
+				if idx >= count {
+					count = idx + 1
+				}
+			}
+			forms := make([]string, count)
+			for idx, form := range entry.forms {
+				forms[idx] = *form
+			}
+			if key == "" {
+				if len(forms) > 0 {
+					header = forms[0]
+				}
+			} else if !entry.fuzzy {
+				catalog.Add(key, forms...)
+			}
+		}
+		entry = &poEntry{forms: map[int]*string{}}
+		current = nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	lineno := 0
+	for scanner.Scan() {
+		lineno++
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+			flush()
+		case strings.HasPrefix(line, "#~"):
+			// Obsolete entries are kept as comments
+			entry.obsolete = true
+		case strings.HasPrefix(line, "#,"):
+			if entry.msgid != nil {
+				flush()
+			}
+			for _, flag := range strings.Split(line[2:], ",") {
+				if strings.TrimSpace(flag) == "fuzzy" {
+					entry.fuzzy = true
+				}
+			}
+		case strings.HasPrefix(line, "#"):
+			if entry.msgid != nil {
+				flush()
+			}
+		case strings.HasPrefix(line, `"`):
+			if current == nil {
+				return nil, errors.Errorf(`Unexpected string on line %d of the '%s' catalog`, lineno, locale)
+			}
+			value, err := strconv.Unquote(line)
+			if err != nil {
+				return nil, errors.Wrapf(err, `Invalid string on line %d of the '%s' catalog`, lineno, locale)
+			}
+			*current += value
+		default:
+			keyword, value, err := poKeyword(line)
+			if err != nil {
+				return nil, errors.Wrapf(err, `Invalid line %d of the '%s' catalog`, lineno, locale)
+			}
+			if (keyword == "msgctxt" || keyword == "msgid") && entry.msgid != nil {
+				// New entry without blank line separator
+				flush()
+			}
+			switch {
+			case keyword == "msgctxt":
+				entry.context = &value
+				current = entry.context
+			case keyword == "msgid":
+				entry.msgid = &value
+				current = entry.msgid
+			case keyword == "msgid_plural":
+				entry.plural = &value
+				current = entry.plural
+			case keyword == "msgstr":
+				entry.forms[0] = &value
+				current = &value
+			case strings.HasPrefix(keyword, "msgstr["):
+				idx, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(keyword, "msgstr["), "]"))
+				if err != nil || idx < 0 {
+					return nil, errors.Errorf(`Invalid plural index on line %d of the '%s' catalog`, lineno, locale)
+				}
+				entry.forms[idx] = &value
+				current = &value
+			default:
+				return nil, errors.Errorf(`Unknown keyword '%s' on line %d of the '%s' catalog`, keyword, lineno, locale)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrapf(err, `Unable to read the '%s' catalog`, locale)
+	}
+	flush()
+
+	if err := catalog.setHeaders(header); err != nil {
+		return nil, err
+	}
+	return catalog, nil
+}
+
+// poKeyword splits a line such as `msgid "text"`
+func poKeyword(line string) (string, string, error) {
+	idx := strings.IndexAny(line, " \t")
+	if idx < 0 {
+		return "", "", errors.Errorf(`Missing string after '%s'`, line)
+	}
+	value, err := strconv.Unquote(strings.TrimSpace(line[idx:]))
+	if err != nil {
+		return "", "", err
+	}
+	return line[:idx], value, nil
+}
@@ -0,0 +1,12 @@
+// Package i18n translates templates with gettext catalogs:
+//
+//	cfg := i18n.NewConfig()
+//	cfg.Load(env.Loader, "fr", "locale/fr.po")
+//	env.Statements.Update(i18n.Statements)
+//	env.Globals.Update(cfg.Globals())
+//	env.Config.Ext["i18n"] = cfg
+package i18n
+
+import "github.com/paradime-io/gonja/exec"
+
+var Statements = exec.StatementSet{}
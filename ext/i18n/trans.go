@@ -0,0 +1,221 @@
+package i18n
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/paradime-io/gonja/exec"
+	"github.com/paradime-io/gonja/meta"
+	"github.com/paradime-io/gonja/nodes"
+	"github.com/paradime-io/gonja/parser"
+	"github.com/paradime-io/gonja/tokens"
+)
+
+// TransStmt translates its body:
+//
+//	{% trans count=users|length %}One user{% pluralize %}{{ count }} users{% endtrans %}
+type TransStmt struct {
+	Location *tokens.Token
+	Singular string // message id, variables being '%(name)s' placeholders
+	Plural   string
+	Count    string   // variable selecting the plural form
+	Names    []string // variables, by order of appearance
+	Vars     map[string]nodes.Expression
+
+	Placeholders bool // whether the messages use variables
+}
+
+func (stmt *TransStmt) Position() *tokens.Token { return stmt.Location }
+func (stmt *TransStmt) String() string {
+	t := stmt.Position()
+	return fmt.Sprintf("TransStmt(Line=%d Col=%d)", t.Line, t.Col)
+}
+
+func (stmt *TransStmt) Execute(r *exec.Renderer, tag *nodes.StatementBlock) error {
+	vars := map[string]*exec.Value{}
+	for _, name := range stmt.Names {
+		value := r.Eval(stmt.Vars[name])
+		if value.IsError() {
+			return errors.Wrapf(value, `Unable to evaluate '%s'`, name)
+		}
+		vars[name] = value
+	}
+
+	cfg := DefaultConfig
+	if ext, ok := r.Config.Ext["i18n"].(*Config); ok {
+		cfg = ext
+	}
+	catalog := cfg.catalogFor(r.Context)
+
+	var msg string
+	if stmt.Plural != "" {
+		count := vars[stmt.Count]
+		if !count.IsNumber() {
+			return errors.Errorf(`'%s' must be a number to pluralize, got '%s'`, stmt.Count, count.String())
+		}
+		msg = catalog.Ngettext(stmt.Singular, stmt.Plural, count.Integer())
+	} else {
+		msg = catalog.Gettext(stmt.Singular)
+	}
+
+	if stmt.Placeholders {
+		var err error
+		msg, err = interpolate(msg, vars, r.Autoescape)
+		if err != nil {
+			return err
+		}
+	}
+	r.RenderValue(exec.AsSafeValue(msg))
+	return nil
+}
+
+func (stmt *TransStmt) Children() []nodes.Node {
+	children := []nodes.Node{}
+	for _, name := range stmt.Names {
+		children = nodes.Append(children, stmt.Vars[name])
+	}
+	return children
+}
+
+func (stmt *TransStmt) Analyze(a *meta.Analysis) {
+	for _, name := range stmt.Names {
+		a.Expression(stmt.Vars[name])
+	}
+}
+
+var transWhitespaces = regexp.MustCompile(`\s*\n\s*`)
+
+// transBody builds a message id from a trans block body
+func transBody(p *parser.Parser, wrapper *nodes.Wrapper, trimmed bool) (string, []*tokens.Token, error) {
+	var msg strings.Builder
+	var referenced []*tokens.Token
+	for _, node := range wrapper.Nodes {
+		switch n := node.(type) {
+		case *nodes.Comment:
+			continue
+		case *nodes.Data:
+			msg.WriteString(strings.ReplaceAll(n.Data.Val, "%", "%%"))
+			continue
+		case *nodes.Output:
+			if name, ok := n.Expression.(*nodes.Name); ok {
+				msg.WriteString("%(" + name.Name.Val + ")s")
+				referenced = append(referenced, name.Name)
+				continue
+			}
+		}
+		return "", nil, p.Error("A trans block can only contain text and variables.", node.Position())
+	}
+	out := msg.String()
+	if trimmed {
+		out = transWhitespaces.ReplaceAllString(strings.TrimSpace(out), " ")
+	}
+	return out, referenced, nil
+}
+
+func transParser(p *parser.Parser, args *parser.Parser) (nodes.Statement, error) {
+	stmt := &TransStmt{
+		Location: p.Current(),
+		Vars:     map[string]nodes.Expression{},
+	}
+
+	trimmed := DefaultConfig.Trimmed
+	if ext, ok := p.Config.Ext["i18n"].(*Config); ok {
+		trimmed = ext.Trimmed
+	}
+
+	// Variables and options
+	for !args.End() {
+		if len(stmt.Names) > 0 {
+			args.Match(tokens.Comma)
+		}
+		name := args.Match(tokens.Name)
+		if name == nil {
+			return nil, args.Error("Expected a variable name.", args.Current())
+		}
+		var expr nodes.Expression
+		if args.Match(tokens.Assign) != nil {
+			value, err := args.ParseExpression()
+			if err != nil {
+				return nil, err
+			}
+			expr = value
+		} else if name.Val == "trimmed" || name.Val == "notrimmed" {
+			trimmed = name.Val == "trimmed"
+			continue
+		} else {
+			expr = &nodes.Name{Name: name}
+		}
+		if _, exists := stmt.Vars[name.Val]; exists {
+			return nil, args.Error(fmt.Sprintf("Variable '%s' defined twice.", name.Val), name)
+		}
+		stmt.Vars[name.Val] = expr
+		stmt.Names = append(stmt.Names, name.Val)
+	}
+
+	wrapper, endargs, err := p.WrapUntil("pluralize", "endtrans")
+	if err != nil {
+		return nil, err
+	}
+	singular, referenced, err := transBody(p, wrapper, trimmed)
+	if err != nil {
+		return nil, err
+	}
+	stmt.Singular = singular
+	stmt.Placeholders = len(referenced) > 0
+
+	if wrapper.EndTag == "pluralize" {
+		if count := endargs.Match(tokens.Name); count != nil {
+			stmt.Count = count.Val
+			referenced = append(referenced, count)
+		}
+		if !endargs.End() {
+			return nil, endargs.Error("Tag 'pluralize' only takes a variable name.", nil)
+		}
+		wrapper, endargs, err = p.WrapUntil("endtrans")
+		if err != nil {
+			return nil, err
+		}
+		plural, pluralReferenced, err := transBody(p, wrapper, trimmed)
+		if err != nil {
+			return nil, err
+		}
+		stmt.Plural = plural
+		stmt.Placeholders = stmt.Placeholders || len(pluralReferenced) > 0
+		referenced = append(referenced, pluralReferenced...)
+	}
+	if !endargs.End() {
+		return nil, endargs.Error("Arguments not allowed here.", nil)
+	}
+
+	// Variables used but not declared are taken from the context
+	for _, name := range referenced {
+		if _, exists := stmt.Vars[name.Val]; !exists {
+			stmt.Vars[name.Val] = &nodes.Name{Name: name}
+			stmt.Names = append(stmt.Names, name.Val)
+		}
+	}
+
+	if stmt.Plural != "" && stmt.Count == "" {
+		// Like Jinja, the first variable chooses the plural form
+		if len(stmt.Names) == 0 {
+			return nil, p.Error("Tag 'pluralize' requires a variable to count.", stmt.Location)
+		}
+		stmt.Count = stmt.Names[0]
+	}
+
+	if !stmt.Placeholders {
+		// Without any placeholder, percents don't need to be escaped
+		stmt.Singular = strings.ReplaceAll(stmt.Singular, "%%", "%")
+		stmt.Plural = strings.ReplaceAll(stmt.Plural, "%%", "%")
+	}
+
+	return stmt, nil
+}
+
+func init() {
+	Statements.Register("trans", transParser)
+	exec.RegisterNodes(&TransStmt{})
+}
@@ -10,6 +10,7 @@ import (
 	arrow "github.com/bmuller/arrow/lib"
 
 	"github.com/paradime-io/gonja/exec"
+	"github.com/paradime-io/gonja/meta"
 	"github.com/paradime-io/gonja/nodes"
 	"github.com/paradime-io/gonja/parser"
 	"github.com/paradime-io/gonja/tokens"
@@ -76,6 +77,9 @@ func (stmt *NowStmt) Execute(r *exec.Renderer, tag *nodes.StatementBlock) error
 	return nil
 }
 
+// Analyze reads and declares no variable
+func (stmt *NowStmt) Analyze(a *meta.Analysis) {}
+
 func nowParser(p *parser.Parser, args *parser.Parser) (nodes.Statement, error) {
 	stmt := &NowStmt{
 		Location: p.Current(),
@@ -159,4 +163,5 @@ func parseTimeOffset(offset string, add bool) (*TimeOffset, error) {
 
 func init() {
 	Statements.Register("now", nowParser)
+	exec.RegisterNodes(&NowStmt{})
 }
@@ -0,0 +1,75 @@
+package gonja_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/paradime-io/gonja"
+	"github.com/paradime-io/gonja/config"
+	"github.com/paradime-io/gonja/loaders"
+)
+
+func TestDynamicExtends(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"default.tpl":  "default({% block content %}{% endblock %})",
+		"custom.tpl":   "custom({% block content %}{% endblock %})",
+		"middle.tpl":   "{% extends layout %}{% block content %}middle[{% block inner %}{% endblock %}]{% endblock %}",
+		"broken.tpl":   "{% if %}",
+		"variable.tpl": "{% extends layout %}{% block content %}{{ self.title() }}{% endblock %}{% block title %}page{% endblock %}",
+		"list.tpl":     "{% extends ['missing.tpl', 'custom.tpl', 'default.tpl'] %}{% block content %}list{% endblock %}",
+		"dynlist.tpl":  "{% extends [tenant ~ '.tpl', 'default.tpl'] %}{% block content %}{{ tenant }}{% endblock %}",
+		"inline.tpl":   "{% extends 'custom.tpl' if custom else 'default.tpl' %}{% block content %}inline{% endblock %}",
+		"chain.tpl":    "{% extends 'middle.tpl' %}{% block inner %}chain{% endblock %}",
+		"self.tpl":     "{% extends layout %}",
+		"bad.tpl":      "{% extends ['missing.tpl', 'broken.tpl', 'default.tpl'] %}",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	env := gonja.NewEnvironment(config.NewConfig(), loaders.MustNewFileSystemLoader(dir))
+
+	cases := []struct {
+		template string
+		data     map[string]interface{}
+		expected string
+		err      string
+	}{
+		{"variable.tpl", map[string]interface{}{"layout": "default.tpl"}, "default(page)", ""},
+		{"variable.tpl", map[string]interface{}{"layout": "custom.tpl"}, "custom(page)", ""},
+		{"list.tpl", nil, "custom(list)", ""},
+		{"dynlist.tpl", map[string]interface{}{"tenant": "custom"}, "custom(custom)", ""},
+		{"dynlist.tpl", map[string]interface{}{"tenant": "acme"}, "default(acme)", ""},
+		{"inline.tpl", map[string]interface{}{"custom": true}, "custom(inline)", ""},
+		{"inline.tpl", map[string]interface{}{"custom": false}, "default(inline)", ""},
+		{"chain.tpl", map[string]interface{}{"layout": "custom.tpl"}, "custom(middle[chain])", ""},
+		{"chain.tpl", map[string]interface{}{"layout": "default.tpl"}, "default(middle[chain])", ""},
+		{"variable.tpl", map[string]interface{}{"layout": "missing.tpl"}, "", "Unable to parse parent template 'missing.tpl'"},
+		{"variable.tpl", map[string]interface{}{"layout": []string{"missing.tpl", "broken.tpl"}}, "", "Unable to parse any parent template"},
+		{"self.tpl", map[string]interface{}{"layout": "self.tpl"}, "", "Template 'self.tpl' extends itself"},
+	}
+	for _, test := range cases {
+		tpl, err := env.FromFile(test.template)
+		if !assert.Nil(t, err, test.template) {
+			continue
+		}
+		out, err := tpl.Execute(test.data)
+		if test.err != "" {
+			if assert.NotNil(t, err, test.template) {
+				assert.Contains(t, err.Error(), test.err)
+			}
+			continue
+		}
+		assert.Nil(t, err, test.template)
+		assert.Equal(t, test.expected, out, test.template)
+	}
+
+	// Broken templates are reported instead of being skipped
+	_, err := env.FromFile("bad.tpl")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,125 @@
+package gonja_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/paradime-io/gonja"
+	"github.com/paradime-io/gonja/config"
+	"github.com/paradime-io/gonja/exec"
+	"github.com/paradime-io/gonja/loaders"
+)
+
+var limitsCases = []struct {
+	name   string
+	limits exec.Limits
+	source string
+	limit  string
+	line   int
+}{
+	{"output size", exec.Limits{MaxOutputSize: 50},
+		"{% for i in range(100) %}0123456789{% endfor %}",
+		"output size", 1,
+	},
+	{"huge range", exec.Limits{MaxLoopIterations: 1000},
+		"{% for i in range(10**9) %}{{ i }}{% endfor %}",
+		"loop iterations", 1,
+	},
+	{"nested loops", exec.Limits{MaxLoopIterations: 1000},
+		"{% for i in items %}\n{% for j in items %}{{ i * j }}{% endfor %}\n{% endfor %}",
+		"loop iterations", 2,
+	},
+	{"recursive macro", exec.Limits{MaxRecursionDepth: 10},
+		"{% macro r(n) %}\n{{ r(n + 1) }}\n{% endmacro %}{{ r(0) }}",
+		"recursion depth", 1,
+	},
+	{"evaluation steps", exec.Limits{MaxEvalSteps: 100},
+		"{% for i in items %}{{ i + i * i }}{% endfor %}",
+		"evaluation steps", 1,
+	},
+}
+
+func TestLimitsExceeded(t *testing.T) {
+	items := make([]int, 40)
+	for _, tc := range limitsCases {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			env := gonja.NewEnvironment(config.NewConfig(), gonja.DefaultLoader)
+			env.Limits = &test.limits
+			tpl, err := env.FromString(test.source)
+			if !assert.Nil(t, err) {
+				return
+			}
+			_, err = tpl.Execute(map[string]interface{}{"items": items})
+			limitErr, ok := err.(*exec.LimitExceededError)
+			if !assert.True(t, ok, "expected a limit error, got %v", err) {
+				return
+			}
+			assert.Equal(t, test.limit, limitErr.Limit)
+			if assert.NotNil(t, limitErr.Token) {
+				assert.Equal(t, test.line, limitErr.Token.Line)
+			}
+		})
+	}
+}
+
+func TestLimitsIncludeRecursion(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "self.tpl"), []byte(`x{% include name %}`), 0644)
+	if !assert.Nil(t, err) {
+		return
+	}
+	env := gonja.NewEnvironment(config.NewConfig(), loaders.MustNewFileSystemLoader(dir))
+	env.Limits = &exec.Limits{MaxRecursionDepth: 5}
+	tpl, err := env.FromFile("self.tpl")
+	if !assert.Nil(t, err) {
+		return
+	}
+	_, err = tpl.Execute(map[string]interface{}{"name": "self.tpl"})
+	limitErr, ok := err.(*exec.LimitExceededError)
+	if assert.True(t, ok, "expected a limit error, got %v", err) {
+		assert.Equal(t, "recursion depth", limitErr.Limit)
+		assert.Equal(t, "self.tpl", limitErr.Template)
+	}
+}
+
+func TestLimitsNotExceeded(t *testing.T) {
+	env := gonja.NewEnvironment(config.NewConfig(), gonja.DefaultLoader)
+	env.Limits = &exec.Limits{
+		MaxOutputSize:     10,
+		MaxLoopIterations: 10,
+		MaxRecursionDepth: 2,
+		MaxEvalSteps:      100,
+	}
+	tpl, err := env.FromString(`{% macro m(i) %}{{ i }}{% endmacro %}{% for i in range(10) %}{{ m(i) }}{% endfor %}`)
+	if !assert.Nil(t, err) {
+		return
+	}
+	out, err := tpl.Execute(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "0123456789", out)
+}
+
+func TestLimitsRenderedIterations(t *testing.T) {
+	env := gonja.NewEnvironment(config.NewConfig(), gonja.DefaultLoader)
+	env.Limits = &exec.Limits{MaxLoopIterations: 10}
+	tpl, err := env.FromString(`{% for i in range(1000) %}{{ i }}{% if i == 2 %}{% break %}{% endif %}{% endfor %}`)
+	if !assert.Nil(t, err) {
+		return
+	}
+	out, err := tpl.Execute(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "012", out)
+
+	tpl, err = env.FromString("\n{% for i in range(1000) %}{{ loop.length }}{% break %}{% endfor %}")
+	if !assert.Nil(t, err) {
+		return
+	}
+	_, err = tpl.Execute(nil)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "loop.length is unknown")
+	}
+}
@@ -0,0 +1,71 @@
+package gonja_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/paradime-io/gonja"
+	"github.com/paradime-io/gonja/config"
+)
+
+const lineStatementsSource = `<ul>
+# for item in seq:
+    <li>{{ item }}</li>     ## this comment is ignored
+# endfor
+</ul>
+## a full line comment
+  # if seq|length > 1
+{{ seq|join }}
+  # endif
+# set items = [
+    1,
+    2,
+]
+{{ items|join(', ') }}`
+
+const lineStatementsExpected = `<ul>
+    <li>1</li>
+    <li>2</li>
+</ul>
+
+12
+1, 2`
+
+func TestLineStatements(t *testing.T) {
+	for _, trimBlocks := range []bool{false, true} {
+		cfg := config.NewConfig()
+		cfg.LineStatementPrefix = "#"
+		cfg.LineCommentPrefix = "##"
+		cfg.TrimBlocks = trimBlocks
+		env := gonja.NewEnvironment(cfg, gonja.DefaultLoader)
+
+		tpl, err := env.FromString(lineStatementsSource)
+		if !assert.Nil(t, err) {
+			return
+		}
+		out, err := tpl.Execute(map[string]interface{}{"seq": []int{1, 2}})
+		assert.Nil(t, err)
+		assert.Equal(t, lineStatementsExpected, out, "trim_blocks=%t", trimBlocks)
+	}
+}
+
+func TestLineStatementRaw(t *testing.T) {
+	cfg := config.NewConfig()
+	cfg.LineStatementPrefix = "#"
+	env := gonja.NewEnvironment(cfg, gonja.DefaultLoader)
+
+	for source, expected := range map[string]string{
+		"# raw\n{{ x }} # endraw here\n  # endraw\nafter {{ x }}": "{{ x }} # endraw here\nafter 1",
+		"{% raw %}{{ x }}\n# endraw\n{{ x }}":                     "{{ x }}\n1",
+		"# raw\n{{ x }}{% endraw %}{{ x }}":                       "{{ x }}1",
+	} {
+		tpl, err := env.FromString(source)
+		if !assert.Nil(t, err, source) {
+			continue
+		}
+		out, err := tpl.Execute(map[string]interface{}{"x": 1})
+		assert.Nil(t, err)
+		assert.Equal(t, expected, out, source)
+	}
+}
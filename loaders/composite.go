@@ -0,0 +1,189 @@
+package loaders
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ChoiceLoader tries several loaders in order, until one of them has the template.
+// Errors other than a missing template are returned immediately.
+type ChoiceLoader struct {
+	Loaders []Loader
+}
+
+// NewChoiceLoader creates a loader trying loaders in order
+func NewChoiceLoader(loaders ...Loader) *ChoiceLoader {
+	return &ChoiceLoader{Loaders: loaders}
+}
+
+// Get returns the template of the first loader having it
+func (c *ChoiceLoader) Get(path string) (io.Reader, error) {
+	for _, loader := range c.Loaders {
+		reader, err := loader.Get(path)
+		if err == nil {
+			return reader, nil
+		}
+		if !IsNotFound(err) {
+			return nil, err
+		}
+	}
+	return nil, &NotFoundError{Name: path}
+}
+
+// Version returns the version of the template prefixed by the index of
+// the loader having it, so a template added to a prior loader is noticed.
+func (c *ChoiceLoader) Version(path string) (string, error) {
+	for idx, loader := range c.Loaders {
+		var version string
+		var err error
+		if versioner, ok := loader.(Versioner); ok {
+			version, err = versioner.Version(path)
+		} else {
+			_, err = loader.Get(path)
+		}
+		if err == nil {
+			return fmt.Sprintf("%d:%s", idx, version), nil
+		}
+		if !IsNotFound(err) {
+			return "", err
+		}
+	}
+	return "", &NotFoundError{Name: path}
+}
+
+// GetSource returns the template of the first loader having it along with
+// its version, see Version.
+func (c *ChoiceLoader) GetSource(path string) (io.Reader, string, error) {
+	for idx, loader := range c.Loaders {
+		reader, version, err := GetSource(loader, path)
+		if err == nil {
+			return reader, fmt.Sprintf("%d:%s", idx, version), nil
+		}
+		if !IsNotFound(err) {
+			return nil, "", err
+		}
+	}
+	return nil, "", &NotFoundError{Name: path}
+}
+
+// ListTemplates returns the templates of all the loaders,
+// which must all be a Lister.
+func (c *ChoiceLoader) ListTemplates(filter func(name string) bool) ([]string, error) {
+	unique := map[string]bool{}
+	names := []string{}
+	for _, loader := range c.Loaders {
+		lister, ok := loader.(Lister)
+		if !ok {
+			return nil, errors.Errorf(`Unable to list the templates of %T`, loader)
+		}
+		subNames, err := lister.ListTemplates(filter)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range subNames {
+			if !unique[name] {
+				unique[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	return listed(names, nil), nil
+}
+
+// PrefixLoader routes the templates to a loader depending on their prefix,
+// ie. 'plugin/name.html' is loaded as 'name.html' by the 'plugin' loader.
+type PrefixLoader struct {
+	Loaders   map[string]Loader
+	Delimiter string
+}
+
+// NewPrefixLoader creates a loader routing the templates to loaders by prefix,
+// prefixes being delimited by a slash.
+func NewPrefixLoader(loaders map[string]Loader) *PrefixLoader {
+	return &PrefixLoader{Loaders: loaders, Delimiter: "/"}
+}
+
+// route returns the loader of path and the template name within this loader
+func (p *PrefixLoader) route(path string) (Loader, string, error) {
+	idx := strings.Index(path, p.Delimiter)
+	if idx < 0 {
+		return nil, "", &NotFoundError{Name: path}
+	}
+	loader, ok := p.Loaders[path[:idx]]
+	if !ok {
+		return nil, "", &NotFoundError{Name: path}
+	}
+	return loader, path[idx+len(p.Delimiter):], nil
+}
+
+// Get returns the template from the loader of its prefix
+func (p *PrefixLoader) Get(path string) (io.Reader, error) {
+	loader, name, err := p.route(path)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := loader.Get(name)
+	if err != nil && IsNotFound(err) {
+		return nil, &NotFoundError{Name: path}
+	}
+	return reader, err
+}
+
+// GetSource returns the template from the loader of its prefix along with its version
+func (p *PrefixLoader) GetSource(path string) (io.Reader, string, error) {
+	loader, name, err := p.route(path)
+	if err != nil {
+		return nil, "", err
+	}
+	reader, version, err := GetSource(loader, name)
+	if err != nil && IsNotFound(err) {
+		return nil, "", &NotFoundError{Name: path}
+	}
+	return reader, version, err
+}
+
+// Resolve resolves the relative names within the loader of the prefix of base,
+// see the Resolve function. Other names are returned unchanged.
+func (p *PrefixLoader) Resolve(base, name string) string {
+	loader, sub, err := p.route(base)
+	if err != nil || !isRelative(name) {
+		return resolveRelative(base, name)
+	}
+	return base[:len(base)-len(sub)] + Resolve(loader, sub, name)
+}
+
+// ListTemplates returns the prefixed templates of all the loaders,
+// which must all be a Lister.
+func (p *PrefixLoader) ListTemplates(filter func(name string) bool) ([]string, error) {
+	names := []string{}
+	for prefix, loader := range p.Loaders {
+		lister, ok := loader.(Lister)
+		if !ok {
+			return nil, errors.Errorf(`Unable to list the templates of the '%s' prefix`, prefix)
+		}
+		subNames, err := lister.ListTemplates(nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range subNames {
+			names = append(names, prefix+p.Delimiter+name)
+		}
+	}
+	return listed(names, filter), nil
+}
+
+// Version returns the version given by the loader of the template prefix.
+// Templates of loaders without versions never change.
+func (p *PrefixLoader) Version(path string) (string, error) {
+	loader, name, err := p.route(path)
+	if err != nil {
+		return "", err
+	}
+	if versioner, ok := loader.(Versioner); ok {
+		return versioner.Version(name)
+	}
+	return "", nil
+}
@@ -2,11 +2,14 @@ package loaders
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
 
 	"github.com/pkg/errors"
 )
@@ -75,13 +78,101 @@ func (fs *FilesystemLoader) Get(path string) (io.Reader, error) {
 	if err != nil {
 		return nil, err
 	}
+	return readFile(path, realPath)
+}
+
+// readFile reads the template name from realPath.
+// Missing files and directories are reported by a NotFoundError.
+func readFile(name, realPath string) (io.Reader, error) {
 	buf, err := ioutil.ReadFile(realPath)
 	if err != nil {
-		return nil, err
+		if fi, statErr := os.Stat(realPath); statErr == nil && fi.IsDir() {
+			return nil, &NotFoundError{Name: name}
+		}
+		return nil, notFound(name, err)
 	}
 	return bytes.NewReader(buf), nil
 }
 
+// Version returns the modification time and size of the file
+func (fs *FilesystemLoader) Version(path string) (string, error) {
+	realPath, err := fs.Path(path)
+	if err != nil {
+		return "", err
+	}
+	return fileVersion(path, realPath)
+}
+
+func fileVersion(name, realPath string) (string, error) {
+	fi, err := os.Stat(realPath)
+	if err != nil {
+		return "", notFound(name, err)
+	}
+	return fmt.Sprintf("%d-%d", fi.ModTime().UnixNano(), fi.Size()), nil
+}
+
+// GetSource reads the path's content along with its version, see Version
+func (fs *FilesystemLoader) GetSource(path string) (io.Reader, string, error) {
+	realPath, err := fs.Path(path)
+	if err != nil {
+		return nil, "", err
+	}
+	return readSource(path, realPath)
+}
+
+func readSource(name, realPath string) (io.Reader, string, error) {
+	// Versioned before reading so a concurrent change is noticed afterwards
+	version, err := fileVersion(name, realPath)
+	if err != nil {
+		return nil, "", err
+	}
+	reader, err := readFile(name, realPath)
+	if err != nil {
+		return nil, "", err
+	}
+	return reader, version, nil
+}
+
+// ListTemplates returns the files of the base directory,
+// or of the current working directory when there is none.
+func (fs *FilesystemLoader) ListTemplates(filter func(name string) bool) ([]string, error) {
+	root := fs.root
+	if root == "" {
+		var err error
+		if root, err = os.Getwd(); err != nil {
+			return nil, err
+		}
+	}
+	names, err := walkFiles(root)
+	if err != nil {
+		return nil, err
+	}
+	return listed(names, filter), nil
+}
+
+// walkFiles returns the slash separated paths of the files within root
+func walkFiles(root string) ([]string, error) {
+	names := []string{}
+	err := filepath.WalkDir(root, func(realPath string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, realPath)
+		if err != nil {
+			return err
+		}
+		names = append(names, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, `Unable to list the templates of '%s'`, root)
+	}
+	return names, nil
+}
+
 // Path resolves a filename relative to the base directory. Absolute paths are allowed.
 // When there's no base dir set, the absolute path to the filename
 // will be calculated based on either the provided base directory (which
@@ -121,52 +212,175 @@ func (fs *FilesystemLoader) Path(name string) (string, error) {
 	// return filepath.Join(fs.root, name)
 }
 
-// SandboxedFilesystemLoader is still WIP.
+// AccessDeniedError is returned when a template is requested
+// outside of what a SandboxedFilesystemLoader allows.
+type AccessDeniedError struct {
+	Name   string
+	Reason string
+}
+
+func (e *AccessDeniedError) Error() string {
+	return fmt.Sprintf("Access denied to template '%s': %s", e.Name, e.Reason)
+}
+
+// IsAccessDenied returns true if err has been caused by an AccessDeniedError
+func IsAccessDenied(err error) bool {
+	_, ok := errors.Cause(err).(*AccessDeniedError)
+	return ok
+}
+
+// SandboxedFilesystemLoader is a filesystem loader confined to its base directory.
+// Symlinks are resolved before checking the template stays within the root,
+// and allow/deny glob patterns (see path.Match) can further restrict the accessible templates.
+// Patterns are matched against the slash separated path relative to the root.
 type SandboxedFilesystemLoader struct {
 	*FilesystemLoader
+	allow []string
+	deny  []string
 }
 
 // NewSandboxedFilesystemLoader creates a new sandboxed local file system instance.
 func NewSandboxedFilesystemLoader(root string) (*SandboxedFilesystemLoader, error) {
-	fs, err := NewFileSystemLoader(root)
+	if root == "" {
+		return nil, errors.New("A sandboxed loader requires a base directory")
+	}
+	fs := &SandboxedFilesystemLoader{
+		FilesystemLoader: &FilesystemLoader{},
+	}
+	if err := fs.SetBaseDir(root); err != nil {
+		return nil, err
+	}
+	return fs, nil
+}
+
+// SetBaseDir sets the sandbox root directory, symlinks resolved.
+func (fs *SandboxedFilesystemLoader) SetBaseDir(path string) error {
+	if err := fs.FilesystemLoader.SetBaseDir(path); err != nil {
+		return err
+	}
+	root, err := filepath.EvalSymlinks(fs.root)
+	if err != nil {
+		return err
+	}
+	fs.root = root
+	return nil
+}
+
+// Allow restricts the accessible templates to the ones matching at least one of the patterns.
+func (fs *SandboxedFilesystemLoader) Allow(patterns ...string) error {
+	if err := checkPatterns(patterns); err != nil {
+		return err
+	}
+	fs.allow = append(fs.allow, patterns...)
+	return nil
+}
+
+// Deny forbids the access to the templates matching any of the patterns.
+// Deny patterns have priority over allow patterns.
+func (fs *SandboxedFilesystemLoader) Deny(patterns ...string) error {
+	if err := checkPatterns(patterns); err != nil {
+		return err
+	}
+	fs.deny = append(fs.deny, patterns...)
+	return nil
+}
+
+func checkPatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return errors.Wrapf(err, `Invalid pattern '%s'`, pattern)
+		}
+	}
+	return nil
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// Get reads the path's content from the sandbox.
+func (fs *SandboxedFilesystemLoader) Get(path string) (io.Reader, error) {
+	realPath, err := fs.Path(path)
+	if err != nil {
+		return nil, notFound(path, err)
+	}
+	return readFile(path, realPath)
+}
+
+// Version returns the modification time and size of a file of the sandbox
+func (fs *SandboxedFilesystemLoader) Version(path string) (string, error) {
+	realPath, err := fs.Path(path)
+	if err != nil {
+		return "", notFound(path, err)
+	}
+	return fileVersion(path, realPath)
+}
+
+// GetSource reads the path's content from the sandbox along with its version
+func (fs *SandboxedFilesystemLoader) GetSource(path string) (io.Reader, string, error) {
+	realPath, err := fs.Path(path)
+	if err != nil {
+		return nil, "", notFound(path, err)
+	}
+	return readSource(path, realPath)
+}
+
+// ListTemplates returns the files of the sandbox it allows to access
+func (fs *SandboxedFilesystemLoader) ListTemplates(filter func(name string) bool) ([]string, error) {
+	names, err := walkFiles(fs.root)
 	if err != nil {
 		return nil, err
 	}
-	return &SandboxedFilesystemLoader{
-		FilesystemLoader: fs,
-	}, nil
-}
-
-// Move sandbox to a virtual fs
-
-/*
-if len(set.SandboxDirectories) > 0 {
-    defer func() {
-        // Remove any ".." or other crap
-        resolvedPath = filepath.Clean(resolvedPath)
-
-        // Make the path absolute
-        absPath, err := filepath.Abs(resolvedPath)
-        if err != nil {
-            panic(err)
-        }
-        resolvedPath = absPath
-
-        // Check against the sandbox directories (once one pattern matches, we're done and can allow it)
-        for _, pattern := range set.SandboxDirectories {
-            matched, err := filepath.Match(pattern, resolvedPath)
-            if err != nil {
-                panic("Wrong sandbox directory match pattern (see http://golang.org/pkg/path/filepath/#Match).")
-            }
-            if matched {
-                // OK!
-                return
-            }
-        }
-
-        // No pattern matched, we have to log+deny the request
-        set.logf("Access attempt outside of the sandbox directories (blocked): '%s'", resolvedPath)
-        resolvedPath = ""
-    }()
-}
-*/
+	allowed := []string{}
+	for _, name := range names {
+		if _, err := fs.Path(name); err == nil {
+			allowed = append(allowed, name)
+		}
+	}
+	return listed(allowed, filter), nil
+}
+
+// Path resolves a filename within the sandbox. Absolute paths are only
+// allowed when they point inside the base directory.
+// An *AccessDeniedError is returned for any template outside of the sandbox.
+func (fs *SandboxedFilesystemLoader) Path(name string) (string, error) {
+	joined := name
+	if !filepath.IsAbs(name) {
+		joined = filepath.Join(fs.root, name)
+	}
+	resolved, err := filepath.EvalSymlinks(filepath.Clean(joined))
+	if err != nil {
+		if os.IsNotExist(err) {
+			// Prevent probing for files existence outside of the sandbox
+			if _, relErr := fs.relative(name, filepath.Clean(joined)); relErr != nil {
+				return "", relErr
+			}
+		}
+		return "", err
+	}
+	rel, err := fs.relative(name, resolved)
+	if err != nil {
+		return "", err
+	}
+	if matchAny(fs.deny, rel) {
+		return "", &AccessDeniedError{Name: name, Reason: "denied by the sandbox"}
+	}
+	if len(fs.allow) > 0 && !matchAny(fs.allow, rel) {
+		return "", &AccessDeniedError{Name: name, Reason: "not allowed by the sandbox"}
+	}
+	return resolved, nil
+}
+
+// relative returns the slash separated path of resolved relative to the root
+func (fs *SandboxedFilesystemLoader) relative(name, resolved string) (string, error) {
+	rel, err := filepath.Rel(fs.root, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", &AccessDeniedError{Name: name, Reason: "outside of the sandbox root"}
+	}
+	return filepath.ToSlash(rel), nil
+}
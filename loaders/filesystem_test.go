@@ -0,0 +1,125 @@
+package loaders_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/paradime-io/gonja"
+	"github.com/paradime-io/gonja/config"
+	"github.com/paradime-io/gonja/loaders"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func newSandbox(t *testing.T) (*loaders.SandboxedFilesystemLoader, string) {
+	base := t.TempDir()
+	root := filepath.Join(base, "root")
+	writeFiles(t, base, map[string]string{
+		"secret.txt":             "secret",
+		"root/index.tpl":         "index",
+		"root/partials/item.tpl": "item",
+		"root/private/key.tpl":   "key",
+		"root/notes.txt":         "notes",
+	})
+	if err := os.Symlink(filepath.Join(base, "secret.txt"), filepath.Join(root, "link.tpl")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(root, "index.tpl"), filepath.Join(root, "alias.tpl")); err != nil {
+		t.Fatal(err)
+	}
+	fs, err := loaders.NewSandboxedFilesystemLoader(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fs, base
+}
+
+var sandboxCases = []struct {
+	name    string
+	allow   []string
+	deny    []string
+	path    string
+	content string
+	denied  bool
+}{
+	{"relative", nil, nil, "index.tpl", "index", false},
+	{"subdirectory", nil, nil, "partials/item.tpl", "item", false},
+	{"cleaned", nil, nil, "partials/../index.tpl", "index", false},
+	{"parent", nil, nil, "../secret.txt", "", true},
+	{"nested parent", nil, nil, "partials/../../secret.txt", "", true},
+	{"missing parent", nil, nil, "../missing.txt", "", true},
+	{"symlink escape", nil, nil, "link.tpl", "", true},
+	{"symlink inside", nil, nil, "alias.tpl", "index", false},
+	{"allowed", []string{"*.tpl", "partials/*"}, nil, "partials/item.tpl", "item", false},
+	{"not allowed", []string{"*.tpl", "partials/*"}, nil, "notes.txt", "", true},
+	{"denied", nil, []string{"private/*"}, "private/key.tpl", "", true},
+	{"deny wins", []string{"*/*.tpl"}, []string{"private/*"}, "private/key.tpl", "", true},
+}
+
+func TestSandboxedFilesystemLoader(t *testing.T) {
+	for _, tc := range sandboxCases {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			fs, _ := newSandbox(t)
+			assert.Nil(t, fs.Allow(test.allow...))
+			assert.Nil(t, fs.Deny(test.deny...))
+
+			reader, err := fs.Get(test.path)
+			if test.denied {
+				assert.True(t, loaders.IsAccessDenied(err), "expected access denied, got %v", err)
+				return
+			}
+			if !assert.Nil(t, err) {
+				return
+			}
+			content, _ := ioutil.ReadAll(reader)
+			assert.Equal(t, test.content, string(content))
+		})
+	}
+}
+
+func TestSandboxedFilesystemLoaderAbsolute(t *testing.T) {
+	fs, base := newSandbox(t)
+	_, err := fs.Get(filepath.Join(base, "secret.txt"))
+	assert.True(t, loaders.IsAccessDenied(err), "expected access denied, got %v", err)
+
+	_, err = fs.Get(filepath.Join(base, "root", "index.tpl"))
+	assert.Nil(t, err)
+
+	assert.NotNil(t, fs.Allow("[invalid"))
+}
+
+func TestSandboxedFilesystemLoaderStatements(t *testing.T) {
+	fs, _ := newSandbox(t)
+	env := gonja.NewEnvironment(config.NewConfig(), fs)
+	for _, source := range []string{
+		`{% include "../secret.txt" %}`,
+		`{% include "../secret.txt" ignore missing %}`,
+		`{% include name ignore missing %}`,
+		`{% import name as m %}`,
+		`{% extends "../secret.txt" %}`,
+	} {
+		tpl, err := env.FromString(source)
+		if err == nil {
+			_, err = tpl.Execute(map[string]interface{}{"name": "../secret.txt"})
+		}
+		if assert.NotNil(t, err, source) {
+			assert.True(t, loaders.IsAccessDenied(err), "%s: %v", source, err)
+			assert.Contains(t, err.Error(), "../secret.txt", source)
+		}
+	}
+}
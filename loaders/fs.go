@@ -0,0 +1,99 @@
+package loaders
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// FSLoader loads templates from a fs.FS, ie. an embed.FS:
+//
+//	//go:embed templates
+//	var templates embed.FS
+//
+//	loader := loaders.NewFSLoader(templates)
+//	tpl, err := env.FromFile("templates/index.html")
+type FSLoader struct {
+	fsys fs.FS
+}
+
+// NewFSLoader creates a loader reading the templates from fsys
+func NewFSLoader(fsys fs.FS) *FSLoader {
+	return &FSLoader{fsys: fsys}
+}
+
+// name converts a template path to a valid fs.FS name.
+// Templates can't be loaded outside of the file system.
+func (l *FSLoader) name(tpl string) (string, error) {
+	name := path.Clean("/" + strings.ReplaceAll(tpl, "\\", "/"))[1:]
+	if name == "" || !fs.ValidPath(name) {
+		return "", &NotFoundError{Name: tpl}
+	}
+	return name, nil
+}
+
+// Get reads the content of path from the file system
+func (l *FSLoader) Get(path string) (io.Reader, error) {
+	name, err := l.name(path)
+	if err != nil {
+		return nil, err
+	}
+	buf, err := fs.ReadFile(l.fsys, name)
+	if err != nil {
+		if fi, statErr := fs.Stat(l.fsys, name); statErr == nil && fi.IsDir() {
+			return nil, &NotFoundError{Name: path}
+		}
+		return nil, notFound(path, err)
+	}
+	return bytes.NewReader(buf), nil
+}
+
+// Version returns the modification time and size of the file.
+// The files of an embed.FS never change.
+func (l *FSLoader) Version(path string) (string, error) {
+	name, err := l.name(path)
+	if err != nil {
+		return "", err
+	}
+	fi, err := fs.Stat(l.fsys, name)
+	if err != nil {
+		return "", notFound(path, err)
+	}
+	return fmt.Sprintf("%d-%d", fi.ModTime().UnixNano(), fi.Size()), nil
+}
+
+// GetSource reads the content of path along with its version
+func (l *FSLoader) GetSource(path string) (io.Reader, string, error) {
+	version, err := l.Version(path)
+	if err != nil {
+		return nil, "", err
+	}
+	reader, err := l.Get(path)
+	if err != nil {
+		return nil, "", err
+	}
+	return reader, version, nil
+}
+
+// ListTemplates returns the files of the file system
+func (l *FSLoader) ListTemplates(filter func(name string) bool) ([]string, error) {
+	names := []string{}
+	err := fs.WalkDir(l.fsys, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() {
+			names = append(names, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, `Unable to list the templates`)
+	}
+	return listed(names, filter), nil
+}
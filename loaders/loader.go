@@ -1,15 +1,147 @@
 package loaders
 
 import (
+	"fmt"
 	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
 )
 
 // TemplateLoader allows to implement a virtual file system.
+// Loaders can provide more features by implementing the optional
+// Resolver, Lister, Versioner and SourceLoader interfaces.
 type Loader interface {
-	// Abs calculates the path to a given template. Whenever a path must be resolved
-	// due to an import from another template, the base equals the parent template's path.
-	// Abs(base, name string) string
-
 	// Get returns an io.Reader where the template's content can be read from.
 	Get(path string) (io.Reader, error)
 }
+
+// Resolver is implemented by loaders resolving the template names
+// referenced by another template themselves, see Resolve.
+type Resolver interface {
+	// Resolve returns the name of the template name referenced by the template base
+	Resolve(base, name string) string
+}
+
+// Lister is implemented by loaders able to enumerate their templates
+type Lister interface {
+	// ListTemplates returns the sorted names of the templates accepted by filter,
+	// all of them if filter is nil.
+	ListTemplates(filter func(name string) bool) ([]string, error)
+}
+
+// SourceLoader is implemented by loaders able to return the content
+// of a template along with its version (see Versioner) at once.
+type SourceLoader interface {
+	GetSource(path string) (io.Reader, string, error)
+}
+
+// Versioner is implemented by loaders able to tell when a template changed.
+// Environments use it to invalidate their cached templates.
+type Versioner interface {
+	// Version returns an opaque value changing with the content of path,
+	// ie. its modification time.
+	Version(path string) (string, error)
+}
+
+// Resolve returns the name of the template name referenced by the template base,
+// ie. by an include. Unless loader is a Resolver, names starting with './' or '../'
+// are relative to the directory of base and others are returned unchanged.
+func Resolve(loader Loader, base, name string) string {
+	if resolver, ok := loader.(Resolver); ok {
+		return resolver.Resolve(base, name)
+	}
+	return resolveRelative(base, name)
+}
+
+func isRelative(name string) bool {
+	return strings.HasPrefix(name, "./") || strings.HasPrefix(name, "../")
+}
+
+func resolveRelative(base, name string) string {
+	if !isRelative(name) {
+		return name
+	}
+	return path.Join(path.Dir(base), name)
+}
+
+// GetSource returns the content of path and its version.
+// The version is empty when loader is neither a SourceLoader nor a Versioner.
+func GetSource(loader Loader, path string) (io.Reader, string, error) {
+	if sourceLoader, ok := loader.(SourceLoader); ok {
+		return sourceLoader.GetSource(path)
+	}
+	version := ""
+	if versioner, ok := loader.(Versioner); ok {
+		var err error
+		if version, err = versioner.Version(path); err != nil {
+			return nil, "", err
+		}
+	}
+	reader, err := loader.Get(path)
+	if err != nil {
+		return nil, "", err
+	}
+	return reader, version, nil
+}
+
+// WithExtensions returns a ListTemplates filter accepting the templates
+// ending with one of the extensions, ie. ".html".
+func WithExtensions(extensions ...string) func(name string) bool {
+	return func(name string) bool {
+		for _, ext := range extensions {
+			if strings.HasSuffix(name, ext) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// listed sorts names, keeping the ones accepted by filter
+func listed(names []string, filter func(name string) bool) []string {
+	out := []string{}
+	for _, name := range names {
+		if filter == nil || filter(name) {
+			out = append(out, name)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// NotFoundError is returned by the loaders when a template does not exist
+type NotFoundError struct {
+	Name string
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("Template '%s' not found", e.Name)
+}
+
+// Is makes NotFoundError match fs.ErrNotExist
+func (e *NotFoundError) Is(target error) bool {
+	return target == fs.ErrNotExist
+}
+
+// IsNotFound returns true if err has been caused by a missing template,
+// either reported by a NotFoundError or a not exist error of the os package.
+func IsNotFound(err error) bool {
+	cause := errors.Cause(err)
+	if _, ok := cause.(*NotFoundError); ok {
+		return true
+	}
+	return os.IsNotExist(cause)
+}
+
+// notFound converts the not exist errors of the os package to a NotFoundError
+func notFound(name string, err error) error {
+	if os.IsNotExist(err) {
+		return &NotFoundError{Name: name}
+	}
+	return err
+}
@@ -0,0 +1,301 @@
+package loaders_test
+
+import (
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/paradime-io/gonja"
+	"github.com/paradime-io/gonja/config"
+	"github.com/paradime-io/gonja/loaders"
+)
+
+func read(t *testing.T, loader loaders.Loader, name string) string {
+	reader, err := loader.Get(name)
+	if !assert.Nil(t, err, name) {
+		return ""
+	}
+	buf, err := ioutil.ReadAll(reader)
+	assert.Nil(t, err)
+	return string(buf)
+}
+
+func assertNotFound(t *testing.T, loader loaders.Loader, name string) {
+	_, err := loader.Get(name)
+	if assert.NotNil(t, err, name) {
+		assert.True(t, loaders.IsNotFound(err), "%s: %v", name, err)
+		assert.Contains(t, err.Error(), name)
+	}
+}
+
+func TestMapLoader(t *testing.T) {
+	loader := loaders.MapLoader{"index.html": "index"}
+	assert.Equal(t, "index", read(t, loader, "index.html"))
+	assertNotFound(t, loader, "missing.html")
+}
+
+func TestFunctionLoader(t *testing.T) {
+	loader := loaders.FunctionLoader(func(path string) (io.Reader, error) {
+		switch path {
+		case "index.html":
+			return strings.NewReader("index"), nil
+		case "broken.html":
+			return nil, errors.New("broken")
+		case "removed.html":
+			return nil, fs.ErrNotExist
+		}
+		return nil, nil
+	})
+	assert.Equal(t, "index", read(t, loader, "index.html"))
+	assertNotFound(t, loader, "missing.html")
+	assertNotFound(t, loader, "removed.html")
+
+	_, err := loader.Get("broken.html")
+	if assert.NotNil(t, err) {
+		assert.False(t, loaders.IsNotFound(err))
+	}
+}
+
+func TestFSLoader(t *testing.T) {
+	loader := loaders.NewFSLoader(fstest.MapFS{
+		"index.html":         {Data: []byte("index")},
+		"partials/item.html": {Data: []byte("item")},
+	})
+	assert.Equal(t, "index", read(t, loader, "index.html"))
+	assert.Equal(t, "item", read(t, loader, "partials/item.html"))
+	assert.Equal(t, "item", read(t, loader, "/partials/../partials/item.html"))
+	assertNotFound(t, loader, "missing.html")
+	assertNotFound(t, loader, "partials")
+	assertNotFound(t, loader, "")
+
+	version, err := loader.Version("index.html")
+	assert.Nil(t, err)
+	assert.NotEqual(t, "", version)
+	_, err = loader.Version("missing.html")
+	assert.True(t, loaders.IsNotFound(err))
+}
+
+func TestFilesystemLoaderNotFound(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"partials/item.html": "item"})
+	loader := loaders.MustNewFileSystemLoader(dir)
+	assertNotFound(t, loader, "missing.html")
+	assertNotFound(t, loader, "partials")
+}
+
+func TestChoiceLoader(t *testing.T) {
+	first := loaders.MapLoader{"index.html": "first"}
+	second := loaders.MapLoader{"index.html": "second", "other.html": "other"}
+	loader := loaders.NewChoiceLoader(first, second)
+	assert.Equal(t, "first", read(t, loader, "index.html"))
+	assert.Equal(t, "other", read(t, loader, "other.html"))
+	assertNotFound(t, loader, "missing.html")
+
+	version, err := loader.Version("other.html")
+	assert.Nil(t, err)
+	first["other.html"] = "overridden"
+	updated, err := loader.Version("other.html")
+	assert.Nil(t, err)
+	assert.NotEqual(t, version, updated)
+
+	// Errors other than missing templates are not skipped
+	sandbox, _ := newSandbox(t)
+	loader = loaders.NewChoiceLoader(sandbox, second)
+	_, err = loader.Get("../secret.txt")
+	assert.True(t, loaders.IsAccessDenied(err), "got %v", err)
+}
+
+func TestPrefixLoader(t *testing.T) {
+	loader := loaders.NewPrefixLoader(map[string]loaders.Loader{
+		"app":    loaders.MapLoader{"index.html": "app"},
+		"plugin": loaders.MapLoader{"index.html": "plugin", "sub/item.html": "item"},
+	})
+	assert.Equal(t, "app", read(t, loader, "app/index.html"))
+	assert.Equal(t, "plugin", read(t, loader, "plugin/index.html"))
+	assert.Equal(t, "item", read(t, loader, "plugin/sub/item.html"))
+	assertNotFound(t, loader, "plugin/missing.html")
+	assertNotFound(t, loader, "unknown/index.html")
+	assertNotFound(t, loader, "index.html")
+
+	loader.Delimiter = ":"
+	loader.Loaders["app"] = loaders.MapLoader{"index.html": "app"}
+	assert.Equal(t, "app", read(t, loader, "app:index.html"))
+}
+
+func TestIgnoreMissing(t *testing.T) {
+	templates := loaders.MapLoader{
+		"broken.html":  "{% if %}",
+		"partial.html": "partial",
+	}
+	fsys := fstest.MapFS{"partial.html": {Data: []byte("fs partial")}}
+	for name, loader := range map[string]loaders.Loader{
+		"map":    templates,
+		"choice": loaders.NewChoiceLoader(loaders.NewFSLoader(fsys), templates),
+		"prefix": loaders.NewPrefixLoader(map[string]loaders.Loader{"fs": loaders.NewFSLoader(fsys)}),
+		"function": loaders.FunctionLoader(func(path string) (io.Reader, error) {
+			return templates.Get(path)
+		}),
+	} {
+		env := gonja.NewEnvironment(config.NewConfig(), loader)
+		tpl, err := env.FromString(`[{% include "missing.html" ignore missing %}{% include name ignore missing %}]`)
+		if !assert.Nil(t, err, name) {
+			continue
+		}
+		out, err := tpl.Execute(map[string]interface{}{"name": "other.html"})
+		if assert.Nil(t, err, name) {
+			assert.Equal(t, "[]", out, name)
+		}
+	}
+
+	env := gonja.NewEnvironment(config.NewConfig(), templates)
+	_, err := env.FromString(`{% include "broken.html" ignore missing %}`)
+	assert.NotNil(t, err, "parse errors are not ignored")
+	tpl, err := env.FromString(`{% extends ["missing.html", "partial.html"] %}`)
+	if assert.Nil(t, err) {
+		out, err := tpl.Execute(nil)
+		assert.Nil(t, err)
+		assert.Equal(t, "partial", out)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	mapLoader := loaders.MapLoader{}
+	for _, test := range []struct{ base, name, expected string }{
+		{"pages/index.html", "./partial.html", "pages/partial.html"},
+		{"pages/index.html", "../base.html", "base.html"},
+		{"pages/sub/index.html", "../partial.html", "pages/partial.html"},
+		{"pages/index.html", "base.html", "base.html"},
+		{"string", "./partial.html", "partial.html"},
+	} {
+		assert.Equal(t, test.expected, loaders.Resolve(mapLoader, test.base, test.name), "%s from %s", test.name, test.base)
+	}
+
+	prefix := loaders.NewPrefixLoader(map[string]loaders.Loader{"plugin": mapLoader})
+	assert.Equal(t, "plugin/sub/item.html", loaders.Resolve(prefix, "plugin/sub/index.html", "./item.html"))
+	assert.Equal(t, "plugin/item.html", loaders.Resolve(prefix, "plugin/sub/index.html", "../item.html"))
+	assert.Equal(t, "other/item.html", loaders.Resolve(prefix, "plugin/index.html", "other/item.html"))
+}
+
+func TestListTemplates(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"index.html":         "",
+		"partials/item.html": "",
+		"style.css":          "",
+	})
+	fsys := fstest.MapFS{
+		"index.html":    {Data: []byte("")},
+		"sub/page.html": {Data: []byte("")},
+	}
+	mapLoader := loaders.MapLoader{"b.html": "", "a.html": "", "c.txt": ""}
+
+	for name, test := range map[string]struct {
+		loader   loaders.Lister
+		expected []string
+	}{
+		"filesystem": {loaders.MustNewFileSystemLoader(dir), []string{"index.html", "partials/item.html", "style.css"}},
+		"map":        {mapLoader, []string{"a.html", "b.html", "c.txt"}},
+		"fs":         {loaders.NewFSLoader(fsys), []string{"index.html", "sub/page.html"}},
+		"choice": {
+			loaders.NewChoiceLoader(mapLoader, loaders.NewFSLoader(fsys)),
+			[]string{"a.html", "b.html", "c.txt", "index.html", "sub/page.html"},
+		},
+		"prefix": {
+			loaders.NewPrefixLoader(map[string]loaders.Loader{"m": mapLoader, "fs": loaders.NewFSLoader(fsys)}),
+			[]string{"fs/index.html", "fs/sub/page.html", "m/a.html", "m/b.html", "m/c.txt"},
+		},
+	} {
+		names, err := test.loader.ListTemplates(nil)
+		if assert.Nil(t, err, name) {
+			assert.Equal(t, test.expected, names, name)
+		}
+	}
+
+	names, err := mapLoader.ListTemplates(loaders.WithExtensions(".html"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a.html", "b.html"}, names)
+
+	sandbox, _ := newSandbox(t)
+	assert.Nil(t, sandbox.Deny("private/*"))
+	names, err = sandbox.ListTemplates(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"alias.tpl", "index.tpl", "notes.txt", "partials/item.tpl"}, names)
+
+	env := gonja.NewEnvironment(config.NewConfig(), loaders.FunctionLoader(nil))
+	_, err = env.ListTemplates(nil)
+	assert.NotNil(t, err)
+	choice := loaders.NewChoiceLoader(mapLoader, loaders.FunctionLoader(nil))
+	_, err = choice.ListTemplates(nil)
+	assert.NotNil(t, err)
+}
+
+func TestGetSource(t *testing.T) {
+	mapLoader := loaders.MapLoader{"index.html": "index"}
+	_, version, err := loaders.GetSource(mapLoader, "index.html")
+	assert.Nil(t, err)
+	mapLoader["index.html"] = "updated"
+	reader, updated, err := loaders.GetSource(mapLoader, "index.html")
+	if assert.Nil(t, err) {
+		assert.NotEqual(t, version, updated)
+		buf, _ := ioutil.ReadAll(reader)
+		assert.Equal(t, "updated", string(buf))
+	}
+	_, _, err = loaders.GetSource(mapLoader, "missing.html")
+	assert.True(t, loaders.IsNotFound(err))
+
+	function := loaders.FunctionLoader(func(path string) (io.Reader, error) {
+		return strings.NewReader(path), nil
+	})
+	_, version, err = loaders.GetSource(function, "index.html")
+	assert.Nil(t, err)
+	assert.Equal(t, "", version)
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"index.html": "index"})
+	for _, loader := range []loaders.SourceLoader{
+		loaders.MustNewFileSystemLoader(dir),
+		loaders.NewChoiceLoader(loaders.MustNewFileSystemLoader(dir)),
+		loaders.NewPrefixLoader(map[string]loaders.Loader{"": loaders.MustNewFileSystemLoader(dir)}),
+	} {
+		name := "index.html"
+		if _, ok := loader.(*loaders.PrefixLoader); ok {
+			name = "/index.html"
+		}
+		_, version, err := loader.GetSource(name)
+		if assert.Nil(t, err) {
+			expected, _ := loader.(loaders.Versioner).Version(name)
+			assert.Equal(t, expected, version)
+		}
+		_, _, err = loader.GetSource("missing.html")
+		assert.True(t, loaders.IsNotFound(err), "%T: %v", loader, err)
+	}
+}
+
+func TestRelativeTemplates(t *testing.T) {
+	env := gonja.NewEnvironment(config.NewConfig(), loaders.MapLoader{
+		"base.html":           "<{% block content %}{% endblock %}>",
+		"pages/index.html":    `{% extends "../base.html" %}{% block content %}{% include "./partial.html" %}{% endblock %}`,
+		"pages/partial.html":  `{% from "./macros/m.html" import m %}{{ m() }}{% include name %}`,
+		"pages/macros/m.html": `{% macro m() %}m{% endmacro %}`,
+		"pages/dynamic.html":  "dynamic",
+		"pages/child.html":    `{% extends parent %}{% block content %}child{% endblock %}`,
+	})
+	tpl, err := env.FromCache("pages/index.html")
+	if assert.Nil(t, err) {
+		out, err := tpl.Execute(map[string]interface{}{"name": "./dynamic.html"})
+		assert.Nil(t, err)
+		assert.Equal(t, "<mdynamic>", out)
+	}
+	tpl, err = env.FromCache("pages/child.html")
+	if assert.Nil(t, err) {
+		out, err := tpl.Execute(map[string]interface{}{"parent": "../base.html"})
+		assert.Nil(t, err)
+		assert.Equal(t, "<child>", out)
+	}
+}
@@ -0,0 +1,66 @@
+package loaders
+
+import (
+	"hash/fnv"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// MapLoader loads templates from a map of sources by name,
+// ie. for tests or templates generated at runtime.
+type MapLoader map[string]string
+
+// Get returns the source of path
+func (m MapLoader) Get(path string) (io.Reader, error) {
+	source, ok := m[path]
+	if !ok {
+		return nil, &NotFoundError{Name: path}
+	}
+	return strings.NewReader(source), nil
+}
+
+// Version returns a hash of the source of path
+func (m MapLoader) Version(path string) (string, error) {
+	source, ok := m[path]
+	if !ok {
+		return "", &NotFoundError{Name: path}
+	}
+	hash := fnv.New64a()
+	hash.Write([]byte(source))
+	return strconv.FormatUint(hash.Sum64(), 16), nil
+}
+
+// GetSource returns the source of path along with its version
+func (m MapLoader) GetSource(path string) (io.Reader, string, error) {
+	version, err := m.Version(path)
+	if err != nil {
+		return nil, "", err
+	}
+	return strings.NewReader(m[path]), version, nil
+}
+
+// ListTemplates returns the names of the map
+func (m MapLoader) ListTemplates(filter func(name string) bool) ([]string, error) {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	return listed(names, filter), nil
+}
+
+// FunctionLoader loads templates by calling a function.
+// The function returns a nil reader when the template does not exist.
+type FunctionLoader func(path string) (io.Reader, error)
+
+// Get returns the reader given by the function
+func (f FunctionLoader) Get(path string) (io.Reader, error) {
+	reader, err := f(path)
+	if err != nil {
+		return nil, notFound(path, err)
+	}
+	if reader == nil {
+		return nil, &NotFoundError{Name: path}
+	}
+	return reader, nil
+}
@@ -0,0 +1,52 @@
+package gonja_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/paradime-io/gonja"
+)
+
+var loopControlsCases = []struct {
+	name     string
+	source   string
+	expected string
+}{
+	{"break", `{% for i in range(10) %}{% if i == 3 %}{% break %}{% endif %}{{ i }}{% endfor %}`, "012"},
+	{"continue", `{% for i in range(6) %}{% if i is odd %}{% continue %}{% endif %}{{ i }}{% endfor %}`, "024"},
+	{"loop object", `{% for i in range(5) %}{% if loop.index == 3 %}{% break %}{% endif %}{{ loop.revindex }}{{ loop.last }} {% endfor %}`, "5False 4False "},
+	{"else is not rendered after break", `{% for i in items %}{{ i }}{% break %}{% else %}empty{% endfor %}`, "1"},
+	{"else of an empty loop", `{% for i in [] %}{% break %}{% else %}empty{% endfor %}`, "empty"},
+	{"nested loops", `{% for i in range(3) %}{% for j in range(3) %}{% if j > i %}{% break %}{% endif %}{{ i }}{{ j }} {% endfor %}{% if i == 1 %}{% break %}{% endif %}{% endfor %}`, "00 10 11 "},
+	{"inside a with block", `{% for i in items %}{% with x = i %}{% if x == 2 %}{% continue %}{% endif %}{{ x }}{% endwith %}{% endfor %}`, "13"},
+	{"whitespace control", "{% for i in items %}\n  {%- if i == 2 -%}\n    {%- continue -%}\n  {%- endif %}{{ i }}\n{%- endfor %}", "13"},
+}
+
+func TestLoopControls(t *testing.T) {
+	data := map[string]interface{}{"items": []int{1, 2, 3}}
+	for _, tc := range loopControlsCases {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			tpl, err := gonja.FromString(test.source)
+			if !assert.Nil(t, err) {
+				return
+			}
+			out, err := tpl.Execute(data)
+			assert.Nil(t, err)
+			assert.Equal(t, test.expected, out)
+		})
+	}
+}
+
+func TestLoopControlsOutsideLoop(t *testing.T) {
+	for _, source := range []string{
+		`{% break %}`,
+		`{% for i in items %}{% else %}{% continue %}{% endfor %}`,
+		`{% for i in items %}{% macro m() %}{% break %}{% endmacro %}{% endfor %}`,
+		`{% for i in items %}{% break i %}{% endfor %}`,
+	} {
+		_, err := gonja.FromString(source)
+		assert.NotNil(t, err, source)
+	}
+}
@@ -0,0 +1,105 @@
+package gonja_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/paradime-io/gonja"
+)
+
+var loopCases = []struct {
+	name     string
+	source   string
+	expected string
+}{
+	{"indexes",
+		`{% for i in items %}{{ loop.index }}{{ loop.index0 }}{{ loop.revindex }}{{ loop.revindex0 }} {% endfor %}`,
+		"1032 2121 3210 "},
+	{"first last length",
+		`{% for i in items %}{{ loop.first }}/{{ loop.last }}/{{ loop.length }} {% endfor %}`,
+		"True/False/3 False/False/3 False/True/3 "},
+	{"depth",
+		`{% for i in items %}{{ loop.depth }}{{ loop.depth0 }}{% endfor %}`,
+		"101010"},
+	{"previtem nextitem",
+		`{% for i in items %}{{ loop.previtem|default('^') }}{{ i }}{{ loop.nextitem|default('$') }} {% endfor %}`,
+		"^ab abc bc$ "},
+	{"previtem is undefined on the first iteration",
+		`{% for i in items %}{{ loop.previtem is defined }}{{ loop.nextitem is defined }} {% endfor %}`,
+		"FalseTrue TrueTrue TrueFalse "},
+	{"cycle",
+		`{% for i in items %}{{ loop.cycle('odd', 'even') }} {% endfor %}`,
+		"odd even odd "},
+	{"changed",
+		`{% for n in [1, 1, 2, 2, 1] %}{% if loop.changed(n) %}{{ n }}{% endif %}{% endfor %}`,
+		"121"},
+	{"length with filter",
+		`{% for n in range(10) if n is even %}{{ loop.length }}{% endfor %}`,
+		"55555"},
+	{"else when every item is filtered out",
+		`{% for i in items if i == 'z' %}{{ i }}{% else %}none{% endfor %}`,
+		"none"},
+	{"recursive",
+		`{% for node in tree recursive %}{{ node.name }}{% if node.children %}({{ loop(node.children) }}){% endif %}{% endfor %}`,
+		"a(b(c)d)e"},
+	{"recursive depth",
+		`{% for node in tree recursive %}{{ node.name }}{{ loop.depth }}{{ loop.depth0 }}{{ loop(node.children) }}{% endfor %}`,
+		"a10b21c32d21e10"},
+	{"recursive with filter",
+		`{% for node in tree if node.name != 'c' recursive %}{{ node.name }}{{ loop(node.children) }}{% endfor %}`,
+		"abde"},
+	{"recursive keeps the outer loop",
+		`{% for node in tree recursive %}{{ loop.index }}{{ loop(node.children) }}{{ loop.index }};{% endfor %}`,
+		"1111;1;22;1;22;"},
+	{"internals are undefined",
+		`{% for i in items recursive %}{{ loop.recurse is defined }}{{ loop.lastchanged is defined }} {% endfor %}`,
+		"FalseFalse FalseFalse FalseFalse "},
+}
+
+func TestLoop(t *testing.T) {
+	data := map[string]interface{}{
+		"items": []string{"a", "b", "c"},
+		"tree": []map[string]interface{}{
+			{"name": "a", "children": []map[string]interface{}{
+				{"name": "b", "children": []map[string]interface{}{
+					{"name": "c"},
+				}},
+				{"name": "d"},
+			}},
+			{"name": "e"},
+		},
+	}
+	for _, tc := range loopCases {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			tpl, err := gonja.FromString(test.source)
+			if !assert.Nil(t, err) {
+				return
+			}
+			out, err := tpl.Execute(data)
+			assert.Nil(t, err)
+			assert.Equal(t, test.expected, out)
+		})
+	}
+}
+
+func TestLoopNotRecursive(t *testing.T) {
+	tpl, err := gonja.FromString(`{% for i in items %}{{ loop(items) }}{% endfor %}`)
+	if !assert.Nil(t, err) {
+		return
+	}
+	_, err = tpl.Execute(map[string]interface{}{"items": []int{1}})
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "non recursive loop")
+	}
+}
+
+func TestLoopInternalsNotCallable(t *testing.T) {
+	tpl, err := gonja.FromString(`{% for i in items recursive %}{{ loop.recurse([]) }}{% endfor %}`)
+	if !assert.Nil(t, err) {
+		return
+	}
+	_, err = tpl.Execute(map[string]interface{}{"items": []int{1}})
+	assert.NotNil(t, err)
+}
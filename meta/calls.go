@@ -0,0 +1,83 @@
+package meta
+
+import (
+	"github.com/paradime-io/gonja/nodes"
+	"github.com/paradime-io/gonja/tokens"
+)
+
+// Argument is an argument of a call found by FindCalls
+type Argument struct {
+	// Value of a literal argument: a string, int, float64, bool,
+	// []interface{} or map[string]interface{}. It is nil otherwise.
+	Value interface{}
+	// Literal is false when the argument is only known at rendering,
+	// ie. a variable or an operation.
+	Literal    bool
+	Expression nodes.Expression
+}
+
+// Call is a call to a global function found by FindCalls
+type Call struct {
+	Name     string
+	Args     []*Argument
+	Kwargs   map[string]*Argument
+	Node     *nodes.Call
+	Location *tokens.Token
+}
+
+// Literal returns true if all the arguments of the call are literals
+func (c *Call) Literal() bool {
+	for _, arg := range c.Args {
+		if !arg.Literal {
+			return false
+		}
+	}
+	for _, arg := range c.Kwargs {
+		if !arg.Literal {
+			return false
+		}
+	}
+	return true
+}
+
+// FindCalls returns the calls to the global functions names found in tpl,
+// ie. "ref" for `{{ ref('model') }}`, by order of appearance.
+// Calls to variables of the same name declared by the template are ignored.
+func FindCalls(tpl *nodes.Template, names ...string) []*Call {
+	return Analyze(tpl, names...).Calls()
+}
+
+// Calls returns the calls to the functions given to Analyze by order of appearance
+func (a *Analysis) Calls() []*Call {
+	return a.calls
+}
+
+// call records call if it targets one of the searched global functions
+func (a *Analysis) call(call *nodes.Call) {
+	name, ok := call.Func.(*nodes.Name)
+	if !ok || !a.searched[name.Name.Val] || a.scope.has(name.Name.Val) {
+		return
+	}
+	found := &Call{
+		Name:     name.Name.Val,
+		Args:     []*Argument{},
+		Kwargs:   map[string]*Argument{},
+		Node:     call,
+		Location: call.Location,
+	}
+	for _, expr := range call.Args {
+		found.Args = append(found.Args, argument(expr))
+	}
+	for key, expr := range call.Kwargs {
+		found.Kwargs[key] = argument(expr)
+	}
+	a.calls = append(a.calls, found)
+}
+
+func argument(expr nodes.Expression) *Argument {
+	value, ok := nodes.Literal(expr)
+	if !ok {
+		return &Argument{Expression: expr}
+	}
+	return &Argument{Value: value, Literal: true, Expression: expr}
+}
@@ -0,0 +1,367 @@
+// Package meta analyzes templates without rendering them, ie. to find out
+// the variables a template expects or the templates it depends on.
+package meta
+
+import (
+	"sort"
+
+	"github.com/paradime-io/gonja/nodes"
+	"github.com/paradime-io/gonja/tokens"
+)
+
+// Statement is implemented by the statements taking part to the analysis.
+// Other statements are analyzed by walking their children (see Analysis.Unanalyzed).
+type Statement interface {
+	Analyze(a *Analysis)
+}
+
+// Reference is a template referenced by an extends, include or import statement
+type Reference struct {
+	Statement string // 'extends', 'include', 'import' or 'from'
+	Name      string // empty when Dynamic
+	Dynamic   bool   // the name is only known at rendering
+	Location  *tokens.Token
+}
+
+// Analysis walks a template, tracking the variables it declares and reads
+// along with the templates it references.
+type Analysis struct {
+	Template *nodes.Template // the analyzed template
+
+	scope      *scope
+	statement  *nodes.StatementBlock // the statement being analyzed
+	undeclared map[string]bool
+	references []*Reference
+	searched   map[string]bool // the global functions whose calls are recorded
+	calls      []*Call
+	unanalyzed []*nodes.StatementBlock
+}
+
+type scope struct {
+	parent   *scope
+	declared map[string]bool
+}
+
+func (s *scope) has(name string) bool {
+	for ; s != nil; s = s.parent {
+		if s.declared[name] {
+			return true
+		}
+	}
+	return false
+}
+
+// Analyze walks the nodes of tpl, recording the calls to the global functions calls.
+// Included, imported and parent templates are not analyzed.
+func Analyze(tpl *nodes.Template, calls ...string) *Analysis {
+	a := &Analysis{
+		Template:   tpl,
+		scope:      &scope{declared: map[string]bool{}},
+		undeclared: map[string]bool{},
+		searched:   map[string]bool{},
+	}
+	for _, name := range calls {
+		a.searched[name] = true
+	}
+	a.Declare("self")
+	for _, node := range tpl.Nodes {
+		a.Node(node)
+	}
+	return a
+}
+
+// FindUndeclaredVariables returns the sorted names of the variables tpl reads
+// from its context, ie. the ones to be given when rendering it.
+// Like Jinja, globals such as 'range' are reported as well.
+// Use Analyze to know whether every statement has been analyzed (see Analysis.Complete).
+func FindUndeclaredVariables(tpl *nodes.Template) []string {
+	return Analyze(tpl).Undeclared()
+}
+
+// FindReferencedTemplates returns the templates extended, included or imported by tpl,
+// by order of appearance.
+func FindReferencedTemplates(tpl *nodes.Template) []*Reference {
+	return Analyze(tpl).References()
+}
+
+// Undeclared returns the sorted names of the variables read before being declared
+func (a *Analysis) Undeclared() []string {
+	names := make([]string, 0, len(a.undeclared))
+	for name := range a.undeclared {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// References returns the referenced templates by order of appearance
+func (a *Analysis) References() []*Reference {
+	return a.references
+}
+
+// Node analyzes a node of the template
+func (a *Analysis) Node(node nodes.Node) {
+	switch n := node.(type) {
+	case *nodes.Output:
+		a.Expression(n.Expression)
+	case *nodes.StatementBlock:
+		outer := a.statement
+		a.statement = n
+		if stmt, ok := n.Stmt.(Statement); ok {
+			stmt.Analyze(a)
+		} else {
+			a.unanalyzed = append(a.unanalyzed, n)
+			a.children(n.Stmt)
+		}
+		a.statement = outer
+	case *nodes.Wrapper:
+		a.Wrapper(n)
+	}
+}
+
+// children analyzes the children of a statement which doesn't implement Statement
+func (a *Analysis) children(stmt nodes.Node) {
+	for _, child := range nodes.Children(stmt) {
+		switch child.(type) {
+		case *nodes.Output, *nodes.StatementBlock, *nodes.Wrapper:
+			a.Node(child)
+		default:
+			a.Expression(child)
+		}
+	}
+}
+
+// Unanalyzed returns the statements which don't implement Statement, by order
+// of appearance. The variables they declare or read outside of their children,
+// like the templates they reference, are unknown: the analysis is incomplete.
+func (a *Analysis) Unanalyzed() []*nodes.StatementBlock {
+	return a.unanalyzed
+}
+
+// Complete returns true if every statement of the template has been analyzed
+func (a *Analysis) Complete() bool {
+	return len(a.unanalyzed) == 0
+}
+
+// Wrapper analyzes the nodes of a statement body in the current scope
+func (a *Analysis) Wrapper(wrapper *nodes.Wrapper) {
+	if wrapper == nil {
+		return
+	}
+	for _, node := range wrapper.Nodes {
+		a.Node(node)
+	}
+}
+
+// Scope runs analyze within a new scope, the variables declared meanwhile
+// being forgotten afterwards. It returns the names of these variables.
+func (a *Analysis) Scope(analyze func()) map[string]bool {
+	current := &scope{parent: a.scope, declared: map[string]bool{}}
+	a.scope = current
+	defer func() { a.scope = current.parent }()
+	analyze()
+	return current.declared
+}
+
+// TemplateScope runs analyze like Scope but within a scope only seeing
+// the template level variables, as non scoped blocks do
+func (a *Analysis) TemplateScope(analyze func()) map[string]bool {
+	current := a.scope
+	for a.scope.parent != nil {
+		a.scope = a.scope.parent
+	}
+	defer func() { a.scope = current }()
+	return a.Scope(analyze)
+}
+
+// Declare declares variables in the current scope
+func (a *Analysis) Declare(names ...string) {
+	for _, name := range names {
+		if name != "" {
+			a.scope.declared[name] = true
+		}
+	}
+}
+
+// Target declares the variables assigned by a target such as 'a' or 'a, b'.
+// Attributes and items targets, ie. 'ns.a', read their owner.
+func (a *Analysis) Target(target nodes.Expression) {
+	switch t := target.(type) {
+	case *nodes.Name:
+		a.Declare(t.Name.Val)
+	case *nodes.Tuple:
+		for _, item := range t.Val {
+			a.Target(item)
+		}
+	case *nodes.List:
+		for _, item := range t.Val {
+			a.Target(item)
+		}
+	default:
+		a.Expression(target)
+	}
+}
+
+// Macro analyzes a macro definition. Default values are evaluated in the current scope,
+// the body in a new one declaring the arguments along with 'varargs', 'kwargs' and 'caller'.
+func (a *Analysis) Macro(macro *nodes.Macro) {
+	names := append([]string{"varargs", "kwargs", "caller"}, macro.Args...)
+	for _, pair := range macro.Kwargs {
+		a.Expression(pair.Value)
+		switch key := pair.Key.(type) {
+		case *nodes.String:
+			names = append(names, key.Val)
+		case *nodes.Name:
+			names = append(names, key.Name.Val)
+		}
+	}
+	a.Scope(func() {
+		a.Declare(names...)
+		a.Wrapper(macro.Wrapper)
+	})
+}
+
+// Reference records a template referenced by the statement being analyzed,
+// either by its name or by an expression. Expressions other than string literals
+// or lists of string literals are reported as dynamic references.
+// References are located at the tag opening the statement.
+func (a *Analysis) Reference(statement string, name string, expr nodes.Expression) {
+	var location *tokens.Token
+	if a.statement != nil {
+		location = a.statement.Location
+	}
+	if expr == nil {
+		a.references = append(a.references, &Reference{Statement: statement, Name: name, Location: location})
+		return
+	}
+	a.Expression(expr)
+	if names, ok := literalNames(expr); ok {
+		for _, name := range names {
+			a.references = append(a.references, &Reference{Statement: statement, Name: name, Location: location})
+		}
+		return
+	}
+	a.references = append(a.references, &Reference{Statement: statement, Dynamic: true, Location: location})
+}
+
+func literalNames(expr nodes.Expression) ([]string, bool) {
+	var items []nodes.Expression
+	switch e := expr.(type) {
+	case *nodes.String:
+		return []string{e.Val}, true
+	case *nodes.List:
+		items = e.Val
+	case *nodes.Tuple:
+		items = e.Val
+	default:
+		return nil, false
+	}
+	names := []string{}
+	for _, item := range items {
+		str, ok := item.(*nodes.String)
+		if !ok {
+			return nil, false
+		}
+		names = append(names, str.Val)
+	}
+	return names, true
+}
+
+// read records a variable read in the current scope
+func (a *Analysis) read(name string) {
+	if !a.scope.has(name) {
+		a.undeclared[name] = true
+	}
+}
+
+// Expression records the variables read by an expression
+func (a *Analysis) Expression(expr nodes.Node) {
+	switch e := expr.(type) {
+	case nil:
+	case *nodes.Name:
+		a.read(e.Name.Val)
+	case *nodes.Varargs:
+		a.read(e.Name.Name.Val)
+	case *nodes.Kwargs:
+		a.read(e.Name.Name.Val)
+	case *nodes.List:
+		a.expressions(e.Val)
+	case *nodes.Tuple:
+		a.expressions(e.Val)
+	case *nodes.Dict:
+		for _, pair := range e.Pairs {
+			a.Expression(pair)
+		}
+	case *nodes.Pair:
+		a.Expression(e.Key)
+		a.Expression(e.Value)
+	case *nodes.Call:
+		a.call(e)
+		a.Expression(e.Func)
+		a.expressions(e.Args)
+		a.kwargs(e.Kwargs)
+	case *nodes.Getattr:
+		a.Expression(e.Node)
+	case *nodes.Getitem:
+		a.Expression(e.Node)
+		if e.Arg != nil {
+			a.Expression(*e.Arg)
+		}
+	case *nodes.Getitemrange:
+		a.Expression(e.Node)
+		if e.Start != nil {
+			a.Expression(*e.Start)
+		}
+		if e.Stop != nil {
+			a.Expression(*e.Stop)
+		}
+	case *nodes.Negation:
+		a.Expression(e.Term)
+	case *nodes.UnaryExpression:
+		a.Expression(e.Term)
+	case *nodes.BinaryExpression:
+		a.Expression(e.Left)
+		a.Expression(e.Right)
+	case *nodes.InlineIfExpression:
+		a.Expression(e.Condition)
+		a.Expression(e.TrueBranch)
+		a.Expression(e.FalseBranch)
+	case *nodes.FilteredExpression:
+		a.Expression(e.Expression)
+		a.Filters(e.Filters)
+	case *nodes.TestExpression:
+		a.Expression(e.Expression)
+		if e.Test != nil {
+			a.expressions(e.Test.Args)
+			a.kwargs(e.Test.Kwargs)
+		}
+	case *nodes.Variable:
+		if len(e.Parts) > 0 && e.Parts[0].Type == nodes.VarTypeIdent {
+			a.read(e.Parts[0].S)
+		}
+		for _, part := range e.Parts {
+			a.expressions(part.Args)
+			a.kwargs(part.Kwargs)
+		}
+	}
+}
+
+// Filters records the variables read by the arguments of filters
+func (a *Analysis) Filters(filters []*nodes.FilterCall) {
+	for _, filter := range filters {
+		a.expressions(filter.Args)
+		a.kwargs(filter.Kwargs)
+	}
+}
+
+func (a *Analysis) expressions(exprs []nodes.Expression) {
+	for _, expr := range exprs {
+		a.Expression(expr)
+	}
+}
+
+func (a *Analysis) kwargs(kwargs map[string]nodes.Expression) {
+	for _, expr := range kwargs {
+		a.Expression(expr)
+	}
+}
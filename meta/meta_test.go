@@ -0,0 +1,211 @@
+package meta_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/paradime-io/gonja"
+	"github.com/paradime-io/gonja/config"
+	"github.com/paradime-io/gonja/loaders"
+	"github.com/paradime-io/gonja/meta"
+	"github.com/paradime-io/gonja/nodes"
+	"github.com/paradime-io/gonja/parser"
+	"github.com/paradime-io/gonja/tokens"
+)
+
+func parse(t *testing.T, source string) *nodes.Template {
+	env := gonja.NewEnvironment(config.NewConfig(), loaders.MapLoader{
+		"base.html":   `{% block content %}{{ base }}{% endblock %}`,
+		"other.html":  `{{ other }}`,
+		"macros.html": `{% macro m(x) %}{{ x }}{% endmacro %}`,
+	})
+	tpl, err := env.FromString(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tpl.Root
+}
+
+var undeclaredCases = []struct {
+	name     string
+	source   string
+	expected []string
+}{
+	{"none", `Hello`, []string{}},
+	{"output", `{{ a }}{{ b.c }}{{ d[e] }}{{ f(g, h=i) }}`, []string{"a", "b", "d", "e", "f", "g", "i"}},
+	{"expressions", `{{ a + -b if not c else [d, (e, f), {g: h}] }}{{ i[j:k] }}`, []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k"}},
+	{"filters and tests", `{{ a|default(b)|join(sep=c) }}{{ d is divisibleby e }}`, []string{"a", "b", "c", "d", "e"}},
+	{"self", `{{ self.title() }}`, []string{}},
+	{"set", `{% set a = b %}{{ a }}`, []string{"b"}},
+	{"set after use", `{{ a }}{% set a = 1 %}`, []string{"a"}},
+	{"set block", `{% set a %}{{ b }}{% endset %}{{ a }}`, []string{"b"}},
+	{"set attribute", `{% set ns.a = b %}`, []string{"b", "ns"}},
+	{"for", `{% for k, v in items if v > min %}{{ k }}{{ loop.index }}{% else %}{{ k }}{% endfor %}{{ v }}`, []string{"items", "k", "min", "v"}},
+	{"for scope", `{% for i in items %}{% set x = i %}{% endfor %}{{ x }}`, []string{"items", "x"}},
+	{"with", `{% with a = b, c = 1 %}{{ a }}{{ c }}{{ d }}{% endwith %}{{ a }}`, []string{"a", "b", "d"}},
+	{"if", `{% if a %}{% set x = 1 %}{{ x }}{% elif b %}{{ c }}{% endif %}{{ x }}`, []string{"a", "b", "c", "x"}},
+	{"if all branches", `{% if a %}{% set x = 1 %}{% else %}{% set x = 2 %}{% endif %}{{ x }}`, []string{"a"}},
+	{"macro", `{% macro m(a, b=c) %}{{ a }}{{ b }}{{ d }}{{ varargs }}{{ caller() }}{{ m() }}{% endmacro %}{{ m(e) }}`, []string{"c", "d", "e"}},
+	{"call", `{% call(x) m(a) %}{{ x }}{{ y }}{% endcall %}`, []string{"a", "m", "y"}},
+	{"import", `{% import "macros.html" as lib %}{{ lib.m(a) }}`, []string{"a"}},
+	{"from import", `{% from "macros.html" import m as mm %}{{ mm(a) }}{{ m }}`, []string{"a", "m"}},
+	{"dynamic import", `{% import name as lib %}`, []string{"name"}},
+	{"include", `{% include "other.html" %}{% include name %}`, []string{"name"}},
+	{"block", `{% block content %}{{ super() }}{{ a }}{% endblock %}`, []string{"a"}},
+	{"block in loop", `{% for i in a %}{% block b %}{{ i }}{% endblock %}{% endfor %}`, []string{"a", "i"}},
+	{"scoped block in loop", `{% for i in a %}{% block b scoped %}{{ i }}{% endblock %}{% endfor %}`, []string{"a"}},
+	{"extends", `{% extends "base.html" %}{% block content %}{{ a }}{% endblock %}`, []string{"a"}},
+	{"filter", `{% filter replace(a, b) %}{{ c }}{% endfilter %}`, []string{"a", "b", "c"}},
+	{"do", `{% do a.append(b) %}`, []string{"a", "b"}},
+	{"autoescape", `{% autoescape false %}{{ a }}{% endautoescape %}`, []string{"a"}},
+	{"raw", `{% raw %}{{ a }}{% endraw %}`, []string{}},
+}
+
+func TestFindUndeclaredVariables(t *testing.T) {
+	for _, tc := range undeclaredCases {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, meta.FindUndeclaredVariables(parse(t, test.source)))
+		})
+	}
+}
+
+func TestFindReferencedTemplates(t *testing.T) {
+	tpl := parse(t, `{% extends "base.html" %}
+{% block content %}
+{% include "other.html" %}
+{% include name ignore missing %}
+{% import "macros.html" as lib %}
+{% from prefix ~ "macros.html" import m %}
+{% endblock %}`)
+	refs := meta.FindReferencedTemplates(tpl)
+	if !assert.Len(t, refs, 5) {
+		return
+	}
+	assert.Equal(t, meta.Reference{Statement: "extends", Name: "base.html", Location: refs[0].Location}, *refs[0])
+	assert.Equal(t, "include", refs[1].Statement)
+	assert.Equal(t, "other.html", refs[1].Name)
+	assert.False(t, refs[1].Dynamic)
+	assert.Equal(t, "include", refs[2].Statement)
+	assert.True(t, refs[2].Dynamic)
+	assert.Equal(t, "", refs[2].Name)
+	assert.Equal(t, 4, refs[2].Location.Line)
+	assert.Equal(t, meta.Reference{Statement: "import", Name: "macros.html", Location: refs[3].Location}, *refs[3])
+	assert.Equal(t, meta.Reference{Statement: "from", Dynamic: true, Location: refs[4].Location}, *refs[4])
+
+	undeclared := meta.FindUndeclaredVariables(tpl)
+	assert.Equal(t, []string{"name", "prefix"}, undeclared)
+}
+
+func TestReferenceLocations(t *testing.T) {
+	source := `{% extends "base.html" %}{% include n %}{% import m as h %}{{ h }}
+  {% from "macros.html" import f %}`
+	refs := meta.FindReferencedTemplates(parse(t, source))
+	if !assert.Len(t, refs, 4) {
+		return
+	}
+	for idx, pos := range []int{0, 25, 40, 69} {
+		assert.Equal(t, pos, refs[idx].Location.Pos, refs[idx].Statement)
+		assert.Equal(t, "{%", source[pos:pos+2], refs[idx].Statement)
+	}
+	assert.Equal(t, 2, refs[3].Location.Line)
+	assert.Equal(t, 3, refs[3].Location.Col)
+}
+
+func TestDynamicExtends(t *testing.T) {
+	refs := meta.FindReferencedTemplates(parse(t, `{% extends layout if layout else "base.html" %}`))
+	if assert.Len(t, refs, 1) {
+		assert.Equal(t, "extends", refs[0].Statement)
+		assert.True(t, refs[0].Dynamic)
+	}
+	refs = meta.FindReferencedTemplates(parse(t, `{% extends ["missing.html", "base.html"] %}`))
+	if assert.Len(t, refs, 1) {
+		assert.Equal(t, "base.html", refs[0].Name)
+	}
+}
+
+func TestFindCalls(t *testing.T) {
+	tpl := parse(t, `{{ config(materialized="table", tags=["a", "b"], meta={"n": -1, "f": 1.5, "b": true}) }}
+select * from {{ ref('model') }} join {{ source("raw", name) }}
+{% for i in range(2) %}{{ ref(ref("nested")) }}{% endfor %}
+{% set source = other %}{{ source("shadowed") }}{{ lib.ref("attribute") }}`)
+	calls := meta.FindCalls(tpl, "config", "ref", "source")
+	if !assert.Len(t, calls, 5) {
+		return
+	}
+
+	config := calls[0]
+	assert.Equal(t, "config", config.Name)
+	assert.Empty(t, config.Args)
+	assert.True(t, config.Literal())
+	assert.Equal(t, "table", config.Kwargs["materialized"].Value)
+	assert.Equal(t, []interface{}{"a", "b"}, config.Kwargs["tags"].Value)
+	assert.Equal(t, map[string]interface{}{"n": -1, "f": 1.5, "b": true}, config.Kwargs["meta"].Value)
+	assert.Equal(t, 1, config.Location.Line)
+
+	ref := calls[1]
+	assert.Equal(t, "ref", ref.Name)
+	if assert.Len(t, ref.Args, 1) {
+		assert.Equal(t, &meta.Argument{Value: "model", Literal: true, Expression: ref.Node.Args[0]}, ref.Args[0])
+	}
+	assert.Equal(t, 2, ref.Location.Line)
+
+	source := calls[2]
+	assert.Equal(t, "source", source.Name)
+	assert.False(t, source.Literal())
+	if assert.Len(t, source.Args, 2) {
+		assert.True(t, source.Args[0].Literal)
+		assert.False(t, source.Args[1].Literal)
+		assert.Nil(t, source.Args[1].Value)
+		assert.IsType(t, &nodes.Name{}, source.Args[1].Expression)
+	}
+
+	assert.Equal(t, "ref", calls[3].Name)
+	assert.False(t, calls[3].Literal())
+	assert.Equal(t, "nested", calls[4].Args[0].Value)
+	assert.Equal(t, 3, calls[4].Location.Line)
+}
+
+// customStmt doesn't implement meta.Statement
+type customStmt struct {
+	Location *tokens.Token
+	Expr     nodes.Expression
+	Body     *nodes.Wrapper
+}
+
+func (stmt *customStmt) Position() *tokens.Token { return stmt.Location }
+func (stmt *customStmt) String() string          { return "customStmt" }
+func (stmt *customStmt) Children() []nodes.Node {
+	return []nodes.Node{stmt.Expr, stmt.Body}
+}
+
+func customParser(p *parser.Parser, args *parser.Parser) (nodes.Statement, error) {
+	stmt := &customStmt{Location: p.Current()}
+	expr, err := args.ParseExpression()
+	if err != nil {
+		return nil, err
+	}
+	stmt.Expr = expr
+	stmt.Body, _, err = p.WrapUntil("endcustom")
+	return stmt, err
+}
+
+func TestUnanalyzedStatements(t *testing.T) {
+	env := gonja.NewEnvironment(config.NewConfig(), loaders.MapLoader{})
+	if err := env.Statements.Register("custom", customParser); err != nil {
+		t.Fatal(err)
+	}
+	tpl, err := env.FromString(`{{ a }}{% custom b %}{{ c }}{% endcustom %}`)
+	if !assert.Nil(t, err) {
+		return
+	}
+	analysis := meta.Analyze(tpl.Root)
+	assert.Equal(t, []string{"a", "b", "c"}, analysis.Undeclared())
+	assert.False(t, analysis.Complete())
+	if assert.Len(t, analysis.Unanalyzed(), 1) {
+		assert.IsType(t, &customStmt{}, analysis.Unanalyzed()[0].Stmt)
+	}
+
+	assert.True(t, meta.Analyze(parse(t, `{% for i in a %}{% break %}{% endfor %}{% raw %}{{ b }}{% endraw %}`)).Complete())
+}
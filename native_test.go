@@ -0,0 +1,67 @@
+package gonja_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/paradime-io/gonja"
+)
+
+var nativeCases = []struct {
+	name     string
+	tpl      string
+	expected interface{}
+}{
+	{"list", `{{ [1, 2] + extra }}`, []interface{}{1, 2, "three", 4.5}},
+	{"dict", `{{ {'a': x, 'b': [x, flag]} }}`, map[string]interface{}{"a": 42, "b": []interface{}{42, true}}},
+	{"non string keys", `{{ {1: 'one', 'two': 2} }}`, map[interface{}]interface{}{1: "one", "two": 2}},
+	{"unhashable key", `{{ {k: 1} }}`, map[interface{}]interface{}{"[1]": 1}},
+	{"int", `{{ x + 1 }}`, 43},
+	{"bool", `{{ x > 1 }}`, true},
+	{"string", `{{ "[1, 2]" }}`, "[1, 2]"},
+	{"context value", `{{ extra }}`, []interface{}{"three", 4.5}},
+	{"trimmed", "  {{- x -}}  \n", 42},
+	{"trailing newline", "{{ flag }}\n", true},
+	{"statement", `{% if flag %}{{ x }}{% endif %}`, 42},
+	{"concatenated int", `{{ x }}{{ x }}`, 4242},
+	{"concatenated list", `[{{ x }}, '{{ "a" }}', {{ flag }}]`, []interface{}{42, "a", true}},
+	{"concatenated dict", `{'a': {{ x }}, 'b': -{{ x }}}`, map[string]interface{}{"a": 42, "b": -42}},
+	{"not a literal", `Hello {{ "World" }}`, "Hello World"},
+	{"unclosed", `[{{ x }}`, "[42"},
+	{"loop", `{% for i in range(3) %}{{ i }}{% endfor %}`, 12},
+	{"empty", `{% if false %}{{ x }}{% endif %}`, nil},
+}
+
+func TestExecuteNative(t *testing.T) {
+	ctx := map[string]interface{}{
+		"x":     42,
+		"flag":  true,
+		"extra": []interface{}{"three", 4.5},
+		"k":     []int{1},
+	}
+	for _, nc := range nativeCases {
+		test := nc
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+			tpl, err := gonja.FromString(test.tpl)
+			if !assert.Nil(err) {
+				return
+			}
+			value, err := tpl.ExecuteNative(ctx)
+			if assert.Nil(err) {
+				assert.Equal(test.expected, value)
+			}
+		})
+	}
+}
+
+func TestExecuteNativeError(t *testing.T) {
+	tpl, err := gonja.FromString(`{{ x|unknown }}`)
+	if !assert.Nil(t, err) {
+		return
+	}
+	value, err := tpl.ExecuteNative(nil)
+	assert.Nil(t, value)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,62 @@
+package nodes
+
+// Literal evaluates expr if it is a literal, ie. `[1, 'a', {'b': true}]`,
+// as a string, int, float64, bool, []interface{} or map[string]interface{}.
+// It returns false if expr depends on the rendering, ie. a variable
+// or an operation other than a sign, or if a dict has a non string key.
+func Literal(expr Expression) (interface{}, bool) {
+	switch e := expr.(type) {
+	case *String:
+		return e.Val, true
+	case *Integer:
+		return e.Val, true
+	case *Float:
+		return e.Val, true
+	case *Bool:
+		return e.Val, true
+	case *UnaryExpression:
+		switch term := e.Term.(type) {
+		case *Integer:
+			if e.Negative {
+				return -term.Val, true
+			}
+			return term.Val, true
+		case *Float:
+			if e.Negative {
+				return -term.Val, true
+			}
+			return term.Val, true
+		}
+	case *List:
+		return literals(e.Val)
+	case *Tuple:
+		return literals(e.Val)
+	case *Dict:
+		dict := map[string]interface{}{}
+		for _, pair := range e.Pairs {
+			key, ok := pair.Key.(*String)
+			if !ok {
+				return nil, false
+			}
+			value, ok := Literal(pair.Value)
+			if !ok {
+				return nil, false
+			}
+			dict[key.Val] = value
+		}
+		return dict, true
+	}
+	return nil, false
+}
+
+func literals(exprs []Expression) (interface{}, bool) {
+	values := []interface{}{}
+	for _, expr := range exprs {
+		value, ok := Literal(expr)
+		if !ok {
+			return nil, false
+		}
+		values = append(values, value)
+	}
+	return values, true
+}
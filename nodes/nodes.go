@@ -44,11 +44,12 @@ type Statement interface {
 
 // Template is the root node of any template
 type Template struct {
-	Name   string
-	Nodes  []Node
-	Blocks BlockSet
-	Macros map[string]*Macro
-	Parent *Template
+	Name     string
+	Nodes    []Node
+	Blocks   BlockSet
+	Required map[string]bool // blocks which must be overridden by a child template
+	Macros   map[string]*Macro
+	Parent   *Template
 }
 
 func (t *Template) Position() *tokens.Token { return t.Nodes[0].Position() }
@@ -57,6 +58,18 @@ func (t *Template) String() string {
 	return fmt.Sprintf("Template(Name=%s Line=%d Col=%d)", t.Name, tok.Line, tok.Col)
 }
 
+// BlockOwner returns the template defining the given block among tpl and its parents
+func (tpl *Template) BlockOwner(block *Wrapper) *Template {
+	for owner := tpl; owner != nil; owner = owner.Parent {
+		for _, wrapper := range owner.Blocks {
+			if wrapper == block {
+				return owner
+			}
+		}
+	}
+	return nil
+}
+
 func (tpl *Template) GetBlocks(name string) []*Wrapper {
 	var blocks []*Wrapper
 	if tpl.Parent != nil {
@@ -144,6 +157,12 @@ type FilterCall struct {
 	// filterFunc FilterFunction
 }
 
+func (fc *FilterCall) Position() *tokens.Token { return fc.Token }
+func (fc *FilterCall) String() string {
+	return fmt.Sprintf("FilterCall(name=%s Line=%d Col=%d)",
+		fc.Name, fc.Token.Line, fc.Token.Col)
+}
+
 type TestExpression struct {
 	Expression Expression
 	Test       *TestCall
@@ -171,6 +190,7 @@ type TestCall struct {
 	// testFunc TestFunction
 }
 
+func (tc *TestCall) Position() *tokens.Token { return tc.Token }
 func (tc *TestCall) String() string {
 	return fmt.Sprintf("TestCall(name=%s Line=%d Col=%d)",
 		tc.Name, tc.Token.Line, tc.Token.Col)
@@ -452,11 +472,12 @@ func (op BinOperator) Position() *tokens.Token { return op.Token }
 func (op BinOperator) String() string          { return op.Token.String() }
 
 type StatementBlock struct {
-	Location *tokens.Token
-	Name     string
-	Stmt     Statement
-	Trim     *Trim
-	LStrip   bool
+	Location      *tokens.Token
+	Name          string
+	Stmt          Statement
+	Trim          *Trim
+	LStrip        bool
+	LineStatement bool // true when written as a line statement
 }
 
 func (s StatementBlock) Position() *tokens.Token { return s.Location }
@@ -468,11 +489,12 @@ func (s StatementBlock) String() string {
 }
 
 type Wrapper struct {
-	Location *tokens.Token
-	Nodes    []Node
-	EndTag   string
-	Trim     *Trim
-	LStrip   bool
+	Location      *tokens.Token
+	Nodes         []Node
+	EndTag        string
+	Trim          *Trim
+	LStrip        bool
+	LineStatement bool // true when the end tag is a line statement
 }
 
 func (w Wrapper) Position() *tokens.Token { return w.Location }
@@ -1,21 +1,35 @@
 package nodes
 
 import (
-	"github.com/pkg/errors"
+	"sort"
 )
 
+// Parent is implemented by the nodes holding other nodes, ie. expressions
+// with operands or statements with a body. Custom statements implement it
+// to take part to the traversal of the templates.
+type Parent interface {
+	Node
+	// Children returns the direct children by order of appearance
+	Children() []Node
+}
+
+// Children returns the direct children of node, none if it is not a Parent
+func Children(node Node) []Node {
+	if parent, ok := node.(Parent); ok {
+		return parent.Children()
+	}
+	return nil
+}
+
 type Visitor interface {
 	Visit(node Node) (Visitor, error)
 }
 
-// type Visitor interface {
-// 	Template(node *Template) error
-// 	Comment(node *Template) error
-// 	Data(node *Data) error
-// 	Output(node *Output) error
-// 	Statement(node *Statement) error
-// }
-
+// Walk traverses an AST in depth-first order: It starts by calling
+// v.Visit(node); node must not be nil. If the visitor w returned by
+// v.Visit(node) is not nil, Walk is invoked recursively with visitor
+// w for each of the non-nil children of node, followed by a call of
+// w.Visit(nil). The traversal stops on the first error.
 func Walk(v Visitor, node Node) error {
 	v, err := v.Visit(node)
 	if err != nil {
@@ -24,28 +38,16 @@ func Walk(v Visitor, node Node) error {
 	if v == nil {
 		return nil
 	}
-
-	switch n := node.(type) {
-	case *Template:
-		for _, node := range n.Nodes {
-			if err := Walk(v, node); err != nil {
-				return err
-			}
+	for _, child := range Children(node) {
+		if child == nil {
+			continue
 		}
-	case *Wrapper:
-		for _, node := range n.Nodes {
-			if err := Walk(v, node); err != nil {
-				return err
-			}
+		if err := Walk(v, child); err != nil {
+			return err
 		}
-	// case *Data:
-	// 	return visitor.Data(t)
-	// case *Output:
-	// 	return visitor.Output(t)
-	default:
-		return errors.Errorf("Unkown type %T", n)
 	}
-	return nil
+	_, err = v.Visit(nil)
+	return err
 }
 
 type Inspector func(Node) bool
@@ -61,25 +63,123 @@ func (f Inspector) Visit(node Node) (Visitor, error) {
 // f(node); node must not be nil. If f returns true, Inspect invokes f
 // recursively for each of the non-nil children of node, followed by a
 // call of f(nil).
-//
 func Inspect(node Node, f func(Node) bool) {
 	Walk(Inspector(f), node)
 }
 
-// type NoOpVisitor struct {}
-
-// func (v *NoOpVisitor) Template(node *Template) error {
-// 	return nil
-// }
-// func (v *NoOpVisitor) Comment(node *Template) error {
-// 	return nil
-// }
-// func (v *NoOpVisitor) Data(node *Data) error {
-// 	return nil
-// }
-// func (v *NoOpVisitor) Output(node *Output) error {
-// 	return nil
-// }
-// func (v *NoOpVisitor) Statement(node *Statement) error {
-// 	return nil
-// }
+// Append appends the non-nil expressions to children
+func Append(children []Node, exprs ...Expression) []Node {
+	for _, expr := range exprs {
+		if expr != nil {
+			children = append(children, expr)
+		}
+	}
+	return children
+}
+
+// AppendKwargs appends keyword arguments to children, sorted by name
+func AppendKwargs(children []Node, kwargs map[string]Expression) []Node {
+	names := make([]string, 0, len(kwargs))
+	for name := range kwargs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		children = Append(children, kwargs[name])
+	}
+	return children
+}
+
+func (t *Template) Children() []Node { return t.Nodes }
+func (o *Output) Children() []Node   { return Append(nil, o.Expression) }
+
+func (expr *FilteredExpression) Children() []Node {
+	children := Append(nil, expr.Expression)
+	for _, filter := range expr.Filters {
+		children = append(children, filter)
+	}
+	return children
+}
+
+func (fc *FilterCall) Children() []Node {
+	return AppendKwargs(Append(nil, fc.Args...), fc.Kwargs)
+}
+
+func (expr *TestExpression) Children() []Node {
+	children := Append(nil, expr.Expression)
+	if expr.Test != nil {
+		children = append(children, expr.Test)
+	}
+	return children
+}
+
+func (tc *TestCall) Children() []Node {
+	return AppendKwargs(Append(nil, tc.Args...), tc.Kwargs)
+}
+
+func (l *List) Children() []Node  { return Append(nil, l.Val...) }
+func (t *Tuple) Children() []Node { return Append(nil, t.Val...) }
+
+func (d *Dict) Children() []Node {
+	children := make([]Node, 0, len(d.Pairs))
+	for _, pair := range d.Pairs {
+		children = append(children, pair)
+	}
+	return children
+}
+
+func (p *Pair) Children() []Node { return Append(nil, p.Key, p.Value) }
+
+func (v *Variable) Children() []Node {
+	var children []Node
+	for _, part := range v.Parts {
+		children = AppendKwargs(Append(children, part.Args...), part.Kwargs)
+	}
+	return children
+}
+
+func (c *Call) Children() []Node {
+	return AppendKwargs(Append(Append(nil, c.Func), c.Args...), c.Kwargs)
+}
+
+func (g *Getitem) Children() []Node {
+	children := Append(nil, g.Node)
+	if g.Arg != nil {
+		children = Append(children, *g.Arg)
+	}
+	return children
+}
+
+func (g *Getitemrange) Children() []Node {
+	children := Append(nil, g.Node)
+	if g.Start != nil {
+		children = Append(children, *g.Start)
+	}
+	if g.Stop != nil {
+		children = Append(children, *g.Stop)
+	}
+	return children
+}
+
+func (g *Getattr) Children() []Node          { return Append(nil, g.Node) }
+func (n *Negation) Children() []Node         { return Append(nil, n.Term) }
+func (u *UnaryExpression) Children() []Node  { return Append(nil, u.Term) }
+func (b *BinaryExpression) Children() []Node { return Append(nil, b.Left, b.Right) }
+
+func (expr *InlineIfExpression) Children() []Node {
+	return Append(nil, expr.TrueBranch, expr.Condition, expr.FalseBranch)
+}
+
+func (s StatementBlock) Children() []Node { return Append(nil, s.Stmt) }
+func (w Wrapper) Children() []Node        { return w.Nodes }
+
+func (m *Macro) Children() []Node {
+	children := make([]Node, 0, len(m.Kwargs)+1)
+	for _, pair := range m.Kwargs {
+		children = append(children, pair)
+	}
+	if m.Wrapper != nil {
+		children = append(children, m.Wrapper)
+	}
+	return children
+}
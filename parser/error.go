@@ -1,137 +1,163 @@
 package parser
 
 import (
-	"github.com/goph/emperror"
-	"github.com/paradime-io/gonja/tokens"
-	// "bufio"
-	// "fmt"
-	// "os"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
 	"github.com/pkg/errors"
+
+	"github.com/paradime-io/gonja/tokens"
 )
 
-// Error produces a nice error message and returns an error-object.
-// The 'token'-argument is optional. If provided, it will take
-// the token's position information. If not provided, it will
-// automatically use the CURRENT token's position information.
-func (p *Parser) Error(msg string, token *tokens.Token) error {
-	// if token == nil {
-	// 	// Set current token
-	// 	token = p.Current()
-	// 	if token == nil {
-	// 		// Set to last token
-	// 		if len(p.tokens) > 0 {
-	// 			token = p.tokens[len(p.tokens)-1]
-	// 		}
-	// 	}
-	// }
-	// var line, col int
-	// if token != nil {
-	// 	line = token.Line
-	// 	col = token.Col
-	// }
-	// return &Error{
-	// 	// Template:  p.template,
-	// 	// Filename:  p.name,
-	// 	Sender:    "parser",
-	// 	Line:      line,
-	// 	Column:    col,
-	// 	Token:     token,
-	// 	OrigError: errors.New(msg),
-	// }
-	// s += fmt.Sprintf(" | Line %d Col %d", e.Line, e.Column)
-	// 	if e.Token != nil {
-	// 		s += fmt.Sprintf(" near '%s'", e.Token.Val)
-	// 	}
-	if token == nil {
-		return errors.New(msg)
-	} else {
-		return emperror.With(
-			errors.Errorf(`%s (Line: %d Col: %d, near "%s")`, msg, token.Line, token.Col, token.Val),
-			"token", token,
-		)
+// Phase is the step of the template processing an Error occurred in
+type Phase string
+
+const (
+	LexPhase    Phase = "lex"
+	ParsePhase  Phase = "parse"
+	RenderPhase Phase = "render"
+)
+
+// Error is an error located in a template.
+// It wraps the error which caused it, possibly an Error located
+// in another template (ie. an included one), forming a template stack trace.
+type Error struct {
+	Template string
+	Line     int
+	Col      int
+	Token    *tokens.Token
+	Phase    Phase
+	Err      error
+	// Source is the template source, if known, used to print excerpts
+	Source string
+}
+
+// Error returns the cause message followed by the location
+func (e *Error) Error() string {
+	msg := e.Err.Error()
+	if e.Line <= 0 {
+		return msg
+	}
+	if e.Phase == RenderPhase {
+		return fmt.Sprintf(`%s in '%s' (Line: %d Col: %d)`, msg, e.Template, e.Line, e.Col)
+	}
+	if e.Token != nil {
+		return fmt.Sprintf(`%s (Line: %d Col: %d, near "%s")`, msg, e.Line, e.Col, e.Token.Val)
 	}
+	return fmt.Sprintf(`%s (Line: %d Col: %d)`, msg, e.Line, e.Col)
 }
 
-// // The Error type is being used to address an error during lexing, parsing or
-// // execution. If you want to return an error object (for example in your own
-// // tag or filter) fill this object with as much information as you have.
-// // Make sure "Sender" is always given (if you're returning an error within
-// // a filter, make Sender equals 'filter:yourfilter'; same goes for tags: 'tag:mytag').
-// // It's okay if you only fill in ErrorMsg if you don't have any other details at hand.
-// type Error struct {
-// 	// Template  *Template
-// 	// Filename  string
-// 	Line      int
-// 	Column    int
-// 	Token     *tokens.Token
-// 	Sender    string
-// 	OrigError error
-// }
+// Cause returns the underlying error
+func (e *Error) Cause() error { return e.Err }
 
-// // func (e *Error) updateFromTokenIfNeeded(template *Template, t *Token) *Error {
-// // 	if e.Template == nil {
-// // 		e.Template = template
-// // 	}
+// Unwrap returns the underlying error
+func (e *Error) Unwrap() error { return e.Err }
 
-// // 	if e.Token == nil {
-// // 		e.Token = t
-// // 		if e.Line <= 0 {
-// // 			e.Line = t.Line
-// // 			e.Column = t.Col
-// // 		}
-// // 	}
+// Stack returns the template stack trace, from the outermost template to the innermost.
+func (e *Error) Stack() []*Error {
+	stack := []*Error{}
+	var err error = e
+	for err != nil {
+		if located, ok := err.(*Error); ok {
+			stack = append(stack, located)
+		}
+		cause, ok := err.(interface{ Cause() error })
+		if !ok {
+			break
+		}
+		err = cause.Cause()
+	}
+	return stack
+}
 
-// // 	return e
-// // }
+// Excerpt returns the source lines around the error with the culprit underlined,
+// or an empty string when the source isn't known.
+func (e *Error) Excerpt() string {
+	width := 1
+	if e.Token != nil && len(e.Token.Val) > 0 {
+		width = utf8.RuneCountInString(e.Token.Val)
+	}
+	return Excerpt(e.Source, e.Line, e.Col, width)
+}
 
-// // Returns a nice formatted error string.
-// func (e *Error) Error() string {
-// 	s := "[Error"
-// 	if e.Sender != "" {
-// 		s += " (where: " + e.Sender + ")"
-// 	}
-// 	if e.Filename != "" {
-// 		s += " in " + e.Filename
-// 	}
-// 	if e.Line > 0 {
-// 		s += fmt.Sprintf(" | Line %d Col %d", e.Line, e.Column)
-// 		if e.Token != nil {
-// 			s += fmt.Sprintf(" near '%s'", e.Token.Val)
-// 		}
-// 	}
-// 	s += "] "
-// 	s += e.OrigError.Error()
-// 	return s
-// }
+// Excerpt returns the given line of source preceded by the previous one
+// and followed by a caret underlining width characters from col,
+// col being a byte offset as in tokens but width a number of runes.
+func Excerpt(source string, line, col, width int) string {
+	lines := strings.Split(source, "\n")
+	if source == "" || line <= 0 || line > len(lines) {
+		return ""
+	}
+	gutter := len(fmt.Sprintf("%d", line))
+	var out strings.Builder
+	for nb := line - 1; nb <= line; nb++ {
+		if nb <= 0 {
+			continue
+		}
+		fmt.Fprintf(&out, "%*d | %s\n", gutter, nb, lines[nb-1])
+	}
+	current := lines[line-1]
+	if col <= 0 {
+		col = 1
+	}
+	if col > len(current)+1 {
+		col = len(current) + 1
+	}
+	if remaining := utf8.RuneCountInString(current[col-1:]); width > remaining && remaining > 0 {
+		width = remaining
+	}
+	if width < 1 {
+		width = 1
+	}
+	// One character per rune before col, keeping tabs,
+	// so the caret is aligned with the source line
+	indent := strings.Map(func(r rune) rune {
+		if r == '\t' {
+			return r
+		}
+		return ' '
+	}, current[:col-1])
+	fmt.Fprintf(&out, "%s | %s%s", strings.Repeat(" ", gutter), indent, strings.Repeat("^", width))
+	return out.String()
+}
 
-// // // RawLine returns the affected line from the original template, if available.
-// // func (e *Error) RawLine() (line string, available bool, outErr error) {
-// // 	if e.Line <= 0 || e.Filename == "<string>" {
-// // 		return "", false, nil
-// // 	}
+// AsError returns the first Error in the causes chain of err, nil if none.
+func AsError(err error) *Error {
+	for err != nil {
+		if located, ok := err.(*Error); ok {
+			return located
+		}
+		cause, ok := err.(interface{ Cause() error })
+		if !ok {
+			return nil
+		}
+		err = cause.Cause()
+	}
+	return nil
+}
 
-// // 	filename := e.Filename
-// // 	if e.Template != nil {
-// // 		filename = e.Template.set.resolveFilename(e.Template, e.Filename)
-// // 	}
-// // 	file, err := os.Open(filename)
-// // 	if err != nil {
-// // 		return "", false, err
-// // 	}
-// // 	defer func() {
-// // 		err := file.Close()
-// // 		if err != nil && outErr == nil {
-// // 			outErr = err
-// // 		}
-// // 	}()
+// Error produces a nice error message and returns an error-object.
+// The 'token'-argument is optional. If provided, it will take
+// the token's position information.
+func (p *Parser) Error(msg string, token *tokens.Token) error {
+	phase := ParsePhase
+	if token != nil && token.Type == tokens.Error {
+		phase = LexPhase
+	}
+	return p.errorAt(phase, errors.New(msg), token)
+}
 
-// // 	scanner := bufio.NewScanner(file)
-// // 	l := 0
-// // 	for scanner.Scan() {
-// // 		l++
-// // 		if l == e.Line {
-// // 			return scanner.Text(), true, nil
-// // 		}
-// // 	}
-// // 	return "", false, nil
-// // }
+func (p *Parser) errorAt(phase Phase, err error, token *tokens.Token) error {
+	e := &Error{
+		Template: p.Name,
+		Phase:    phase,
+		Token:    token,
+		Err:      err,
+	}
+	if token != nil {
+		e.Line = token.Line
+		e.Col = token.Col
+	}
+	return e
+}
@@ -1,6 +1,8 @@
 package parser
 
 import (
+	"fmt"
+
 	"github.com/paradime-io/gonja/nodes"
 	"github.com/paradime-io/gonja/tokens"
 	log "github.com/sirupsen/logrus"
@@ -107,7 +109,7 @@ func (p *Parser) ParseExpressionNode() (nodes.Node, error) {
 
 	tok := p.Match(tokens.VariableBegin)
 	if tok == nil {
-		return nil, p.Error("'{{' expected here", p.Current())
+		return nil, p.Error(fmt.Sprintf("'%s' expected here", p.Config.VariableStartString), p.Current())
 	}
 
 	node := &nodes.Output{
@@ -128,7 +130,7 @@ func (p *Parser) ParseExpressionNode() (nodes.Node, error) {
 
 	tok = p.Match(tokens.VariableEnd)
 	if tok == nil {
-		return nil, p.Error("'}}' expected here", p.Current())
+		return nil, p.Error(fmt.Sprintf("'%s' expected here", p.Config.VariableEndString), p.Current())
 	}
 	node.End = tok
 	node.Trim.Right = tok.Val[0] == '-'
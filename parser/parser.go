@@ -27,6 +27,7 @@ type Parser struct {
 	Template       *nodes.Template
 	Statements     map[string]StatementParser
 	Level          int8
+	LoopLevel      int8 // number of enclosing loops, see break/continue
 	TemplateParser TemplateParser
 }
 
@@ -179,7 +180,7 @@ func (p *Parser) WrapUntil(names ...string) (*nodes.Wrapper, *Parser, error) {
 
 	for !p.Stream.End() {
 		// New tag, check whether we have to stop wrapping here
-		if begin := p.Match(tokens.BlockBegin); begin != nil {
+		if begin := p.Match(tokens.BlockBegin, tokens.LinestatementBegin); begin != nil {
 			ident := p.Peek(tokens.Name)
 
 			if ident != nil {
@@ -197,14 +198,14 @@ func (p *Parser) WrapUntil(names ...string) (*nodes.Wrapper, *Parser, error) {
 				if found {
 					// Okay, endtag found.
 					p.Consume() // '{%' tagname
-					wrapper.Trim.Left = begin.Val[len(begin.Val)-1] == '-'
-					wrapper.LStrip = begin.Val[len(begin.Val)-1] == '+'
+					wrapper.LStrip = hasLStrip(begin)
+					wrapper.LineStatement = begin.Type == tokens.LinestatementBegin
 
 					for {
-						if end := p.Match(tokens.BlockEnd); end != nil {
+						if end := p.Match(closingType(begin)); end != nil {
 							// Okay, end the wrapping here
 							wrapper.EndTag = ident.Val
-							wrapper.Trim.Right = end.Val[0] == '-'
+							wrapper.Trim = tagTrim(begin, end)
 							stream := tokens.NewStream(args)
 							return wrapper, NewParser(p.Name, p.Config, stream), nil
 						}
@@ -236,7 +237,7 @@ func (p *Parser) WrapUntil(names ...string) (*nodes.Wrapper, *Parser, error) {
 func (p *Parser) SkipUntil(names ...string) error {
 	for !p.End() {
 		// New tag, check whether we have to stop wrapping here
-		if p.Match(tokens.BlockBegin) != nil {
+		if begin := p.Match(tokens.BlockBegin, tokens.LinestatementBegin); begin != nil {
 			ident := p.Peek(tokens.Name)
 
 			if ident != nil {
@@ -256,7 +257,7 @@ func (p *Parser) SkipUntil(names ...string) error {
 					p.Consume() // '{%' tagname
 
 					for {
-						if p.Match(tokens.BlockEnd) != nil {
+						if p.Match(closingType(begin)) != nil {
 							// Done skipping, exit.
 							return nil
 						}
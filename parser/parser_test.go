@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"os"
 	"reflect"
+	"runtime"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
@@ -703,3 +705,18 @@ func TestParser(t *testing.T) {
 		})
 	}
 }
+
+func TestParseErrorDoesNotLeak(t *testing.T) {
+	assert := assert.New(t)
+	before := runtime.NumGoroutine()
+	for i := 0; i < 100; i++ {
+		_, err := parser.Parse("{{ foo( }}{% for x in y %}{{ x }}{% endfor %}")
+		assert.NotNil(err)
+	}
+	// Leaked goroutines would be blocked, give the others the time to end
+	for i := 0; i < 10 && runtime.NumGoroutine() > before; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	after := runtime.NumGoroutine()
+	assert.True(after <= before, "%d goroutines leaked", after-before)
+}
@@ -19,8 +19,8 @@ func (p *Parser) ParseStatement() (nodes.Statement, error) {
 		"current": p.Current(),
 	}).Trace("ParseStatement")
 
-	if p.Match(tokens.BlockBegin) == nil {
-		return nil, p.Error("'{%' expected here", p.Current())
+	if p.Match(tokens.BlockBegin, tokens.LinestatementBegin) == nil {
+		return nil, p.Error(fmt.Sprintf("'%s' expected here", p.Config.BlockStartString), p.Current())
 	}
 
 	name := p.Match(tokens.Name)
@@ -41,7 +41,7 @@ func (p *Parser) ParseStatement() (nodes.Statement, error) {
 	// }
 
 	var args []*tokens.Token
-	for p.Peek(tokens.BlockEnd) == nil && !p.Stream.End() {
+	for p.Peek(tokens.BlockEnd, tokens.LinestatementEnd) == nil && !p.Stream.End() {
 		// Add token to args
 		args = append(args, p.Next())
 		// p.Consume() // next token
@@ -52,7 +52,7 @@ func (p *Parser) ParseStatement() (nodes.Statement, error) {
 	// 	return nil, p.Error("Unexpectedly reached EOF, no statement end found.", p.lastToken)
 	// }
 
-	if p.Match(tokens.BlockEnd) == nil {
+	if p.Match(tokens.BlockEnd, tokens.LinestatementEnd) == nil {
 		return nil, p.Error(fmt.Sprintf(`Expected end of block "%s"`, p.Config.BlockEndString), p.Current())
 	}
 
@@ -75,7 +75,7 @@ func (p *Parser) ParseStatementBlock() (*nodes.StatementBlock, error) {
 		"current": p.Current(),
 	}).Trace("ParseStatementBlock")
 
-	begin := p.Match(tokens.BlockBegin)
+	begin := p.Match(tokens.BlockBegin, tokens.LinestatementBegin)
 	if begin == nil {
 		return nil, errors.Errorf(`Expected "%s" got "%s"`, p.Config.BlockStartString, p.Current())
 	}
@@ -99,7 +99,7 @@ func (p *Parser) ParseStatementBlock() (*nodes.StatementBlock, error) {
 
 	log.Trace("args")
 	var args []*tokens.Token
-	for p.Peek(tokens.BlockEnd) == nil && !p.Stream.End() {
+	for p.Peek(tokens.BlockEnd, tokens.LinestatementEnd) == nil && !p.Stream.End() {
 		log.Trace("for args")
 		// Add token to args
 		args = append(args, p.Next())
@@ -112,7 +112,7 @@ func (p *Parser) ParseStatementBlock() (*nodes.StatementBlock, error) {
 	// 	return nil, p.Error("Unexpectedly reached EOF, no statement end found.", p.lastToken)
 	// }
 
-	end := p.Match(tokens.BlockEnd)
+	end := p.Match(closingType(begin))
 	if end == nil {
 		return nil, p.Error(fmt.Sprintf(`Expected end of block "%s"`, p.Config.BlockEndString), p.Current())
 	}
@@ -140,13 +140,37 @@ func (p *Parser) ParseStatementBlock() (*nodes.StatementBlock, error) {
 	}
 	log.Trace("got stmt and return")
 	return &nodes.StatementBlock{
-		Location: begin,
-		Name:     name.Val,
-		Stmt:     stmt,
-		LStrip:   begin.Val[len(begin.Val)-1] == '+',
-		Trim: &nodes.Trim{
-			Left:  begin.Val[len(begin.Val)-1] == '-',
-			Right: end.Val[0] == '-',
-		},
+		Location:      begin,
+		Name:          name.Val,
+		Stmt:          stmt,
+		LStrip:        hasLStrip(begin),
+		Trim:          tagTrim(begin, end),
+		LineStatement: begin.Type == tokens.LinestatementBegin,
 	}, nil
 }
+
+// closingType returns the token type expected to close a tag opened by begin
+func closingType(begin *tokens.Token) tokens.Type {
+	if begin.Type == tokens.LinestatementBegin {
+		return tokens.LinestatementEnd
+	}
+	return tokens.BlockEnd
+}
+
+// hasLStrip returns whether or not the opening token disables lstrip ("{%+")
+func hasLStrip(begin *tokens.Token) bool {
+	return begin.Type == tokens.BlockBegin && begin.Val[len(begin.Val)-1] == '+'
+}
+
+// tagTrim computes the whitespace control of a tag given its delimiters.
+// Line statements do not support whitespace control.
+func tagTrim(begin, end *tokens.Token) *nodes.Trim {
+	trim := &nodes.Trim{}
+	if begin.Type == tokens.BlockBegin {
+		trim.Left = begin.Val[len(begin.Val)-1] == '-'
+	}
+	if end.Type == tokens.BlockEnd {
+		trim.Right = end.Val[0] == '-'
+	}
+	return trim
+}
@@ -1,6 +1,8 @@
 package parser
 
 import (
+	"github.com/pkg/errors"
+
 	"github.com/paradime-io/gonja/nodes"
 	"github.com/paradime-io/gonja/tokens"
 )
@@ -19,11 +21,11 @@ func (p *Parser) parseDocElement() (nodes.Node, error) {
 	case tokens.EOF:
 		p.Consume()
 		return nil, nil
-	case tokens.CommentBegin:
+	case tokens.CommentBegin, tokens.LinecommentBegin:
 		return p.ParseComment()
 	case tokens.VariableBegin:
 		return p.ParseExpressionNode()
-	case tokens.BlockBegin:
+	case tokens.BlockBegin, tokens.LinestatementBegin:
 		return p.ParseStatementBlock()
 	}
 	return nil, p.Error("Unexpected token (only HTML/tags/filters in templates allowed)", t)
@@ -31,9 +33,10 @@ func (p *Parser) parseDocElement() (nodes.Node, error) {
 
 func (p *Parser) ParseTemplate() (*nodes.Template, error) {
 	tpl := &nodes.Template{
-		Name:   p.Name,
-		Blocks: nodes.BlockSet{},
-		Macros: map[string]*nodes.Macro{},
+		Name:     p.Name,
+		Blocks:   nodes.BlockSet{},
+		Required: map[string]bool{},
+		Macros:   map[string]*nodes.Macro{},
 	}
 	p.Template = tpl
 
@@ -46,5 +49,9 @@ func (p *Parser) ParseTemplate() (*nodes.Template, error) {
 			tpl.Nodes = append(tpl.Nodes, node)
 		}
 	}
+	if p.Stream.IsError() {
+		tok := p.Current()
+		return nil, p.errorAt(LexPhase, errors.New(tok.Val), tok)
+	}
 	return tpl, nil
 }
@@ -0,0 +1,150 @@
+package gonja_test
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/paradime-io/gonja"
+	"github.com/paradime-io/gonja/builtins/statements"
+	"github.com/paradime-io/gonja/config"
+	"github.com/paradime-io/gonja/exec"
+	"github.com/paradime-io/gonja/loaders"
+	"github.com/paradime-io/gonja/nodes"
+	"github.com/paradime-io/gonja/parser"
+	"github.com/paradime-io/gonja/tokens"
+)
+
+var serializedTemplates = loaders.MapLoader{
+	"base.html":    `<{% block title %}base{% endblock %}|{% block content %}{% endblock %}>`,
+	"macros.html":  `{% macro bold(text, sep="!") %}*{{ text }}{{ sep }}*{% endmacro %}`,
+	"partial.html": `({{ item }})`,
+	"page.html": `{% extends "base.html" %}
+{%- block title %}{{ super() }} > page{% endblock %}
+{%- block content %}
+{%- import "macros.html" as lib %}{% from "macros.html" import bold as b %}
+{%- for item in items if item != "skip" %}{% include "partial.html" %}{% else %}empty{% endfor %}
+{%- set total = items|length %}{% set ns = namespace(x=0) %}{% set ns.x = total * 2 - 1 %}
+{{- lib.bold(total) }}{{ b("x", sep="?") }}{{ ns.x }}
+{%- if total is divisibleby 2 %} even{% elif total > 3 %} big{% else %} odd{% endif %}
+{%- with d = {"a": [1, 2.5, true], "b": (none, -1)} %} {{ d.a[1:] }}{{ d["b"]|list|length }}{% endwith %}
+{%- filter upper %} {{ "filtered" ~ "!" if not false }}{% endfilter %}
+{%- macro caller_test() %}[{{ caller() }}]{% endmacro %}{% call caller_test() %}called{% endcall %}
+{%- raw %} {{ raw }}{% endraw %}{% do lib.bold(items) %}
+{%- endblock %}`,
+}
+
+func serialize(t *testing.T, tpl *exec.Template) []byte {
+	var buf bytes.Buffer
+	if err := tpl.Serialize(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestSerialize(t *testing.T) {
+	env := gonja.NewEnvironment(config.NewConfig(), serializedTemplates)
+	tpl, err := env.GetTemplate("page.html")
+	if !assert.Nil(t, err) {
+		return
+	}
+	data := serialize(t, tpl)
+
+	// The statically referenced templates are serialized along with the template
+	loaded, err := gonja.NewEnvironment(config.NewConfig(), loaders.MapLoader{}).FromSerialized(bytes.NewReader(data))
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, "page.html", loaded.Name)
+
+	for _, items := range [][]interface{}{{}, {"a", "skip", "b"}, {"a", "b", "c", "d"}} {
+		expected, err := tpl.Execute(map[string]interface{}{"items": items})
+		if !assert.Nil(t, err) {
+			continue
+		}
+		out, err := loaded.Execute(map[string]interface{}{"items": items})
+		if assert.Nil(t, err) {
+			assert.Equal(t, expected, out)
+		}
+	}
+}
+
+func TestDeserializeBlocks(t *testing.T) {
+	env := gonja.NewEnvironment(config.NewConfig(), serializedTemplates)
+	tpl, err := env.GetTemplate("page.html")
+	if !assert.Nil(t, err) {
+		return
+	}
+	loaded, err := env.FromSerialized(bytes.NewReader(serialize(t, tpl)))
+	if !assert.Nil(t, err) {
+		return
+	}
+	// The blocks are the bodies of the deserialized block statements, so they are compiled
+	for root := loaded.Root; root != nil; root = root.Parent {
+		nodes.Inspect(root, func(node nodes.Node) bool {
+			if block, ok := node.(*nodes.StatementBlock); ok {
+				if stmt, ok := block.Stmt.(*statements.BlockStmt); ok {
+					assert.True(t, root.Blocks[stmt.Name] == stmt.Wrapper, "%s in %s", stmt.Name, root.Name)
+				}
+			}
+			return true
+		})
+	}
+	assert.Equal(t, tpl.Program.Len(), loaded.Program.Len())
+}
+
+func TestDeserializeSource(t *testing.T) {
+	env := gonja.NewEnvironment(config.NewConfig(), serializedTemplates)
+	tpl, err := env.FromString("Hello\n{{ missing.attr }}")
+	if !assert.Nil(t, err) {
+		return
+	}
+	loaded, err := env.FromSerialized(bytes.NewReader(serialize(t, tpl)))
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, tpl.Source, loaded.Source)
+	_, err = loaded.Execute(nil)
+	if located := parser.AsError(err); assert.NotNil(t, located, "got %v", err) {
+		assert.Contains(t, located.Excerpt(), "{{ missing.attr }}")
+	}
+}
+
+func TestDeserializeErrors(t *testing.T) {
+	env := gonja.NewEnvironment(config.NewConfig(), serializedTemplates)
+
+	_, err := env.FromSerialized(bytes.NewReader([]byte("{{ not serialized }}")))
+	assert.NotNil(t, err)
+
+	var buf bytes.Buffer
+	enc := gob.NewEncoder(&buf)
+	assert.Nil(t, enc.Encode(struct {
+		Format  string
+		Version int
+		Name    string
+	}{"gonja-template", exec.SerializationVersion + 1, "old.html"}))
+	_, err = env.FromSerialized(&buf)
+	assert.EqualError(t, err, fmt.Sprintf(
+		"Unable to deserialize template 'old.html': unsupported version %d (expected %d)",
+		exec.SerializationVersion+1, exec.SerializationVersion))
+}
+
+type unregisteredStmt struct {
+	Location *tokens.Token
+}
+
+func (stmt *unregisteredStmt) Position() *tokens.Token { return stmt.Location }
+func (stmt *unregisteredStmt) String() string          { return "unregisteredStmt" }
+
+func TestSerializeUnregistered(t *testing.T) {
+	tpl := &exec.Template{Name: "custom", Root: &nodes.Template{
+		Nodes: []nodes.Node{&nodes.StatementBlock{Stmt: &unregisteredStmt{}}},
+	}}
+	err := tpl.Serialize(&bytes.Buffer{})
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "Unable to serialize template 'custom'")
+	}
+}
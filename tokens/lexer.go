@@ -14,7 +14,11 @@ import (
 
 // EOF is an arbitraty value for End Of File
 const rEOF = -1
-const re_ENDRAW = `%s\s*%s`
+const re_ENDRAW = `%s[-+]?\s*%s`
+
+// re_LINE_ENDRAW matches a line statement closing a raw statement,
+// capturing its indentation
+const re_LINE_ENDRAW = `(?m:^([ \t]*)%s[ \t]*%s)`
 
 var escapedStrings = map[string]string{
 	`\"`: `"`,
@@ -37,44 +41,71 @@ type Lexer struct {
 	Col   int    // Current position in the line
 	// Position Position // Current lexing position in the input
 	Config        *config.Config // The lexer configuration
-	Tokens        chan *Token    // channel of scanned tokens.
+	Tokens        chan *Token    // channel of scanned tokens, filled by Run.
+	state         lexFn          // next state, nil once the input has been lexed
+	pending       []*Token       // scanned tokens not yet returned by Next
 	delimiters    []rune
 	RawStatements rawStmt
 	rawEnd        *regexp.Regexp
+	tag           Type // type of the token opening the tag being lexed
 }
 
-// TODO: set from env
 type rawStmt map[string]*regexp.Regexp
 
-// NewLexer creates a new scanner for the input string.
+// rawEndRegexp builds the regexp matching the closing tag of a raw statement
+// using the configured block delimiter and line statement prefix, if any.
+func rawEndRegexp(cfg *config.Config, end string) *regexp.Regexp {
+	pattern := fmt.Sprintf(re_ENDRAW, regexp.QuoteMeta(cfg.BlockStartString), end)
+	if cfg.LineStatementPrefix != "" {
+		pattern += "|" + fmt.Sprintf(re_LINE_ENDRAW, regexp.QuoteMeta(cfg.LineStatementPrefix), end)
+	}
+	return regexp.MustCompile(pattern)
+}
+
+// NewLexer creates a new scanner for the input string using the default configuration.
 func NewLexer(input string) *Lexer {
-	cfg := config.DefaultConfig
-	return &Lexer{
+	return NewLexerWithConfig(input, config.DefaultConfig)
+}
+
+// NewLexerWithConfig creates a new scanner for the input string
+// honoring the delimiters defined by the given configuration.
+func NewLexerWithConfig(input string, cfg *config.Config) *Lexer {
+	l := &Lexer{
 		Input:  input,
 		Tokens: make(chan *Token),
 		Config: cfg,
 		RawStatements: rawStmt{
-			"raw":     regexp.MustCompile(fmt.Sprintf(`%s\s*endraw`, cfg.BlockStartString)),
-			"comment": regexp.MustCompile(fmt.Sprintf(`%s\s*endcomment`, cfg.BlockStartString)),
+			"raw":     rawEndRegexp(cfg, "endraw"),
+			"comment": rawEndRegexp(cfg, "endcomment"),
 		},
 	}
+	l.state = l.lexData
+	return l
 }
 
+// Lex tokenizes the input using the default configuration.
 func Lex(input string) *Stream {
-	l := NewLexer(input)
-	go l.Run()
-	return NewStream(l.Tokens)
+	return LexWithConfig(input, config.DefaultConfig)
+}
+
+// LexWithConfig tokenizes the input using the given configuration.
+// Tokens are lexed on demand as the stream is consumed.
+func LexWithConfig(input string, cfg *config.Config) *Stream {
+	return NewStream(NewLexerWithConfig(input, cfg))
 }
 
 // errorf returns an error token and terminates the scan
 // by passing back a nil pointer that will be the next
 // state, terminating Lexer.Run.
 func (l *Lexer) errorf(format string, args ...interface{}) lexFn {
-	l.Tokens <- &Token{
+	line, col := ReadablePosition(l.Pos, l.Input)
+	l.pending = append(l.pending, &Token{
 		Type: Error,
 		Val:  fmt.Sprintf(format, args...),
 		Pos:  l.Pos,
-	}
+		Line: line,
+		Col:  col,
+	})
 	return nil
 }
 
@@ -91,11 +122,37 @@ func (l *Lexer) Current() string {
 	return l.Input[l.Start:l.Pos]
 }
 
+// Next lexes the input until a token is scanned and returns it,
+// nil once the EOF or an error token has been returned.
+// The Lexer is a TokenIterator.
+func (l *Lexer) Next() *Token {
+	for len(l.pending) == 0 {
+		if l.state == nil {
+			return nil
+		}
+		l.state = l.state()
+	}
+	tok := l.pending[0]
+	l.pending = l.pending[1:]
+	return tok
+}
+
+// All lexes the remaining input and returns its tokens,
+// ie. to be iterated with a SliceIterator.
+func (l *Lexer) All() []*Token {
+	toks := []*Token{}
+	for tok := l.Next(); tok != nil; tok = l.Next() {
+		toks = append(toks, tok)
+	}
+	return toks
+}
+
 // Run lexes the input by executing state functions until
-// the state is nil.
+// the state is nil, sending the tokens to the Tokens channel.
+// It blocks until all the tokens are received.
 func (l *Lexer) Run() {
-	for state := l.lexData; state != nil; {
-		state = state()
+	for tok := l.Next(); tok != nil; tok = l.Next() {
+		l.Tokens <- tok
 	}
 	close(l.Tokens) // No more tokens will be delivered.
 }
@@ -126,13 +183,13 @@ func (l *Lexer) processAndEmit(t Type, fn func(string) string) {
 	if fn != nil {
 		val = fn(val)
 	}
-	l.Tokens <- &Token{
+	l.pending = append(l.pending, &Token{
 		Type: t,
 		Val:  val,
 		Pos:  l.Start,
 		Line: line,
 		Col:  col,
-	}
+	})
 	l.Start = l.Pos
 }
 
@@ -205,27 +262,73 @@ func (l *Lexer) expectDelimiter(r rune) bool {
 	return r == expected
 }
 
-func (l *Lexer) lexData() lexFn {
-	for {
-		if l.hasPrefix(l.Config.CommentStartString) {
-			if l.Pos > l.Start {
-				l.emit(Data)
-			}
-			return l.lexComment
-		}
+// atLineStart returns whether or not the current position is the beginning of a line
+func (l *Lexer) atLineStart() bool {
+	return l.Pos == 0 || l.Input[l.Pos-1] == '\n'
+}
 
-		if l.hasPrefix(l.Config.VariableStartString) {
-			if l.Pos > l.Start {
-				l.emit(Data)
-			}
-			return l.lexVariable
+// hasIndentedPrefix returns the length of the spaces and tabs preceding prefix
+// at the current position or -1 if the prefix is not found.
+func (l *Lexer) hasIndentedPrefix(prefix string) int {
+	if prefix == "" {
+		return -1
+	}
+	remaining := l.remaining()
+	trimmed := strings.TrimLeft(remaining, " \t")
+	if !strings.HasPrefix(trimmed, prefix) {
+		return -1
+	}
+	return len(remaining) - len(trimmed)
+}
+
+// startDelimiter returns the state function for the tag opened at the current
+// position, if any, and the number of leading whitespace characters
+// that must be dropped before it. When delimiters share a prefix, the longest one wins.
+func (l *Lexer) startDelimiter() (lexFn, int) {
+	var (
+		state  lexFn
+		indent int
+		length int
+	)
+	candidates := []struct {
+		delimiter string
+		state     lexFn
+	}{
+		{l.Config.CommentStartString, l.lexComment},
+		{l.Config.VariableStartString, l.lexVariable},
+		{l.Config.BlockStartString, l.lexBlock},
+	}
+	for _, c := range candidates {
+		if len(c.delimiter) > length && l.hasPrefix(c.delimiter) {
+			state = c.state
+			length = len(c.delimiter)
 		}
+	}
+	if l.atLineStart() {
+		prefix := l.Config.LineStatementPrefix
+		if ws := l.hasIndentedPrefix(prefix); ws >= 0 && len(prefix) > length {
+			state = l.lexLineStatement
+			indent = ws
+			length = len(prefix)
+		}
+	}
+	prefix := l.Config.LineCommentPrefix
+	if ws := l.hasIndentedPrefix(prefix); ws >= 0 && len(prefix) > length {
+		state = l.lexLineComment
+		indent = ws
+	}
+	return state, indent
+}
 
-		if l.hasPrefix(l.Config.BlockStartString) {
+func (l *Lexer) lexData() lexFn {
+	for {
+		if state, indent := l.startDelimiter(); state != nil {
 			if l.Pos > l.Start {
 				l.emit(Data)
 			}
-			return l.lexBlock
+			l.Pos += indent
+			l.ignore()
+			return state
 		}
 
 		if l.next() == rEOF {
@@ -245,14 +348,30 @@ func (l *Lexer) remaining() string {
 }
 
 func (l *Lexer) lexRaw() lexFn {
-	loc := l.rawEnd.FindStringIndex(l.remaining())
-	if loc == nil {
-		return l.errorf(`Unable to find raw closing statement`)
+	start := l.Pos
+	for {
+		loc := l.rawEnd.FindStringSubmatchIndex(l.Input[start:])
+		if loc == nil {
+			return l.errorf(`Unable to find raw closing statement`)
+		}
+		if len(loc) < 4 || loc[2] < 0 {
+			l.Pos = start + loc[0]
+			l.emit(Data)
+			l.rawEnd = nil
+			return l.lexBlock
+		}
+		// A line statement only closes the raw statement at the start of a line,
+		// its indentation being dropped as in lexData
+		if at := start + loc[2]; at == 0 || l.Input[at-1] == '\n' {
+			l.Pos = at
+			l.emit(Data)
+			l.Pos = start + loc[3]
+			l.ignore()
+			l.rawEnd = nil
+			return l.lexLineStatement
+		}
+		start += loc[2] + 1
 	}
-	l.Pos += loc[0]
-	l.emit(Data)
-	l.rawEnd = nil
-	return l.lexBlock
 	// regexp.MustCompile(`(?m)(?P<key>\w+):\s+(?P<value>\w+)$`)
 	// idx := pattern
 }
@@ -271,7 +390,57 @@ func (l *Lexer) lexComment() lexFn {
 	return l.lexData
 }
 
+// lexLineComment lexes a comment running until the end of the line.
+// The newline itself is kept as data.
+func (l *Lexer) lexLineComment() lexFn {
+	l.Pos += len(l.Config.LineCommentPrefix)
+	l.emit(LinecommentBegin)
+	l.skipLine()
+	l.emit(Linecomment)
+	l.emit(LinecommentEnd)
+	return l.lexData
+}
+
+func (l *Lexer) lexLineStatement() lexFn {
+	l.tag = LinestatementBegin
+	l.Pos += len(l.Config.LineStatementPrefix)
+	l.emit(LinestatementBegin)
+	return l.lexStatementName
+}
+
+// lexLineStatementEnd consumes the end of the line, newline included.
+func (l *Lexer) lexLineStatementEnd() lexFn {
+	l.accept("\r")
+	l.accept("\n")
+	l.emit(LinestatementEnd)
+	if l.rawEnd != nil {
+		return l.lexRaw
+	}
+	return l.lexData
+}
+
+// atLineStatementEnd returns whether or not the remaining of the current line
+// ends the line statement: nothing but spaces, an optional trailing colon
+// or a line comment.
+func (l *Lexer) atLineStatementEnd() bool {
+	if len(l.delimiters) > 0 {
+		return false
+	}
+	line := l.remaining()
+	if i := strings.IndexAny(line, "\r\n"); i >= 0 {
+		line = line[:i]
+	}
+	if prefix := l.Config.LineCommentPrefix; prefix != "" {
+		if i := strings.Index(line, prefix); i >= 0 {
+			line = line[:i]
+		}
+	}
+	line = strings.TrimRight(line, " \t")
+	return line == "" || line == ":"
+}
+
 func (l *Lexer) lexVariable() lexFn {
+	l.tag = VariableBegin
 	l.Pos += len(l.Config.VariableStartString)
 	l.accept("-")
 	l.emit(VariableBegin)
@@ -286,10 +455,15 @@ func (l *Lexer) lexVariableEnd() lexFn {
 }
 
 func (l *Lexer) lexBlock() lexFn {
+	l.tag = BlockBegin
 	l.Pos += len(l.Config.BlockStartString)
 	l.accept("+-")
 	l.emit(BlockBegin)
-	for isSpace(l.peek()) || isEndOfLine(l.peek()) {
+	return l.lexStatementName
+}
+
+func (l *Lexer) lexStatementName() lexFn {
+	for isSpace(l.peek()) || (l.tag == BlockBegin && isEndOfLine(l.peek())) {
 		l.next()
 	}
 	if len(l.Current()) > 0 {
@@ -317,8 +491,12 @@ func (l *Lexer) lexBlockEnd() lexFn {
 
 func (l *Lexer) lexExpression() lexFn {
 	for {
+		if l.tag == LinestatementBegin && l.atLineStatementEnd() {
+			l.ignoreLine()
+			return l.lexLineStatementEnd
+		}
 		if !l.expectDelimiter(l.peek()) {
-			if l.hasPrefix(l.Config.VariableEndString) { // && l.expectDelimiter(l.peek()) {
+			if l.tag == VariableBegin && l.hasPrefix(l.Config.VariableEndString) { // && l.expectDelimiter(l.peek()) {
 				return l.lexVariableEnd
 			}
 
@@ -331,7 +509,7 @@ func (l *Lexer) lexExpression() lexFn {
 			// 	return lexText
 			// }
 
-			if l.hasPrefix(l.Config.BlockEndString) {
+			if l.tag == BlockBegin && l.hasPrefix(l.Config.BlockEndString) {
 				return l.lexBlockEnd
 			}
 		}
@@ -339,7 +517,7 @@ func (l *Lexer) lexExpression() lexFn {
 		r := l.next()
 		// remaining := l.Input[l.Pos:]
 		switch {
-		case isSpace(r):
+		case isSpace(r), isEndOfLine(r) && l.tag == LinestatementBegin:
 			return l.lexSpace
 		case isNumeric(r):
 			return l.lexNumber
@@ -362,10 +540,10 @@ func (l *Lexer) lexExpression() lexFn {
 		case '+':
 			l.emit(Add)
 		case '-':
-			if l.hasPrefix(l.Config.BlockEndString) {
+			if l.tag == BlockBegin && l.hasPrefix(l.Config.BlockEndString) {
 				l.backup()
 				return l.lexBlockEnd
-			} else if l.hasPrefix(l.Config.VariableEndString) {
+			} else if l.tag == VariableBegin && l.hasPrefix(l.Config.VariableEndString) {
 				l.backup()
 				return l.lexVariableEnd
 			} else {
@@ -453,8 +631,24 @@ func (l *Lexer) lexExpression() lexFn {
 	return l.lexData
 }
 
+// skipLine moves to the end of the current line, newline excluded.
+func (l *Lexer) skipLine() {
+	if i := strings.IndexAny(l.remaining(), "\r\n"); i >= 0 {
+		l.Pos += i
+	} else {
+		l.Pos = len(l.Input)
+	}
+}
+
+// ignoreLine skips over the remaining of the current line, newline excluded.
+func (l *Lexer) ignoreLine() {
+	l.skipLine()
+	l.ignore()
+}
+
 func (l *Lexer) lexSpace() lexFn {
-	for isSpace(l.peek()) {
+	// Line statements may span multiple lines within brackets
+	for isSpace(l.peek()) || (isEndOfLine(l.peek()) && l.tag == LinestatementBegin) {
 		l.next()
 	}
 	l.emit(Whitespace)
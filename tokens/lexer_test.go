@@ -3,6 +3,7 @@ package tokens_test
 import (
 	"testing"
 
+	"github.com/paradime-io/gonja/config"
 	"github.com/paradime-io/gonja/tokens"
 	"github.com/stretchr/testify/assert"
 )
@@ -302,6 +303,26 @@ func TestStreamSlice(t *testing.T) {
 	}
 }
 
+func TestLexerNext(t *testing.T) {
+	for _, lc := range lexerCases {
+		test := lc
+		t.Run(test.name, func(t *testing.T) {
+			lexer := tokens.NewLexer(test.input)
+			go lexer.Run()
+			expected := tokenSlice(lexer.Tokens)
+
+			assert := assert.New(t)
+			assert.Equal(expected, tokens.NewLexer(test.input).All())
+
+			lexer = tokens.NewLexer(test.input)
+			for _, token := range expected {
+				assert.Equal(token, lexer.Next())
+			}
+			assert.Nil(lexer.Next())
+		})
+	}
+}
+
 const positionsCase = `Hello
 {#
     Multiline comment
@@ -324,3 +345,158 @@ func TestLexerPosition(t *testing.T) {
 		&tokens.Token{tokens.EOF, "", 40, 6, 1},
 	}, toks)
 }
+
+func delimitersConfig(block, variable, comment [2]string) *config.Config {
+	cfg := config.NewConfig()
+	cfg.BlockStartString, cfg.BlockEndString = block[0], block[1]
+	cfg.VariableStartString, cfg.VariableEndString = variable[0], variable[1]
+	cfg.CommentStartString, cfg.CommentEndString = comment[0], comment[1]
+	return cfg
+}
+
+var delimitersCases = []struct {
+	name     string
+	cfg      *config.Config
+	input    string
+	expected []tok
+}{
+	{"multi-character delimiters",
+		delimitersConfig([2]string{"<%", "%>"}, [2]string{"<<", ">>"}, [2]string{"<#", "#>"}),
+		"<# c #>{{ x }}<% if a %><< b >><% endif %>", []tok{
+			tok{tokens.CommentBegin, "<#"}, data(" c "), tok{tokens.CommentEnd, "#>"},
+			data("{{ x }}"),
+			tok{tokens.BlockBegin, "<%"}, space, name("if"), space, name("a"), space, tok{tokens.BlockEnd, "%>"},
+			tok{tokens.VariableBegin, "<<"}, space, name("b"), space, tok{tokens.VariableEnd, ">>"},
+			tok{tokens.BlockBegin, "<%"}, space, name("endif"), space, tok{tokens.BlockEnd, "%>"},
+			EOF,
+		}},
+	{"asymmetric delimiters sharing the end",
+		delimitersConfig([2]string{`\BLOCK{`, "}"}, [2]string{`\VAR{`, "}"}, [2]string{`\#{`, "}"}),
+		`\BLOCK{if a}\VAR{ {'k': b}['k'] }\#{ c }\BLOCK{endif}`, []tok{
+			tok{tokens.BlockBegin, `\BLOCK{`}, name("if"), space, name("a"), tok{tokens.BlockEnd, "}"},
+			tok{tokens.VariableBegin, `\VAR{`}, space,
+			lBrace, str("k"), tok{tokens.Colon, ":"}, space, name("b"), rBrace,
+			lBracket, str("k"), rBracket,
+			space, tok{tokens.VariableEnd, "}"},
+			tok{tokens.CommentBegin, `\#{`}, data(" c "), tok{tokens.CommentEnd, "}"},
+			tok{tokens.BlockBegin, `\BLOCK{`}, name("endif"), tok{tokens.BlockEnd, "}"},
+			EOF,
+		}},
+	{"trim markers with custom delimiters",
+		delimitersConfig([2]string{"<%", "%>"}, [2]string{"${", "}"}, [2]string{"<#", "#>"}),
+		"<%- if a -%>${- b -}<% endif %>", []tok{
+			tok{tokens.BlockBegin, "<%-"}, space, name("if"), space, name("a"), space, tok{tokens.BlockEnd, "-%>"},
+			tok{tokens.VariableBegin, "${-"}, space, name("b"), space, tok{tokens.VariableEnd, "-}"},
+			tok{tokens.BlockBegin, "<%"}, space, name("endif"), space, tok{tokens.BlockEnd, "%>"},
+			EOF,
+		}},
+	{"longest start delimiter wins",
+		delimitersConfig([2]string{"<%", "%>"}, [2]string{"<%=", "%>"}, [2]string{"<%#", "%>"}),
+		"<%= a %><% if b %><%# c %><% endif %>", []tok{
+			tok{tokens.VariableBegin, "<%="}, space, name("a"), space, tok{tokens.VariableEnd, "%>"},
+			tok{tokens.BlockBegin, "<%"}, space, name("if"), space, name("b"), space, tok{tokens.BlockEnd, "%>"},
+			tok{tokens.CommentBegin, "<%#"}, data(" c "), tok{tokens.CommentEnd, "%>"},
+			tok{tokens.BlockBegin, "<%"}, space, name("endif"), space, tok{tokens.BlockEnd, "%>"},
+			EOF,
+		}},
+	{"raw with custom delimiters",
+		delimitersConfig([2]string{"[%", "%]"}, [2]string{"[[", "]]"}, [2]string{"[#", "#]"}),
+		"[% raw %][[ a ]] [% b %][%- endraw %]", []tok{
+			tok{tokens.BlockBegin, "[%"}, space, name("raw"), space, tok{tokens.BlockEnd, "%]"},
+			data("[[ a ]] [% b %]"),
+			tok{tokens.BlockBegin, "[%-"}, space, name("endraw"), space, tok{tokens.BlockEnd, "%]"},
+			EOF,
+		}},
+}
+
+func TestLexerWithConfig(t *testing.T) {
+	for _, lc := range delimitersCases {
+		test := lc
+		t.Run(test.name, func(t *testing.T) {
+			lexer := tokens.NewLexerWithConfig(test.input, test.cfg)
+			go lexer.Run()
+			toks := tokenSlice(lexer.Tokens)
+
+			assert := assert.New(t)
+			actual := []tok{}
+			for _, token := range toks {
+				actual = append(actual, tok{token.Type, token.Val})
+			}
+			assert.Equal(test.expected, actual)
+		})
+	}
+}
+
+func lineConfig() *config.Config {
+	cfg := config.NewConfig()
+	cfg.LineStatementPrefix = "#"
+	cfg.LineCommentPrefix = "##"
+	return cfg
+}
+
+var lineCases = []struct {
+	name     string
+	input    string
+	expected []tok
+}{
+	{"line statement", "a\n  # if b\nc\n# endif\n", []tok{
+		data("a\n"),
+		tok{tokens.LinestatementBegin, "#"}, space, name("if"), space, name("b"), tok{tokens.LinestatementEnd, "\n"},
+		data("c\n"),
+		tok{tokens.LinestatementBegin, "#"}, space, name("endif"), tok{tokens.LinestatementEnd, "\n"},
+		EOF,
+	}},
+	{"line statement with colon and no trailing newline", "# for x in y:\n# endfor", []tok{
+		tok{tokens.LinestatementBegin, "#"}, space, name("for"), space, name("x"), space,
+		name("in"), space, name("y"), tok{tokens.LinestatementEnd, "\n"},
+		tok{tokens.LinestatementBegin, "#"}, space, name("endfor"), tok{tokens.LinestatementEnd, ""},
+		EOF,
+	}},
+	{"line statement spanning brackets", "# set x = [1,\n  2]\n", []tok{
+		tok{tokens.LinestatementBegin, "#"}, space, name("set"), space, name("x"), space,
+		tok{tokens.Assign, "="}, space, lBracket, tok{tokens.Integer, "1"}, tok{tokens.Comma, ","},
+		tok{tokens.Whitespace, "\n  "}, tok{tokens.Integer, "2"}, rBracket, tok{tokens.LinestatementEnd, "\n"},
+		EOF,
+	}},
+	{"prefix not at line start", "color: #fff\n", []tok{
+		data("color: #fff\n"),
+		EOF,
+	}},
+	{"line comments", "a  ## comment\n## other\nb # c", []tok{
+		data("a"),
+		tok{tokens.LinecommentBegin, "##"}, tok{tokens.Linecomment, " comment"}, tok{tokens.LinecommentEnd, ""},
+		data("\n"),
+		tok{tokens.LinecommentBegin, "##"}, tok{tokens.Linecomment, " other"}, tok{tokens.LinecommentEnd, ""},
+		data("\nb # c"),
+		EOF,
+	}},
+	{"line comment after a line statement", "# if a ## comment\n# endif\n", []tok{
+		tok{tokens.LinestatementBegin, "#"}, space, name("if"), space, name("a"), tok{tokens.LinestatementEnd, "\n"},
+		tok{tokens.LinestatementBegin, "#"}, space, name("endif"), tok{tokens.LinestatementEnd, "\n"},
+		EOF,
+	}},
+	{"block tags are still available", "{% if a %}# x{% endif %}", []tok{
+		blockBegin, space, name("if"), space, name("a"), space, blockEnd,
+		data("# x"),
+		blockBegin, space, name("endif"), space, blockEnd,
+		EOF,
+	}},
+}
+
+func TestLexerLineStatements(t *testing.T) {
+	for _, lc := range lineCases {
+		test := lc
+		t.Run(test.name, func(t *testing.T) {
+			lexer := tokens.NewLexerWithConfig(test.input, lineConfig())
+			go lexer.Run()
+			toks := tokenSlice(lexer.Tokens)
+
+			assert := assert.New(t)
+			actual := []tok{}
+			for _, token := range toks {
+				actual = append(actual, tok{token.Type, token.Val})
+			}
+			assert.Equal(test.expected, actual)
+		})
+	}
+}
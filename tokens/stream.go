@@ -63,6 +63,8 @@ func NewStream(input interface{}) *Stream {
 		it = ChanIterator(t)
 	case []*Token:
 		it = SliceIterator(t)
+	case TokenIterator:
+		it = t
 	default:
 		panic(fmt.Sprintf(`Unsupported stream input type "%T"`, t))
 	}
@@ -0,0 +1,115 @@
+package gonja_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/paradime-io/gonja"
+	"github.com/paradime-io/gonja/config"
+	"github.com/paradime-io/gonja/exec"
+)
+
+var undefinedCases = []struct {
+	name   string
+	source string
+	// expected output per policy, "error" when the rendering must fail
+	chainable, undefined, strict, debug string
+}{
+	{"missing name", `[{{ missing }}]`,
+		"[]", "[]", "error", "[{{ missing }}]"},
+	{"missing attribute", `[{{ user.nick }}]`,
+		"[]", "[]", "error", "[{{ user.nick }}]"},
+	{"chained attributes", `[{{ missing.a.b }}]`,
+		"[]", "error", "error", "error"},
+	{"chained item", `[{{ missing['a'] }}]`,
+		"[]", "error", "error", "error"},
+	{"defined test", `{{ missing is defined }} {{ missing is undefined }} {{ user is defined }}`,
+		"False True True", "False True True", "False True True", "False True True"},
+	{"defined attribute", `{{ user.nick is defined }} {{ user.name is defined }}`,
+		"False True", "False True", "False True", "False True"},
+	{"default filter", `{{ missing|default('x') }} {{ user.nick|d('y') }}`,
+		"x y", "x y", "x y", "x y"},
+	{"other filter", `[{{ missing|upper }}]`,
+		"[]", "[]", "error", "[{{ MISSING }}]"},
+	{"if statement", `{% if missing %}yes{% else %}no{% endif %}`,
+		"no", "no", "error", "no"},
+	{"inline if", `{{ 'yes' if missing else 'no' }}`,
+		"no", "no", "error", "no"},
+	{"for loop", `[{% for i in missing %}{{ i }}{% endfor %}]`,
+		"[]", "[]", "error", "[]"},
+	{"none is not undefined", `[{{ nothing }}]`,
+		"[]", "[]", "[]", "[]"},
+	{"none is defined", `{{ nothing is defined }} {{ nothing is undefined }} {{ missing is defined }}`,
+		"True False False", "True False False", "True False False", "True False False"},
+	{"default keeps none", `[{{ nothing|default('x') }}] {{ nothing|default('x') is none }} {{ missing|default('x') }}`,
+		"[] True x", "[] True x", "[] True x", "[] True x"},
+	{"default boolean replaces none", `{{ nothing|default('x', true) }}`,
+		"x", "x", "x", "x"},
+}
+
+func TestUndefinedPolicies(t *testing.T) {
+	policies := []struct {
+		name   string
+		policy exec.UndefinedPolicy
+	}{
+		{"chainable", exec.ChainableUndefined},
+		{"undefined", exec.Undefined},
+		{"strict", exec.StrictUndefined},
+		{"debug", exec.DebugUndefined},
+	}
+	data := map[string]interface{}{
+		"user":    map[string]interface{}{"name": "john"},
+		"nothing": nil,
+	}
+	for _, tc := range undefinedCases {
+		test := tc
+		expected := []string{test.chainable, test.undefined, test.strict, test.debug}
+		for i, p := range policies {
+			policy := p
+			want := expected[i]
+			t.Run(test.name+"/"+policy.name, func(t *testing.T) {
+				env := gonja.NewEnvironment(config.NewConfig(), gonja.DefaultLoader)
+				env.Undefined = policy.policy
+				tpl, err := env.FromString(test.source)
+				if !assert.Nil(t, err) {
+					return
+				}
+				out, err := tpl.Execute(data)
+				if want == "error" {
+					assert.NotNil(t, err, "rendered %q", out)
+					assert.True(t, exec.IsUndefinedError(err), "got %v", err)
+					return
+				}
+				assert.Nil(t, err)
+				assert.Equal(t, want, out)
+			})
+		}
+	}
+}
+
+func TestStrictUndefinedError(t *testing.T) {
+	env := gonja.NewEnvironment(config.NewConfig(), gonja.DefaultLoader)
+	env.Undefined = exec.StrictUndefined
+	tpl, err := env.FromString("Hello\n  {{ user.nick }}")
+	if !assert.Nil(t, err) {
+		return
+	}
+	_, err = tpl.Execute(map[string]interface{}{"user": map[string]interface{}{}})
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "'user.nick' is undefined in 'string' (Line: 2 Col: 10)")
+	}
+}
+
+func TestDefaultUndefinedPolicy(t *testing.T) {
+	tpl, err := gonja.FromString(`[{{ m }}]{{ m.a }}`)
+	if !assert.Nil(t, err) {
+		return
+	}
+	_, err = tpl.Execute(nil)
+	if assert.NotNil(t, err) {
+		assert.True(t, exec.IsUndefinedError(err), "got %v", err)
+		assert.Equal(t, 1, strings.Count(err.Error(), "Line:"), err.Error())
+	}
+}
@@ -0,0 +1,92 @@
+package gonja_test
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/paradime-io/gonja"
+	"github.com/paradime-io/gonja/config"
+	"github.com/paradime-io/gonja/loaders"
+	"github.com/paradime-io/gonja/nodes"
+)
+
+func parseRoot(t *testing.T, source string) *nodes.Template {
+	env := gonja.NewEnvironment(config.NewConfig(), loaders.MapLoader{})
+	tpl, err := env.FromString(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tpl.Root
+}
+
+func TestInspect(t *testing.T) {
+	root := parseRoot(t, `{% if a %}{% for x in items if x > min %}{{ x|default(b) }}{% else %}{{ c is divisibleby d }}{% endfor %}
+{% elif e %}{% macro m(y=f) %}{{ g(y, k=h) }}{% endmacro %}
+{% else %}{% set s %}{{ i[j:k] }}{% endset %}{% with w = l %}{% filter upper %}{{ [m, {n: o}] }}{% endfilter %}{% endwith %}{% endif %}
+{% block content %}{% call p() %}{{ q.attr }}{% endcall %}{% endblock %}
+{% include r %}{% do -s + (t if u else v) %}{% raw %}{{ raw }}{% endraw %}`)
+
+	names := []string{}
+	filters := []string{}
+	tests := []string{}
+	nodes.Inspect(root, func(node nodes.Node) bool {
+		switch n := node.(type) {
+		case *nodes.Name:
+			names = append(names, n.Name.Val)
+		case *nodes.FilterCall:
+			filters = append(filters, n.Name)
+		case *nodes.TestCall:
+			tests = append(tests, n.Name)
+		}
+		return true
+	})
+	assert.Equal(t, []string{"a", "items", "x", "min", "x", "b", "c", "d", "e", "f", "g", "y", "h",
+		"s", "i", "j", "k", "l", "m", "n", "o", "p", "q", "r", "s", "t", "u", "v"}, names)
+	assert.Equal(t, []string{"default", "upper"}, filters)
+	assert.Equal(t, []string{"divisibleby"}, tests)
+}
+
+func TestInspectPrune(t *testing.T) {
+	root := parseRoot(t, `{% for x in items %}{{ x }}{% endfor %}{{ y }}`)
+	visited := 0
+	nodes.Inspect(root, func(node nodes.Node) bool {
+		if node == nil {
+			return false
+		}
+		visited++
+		_, isStatement := node.(*nodes.StatementBlock)
+		return !isStatement
+	})
+	// template, statement block, output and name
+	assert.Equal(t, 4, visited)
+}
+
+type errVisitor struct {
+	visited int
+	ends    int
+}
+
+func (v *errVisitor) Visit(node nodes.Node) (nodes.Visitor, error) {
+	if node == nil {
+		v.ends++
+		return nil, nil
+	}
+	v.visited++
+	if _, ok := node.(*nodes.Integer); ok {
+		return nil, errors.New("integer")
+	}
+	return v, nil
+}
+
+func TestWalk(t *testing.T) {
+	v := &errVisitor{}
+	assert.Nil(t, nodes.Walk(v, parseRoot(t, `{{ a }}`)))
+	assert.Equal(t, 3, v.visited)
+	assert.Equal(t, 3, v.ends)
+
+	v = &errVisitor{}
+	assert.EqualError(t, nodes.Walk(v, parseRoot(t, `{{ a + 1 }}{{ b }}`)), "integer")
+	assert.Equal(t, 5, v.visited)
+}